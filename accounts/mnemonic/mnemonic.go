@@ -0,0 +1,134 @@
+// Copyright 2022 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+// Package mnemonic implements BIP-39 mnemonic phrases and the BIP-32 derivation
+// of account keys from the seed of a phrase.
+//
+// Only the English wordlist is supported.
+package mnemonic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+var (
+	ErrEntropyLength = errors.New("entropy length must be a multiple of 32 bits within [128, 256]")
+	ErrWordCount     = errors.New("mnemonic must have 12, 15, 18, 21 or 24 words")
+	ErrChecksum      = errors.New("mnemonic checksum mismatch")
+)
+
+const seedIterations = 2048
+
+// wordIndex maps each word of the wordlist to its position.
+var wordIndex = make(map[string]int, len(englishWordlist))
+
+func init() {
+	if len(englishWordlist) != 2048 {
+		panic(fmt.Sprintf("mnemonic: wordlist has %d words", len(englishWordlist)))
+	}
+	for i, word := range englishWordlist {
+		wordIndex[word] = i
+	}
+}
+
+func validEntropyBits(bits int) bool {
+	return bits%32 == 0 && bits >= 128 && bits <= 256
+}
+
+// NewEntropy returns random entropy of the given number of bits, which must be
+// a multiple of 32 within [128, 256]. 128 bits make a 12 word phrase and 256
+// bits a 24 word phrase.
+func NewEntropy(bits int) ([]byte, error) {
+	if !validEntropyBits(bits) {
+		return nil, ErrEntropyLength
+	}
+	entropy := make([]byte, bits/8)
+	if _, err := rand.Read(entropy); err != nil {
+		return nil, err
+	}
+	return entropy, nil
+}
+
+// NewMnemonic encodes the entropy into a phrase of space separated words.
+func NewMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if !validEntropyBits(bits) {
+		return "", ErrEntropyLength
+	}
+	checksumBits := uint(bits / 32)
+	hash := sha256.Sum256(entropy)
+
+	// entropy || first checksumBits of sha256(entropy), read 11 bits per word
+	data := new(big.Int).SetBytes(entropy)
+	data.Lsh(data, checksumBits)
+	data.Or(data, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	words := make([]string, (bits+int(checksumBits))/11)
+	mask := big.NewInt(2047)
+	for i := len(words) - 1; i >= 0; i-- {
+		words[i] = englishWordlist[new(big.Int).And(data, mask).Int64()]
+		data.Rsh(data, 11)
+	}
+	return strings.Join(words, " "), nil
+}
+
+// EntropyFromMnemonic decodes a phrase back to its entropy, verifying the words
+// and the checksum.
+func EntropyFromMnemonic(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, ErrWordCount
+	}
+	data := new(big.Int)
+	for _, word := range words {
+		index, ok := wordIndex[strings.ToLower(word)]
+		if !ok {
+			return nil, fmt.Errorf("invalid mnemonic word %q", word)
+		}
+		data.Lsh(data, 11)
+		data.Or(data, big.NewInt(int64(index)))
+	}
+	checksumBits := uint(len(words) / 3)
+	checksum := new(big.Int).And(data, big.NewInt(int64(1)<<checksumBits-1)).Int64()
+	data.Rsh(data, checksumBits)
+
+	entropy := make([]byte, len(words)*4/3)
+	data.FillBytes(entropy)
+	hash := sha256.Sum256(entropy)
+	if int64(hash[0]>>(8-checksumBits)) != checksum {
+		return nil, ErrChecksum
+	}
+	return entropy, nil
+}
+
+// NewSeed verifies the phrase and returns the 64 byte seed derived from it and
+// the optional passphrase. BIP-39 expects both to be NFKD normalized; a phrase
+// of the English wordlist and an ASCII passphrase always are.
+func NewSeed(mnemonic, passphrase string) ([]byte, error) {
+	if _, err := EntropyFromMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+	normalized := strings.ToLower(strings.Join(strings.Fields(mnemonic), " "))
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), seedIterations, 64, sha512.New), nil
+}
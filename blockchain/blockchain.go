@@ -2592,6 +2592,19 @@ func (bc *BlockChain) SaveTrieNodeCacheToDisk() error {
 	return nil
 }
 
+// FlushRedisTrieNodeCache removes all items of the redis trie node cache. The local cache of a hybrid cache is kept.
+// See statedb.RedisCache.FlushAll.
+func (bc *BlockChain) FlushRedisTrieNodeCache() error {
+	switch cache := bc.stateCache.TrieDB().TrieNodeCache().(type) {
+	case *statedb.RedisCache:
+		return cache.FlushAll()
+	case *statedb.HybridCache:
+		return cache.Remote().FlushAll()
+	default:
+		return fmt.Errorf("trie node cache does not use redis. TrieNodeCacheType: %v", reflect.TypeOf(cache))
+	}
+}
+
 // ApplyTransaction attempts to apply a transaction to the given state database
 // and uses the input parameters for its environment. It returns the receipt
 // for the transaction, gas used and an error if the transaction failed,
@@ -50,6 +50,7 @@ func init() {
 		// See utils/nodecmd/chaincmd.go:
 		nodecmd.InitCommand,
 		nodecmd.DumpGenesisCommand,
+		nodecmd.DumpStakingInfoCommand,
 
 		// See utils/nodecmd/accountcmd.go
 		nodecmd.AccountCommand,
@@ -40,10 +40,13 @@ var FlagGroups = []FlagGroup{
 			DbTypeFlag,
 			DataDirFlag,
 			KeyStoreDirFlag,
+			ExtraKeyStoreDirFlag,
 			IdentityFlag,
 			SyncModeFlag,
 			GCModeFlag,
 			LightKDFFlag,
+			ScryptNFlag,
+			ScryptPFlag,
 			SrvTypeFlag,
 			ExtraDataFlag,
 			ConfigFileFlag,
@@ -190,8 +193,30 @@ var FlagGroups = []FlagGroup{
 			TrieNodeCacheSavePeriodFlag,
 			TrieNodeCacheRedisEndpointsFlag,
 			TrieNodeCacheRedisClusterFlag,
+			TrieNodeCacheRedisSentinelFlag,
+			TrieNodeCacheRedisMasterNameFlag,
+			TrieNodeCacheRedisUsernameFlag,
+			TrieNodeCacheRedisPasswordFlag,
 			TrieNodeCacheRedisPublishBlockFlag,
 			TrieNodeCacheRedisSubscribeBlockFlag,
+			TrieNodeCacheRedisRepopulateFlag,
+			TrieNodeCacheRedisTLSFlag,
+			TrieNodeCacheRedisTLSSkipVerifyFlag,
+			TrieNodeCacheRedisTLSCAFlag,
+			TrieNodeCacheRedisTLSCertFlag,
+			TrieNodeCacheRedisTLSKeyFlag,
+			TrieNodeCacheRedisSetItemChannelSizeFlag,
+			TrieNodeCacheRedisSetRetriesFlag,
+			TrieNodeCacheRedisSetRetryBackoffFlag,
+			TrieNodeCacheRedisKeyPrefixFlag,
+			TrieNodeCacheRedisEntryTTLFlag,
+			TrieNodeCacheRedisDialTimeoutFlag,
+			TrieNodeCacheRedisReadTimeoutFlag,
+			TrieNodeCacheRedisWriteTimeoutFlag,
+			TrieNodeCacheRedisPoolSizeFlag,
+			TrieNodeCacheRedisMinIdleConnsFlag,
+			TrieNodeCacheRedisCompressionFlag,
+			TrieNodeCacheLocalEvictionFlag,
 		},
 	},
 	{
@@ -199,6 +224,8 @@ var FlagGroups = []FlagGroup{
 		Flags: []cli.Flag{
 			ServiceChainSignerFlag,
 			RewardbaseFlag,
+			StakingUnavailablePolicyFlag,
+			StakingRecomputeTimeoutFlag,
 		},
 	},
 	{
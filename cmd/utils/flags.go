@@ -54,6 +54,7 @@ import (
 	"github.com/klaytn/klaytn/node/cn/filters"
 	"github.com/klaytn/klaytn/node/sc"
 	"github.com/klaytn/klaytn/params"
+	"github.com/klaytn/klaytn/reward"
 	"github.com/klaytn/klaytn/storage/database"
 	"github.com/klaytn/klaytn/storage/statedb"
 	"gopkg.in/urfave/cli.v1"
@@ -113,6 +114,53 @@ var (
 		Usage:  "Directory for the keystore (default = inside the datadir)",
 		EnvVar: "KLAYTN_KEYSTORE",
 	}
+	ExtraKeyStoreDirFlag = cli.StringSliceFlag{
+		Name:   "keystore.extra",
+		Usage:  "Additional keystore directory (can be given multiple times)",
+		EnvVar: "KLAYTN_KEYSTORE_EXTRA",
+	}
+	AccountNewCountFlag = cli.IntFlag{
+		Name:  "count",
+		Usage: "Number of accounts to create with the same password",
+		Value: 1,
+	}
+	AccountNewMnemonicFlag = cli.BoolFlag{
+		Name:  "mnemonic",
+		Usage: "Derive the new account from a generated BIP-39 mnemonic phrase, which is printed once",
+	}
+	AccountMnemonicWordsFlag = cli.IntFlag{
+		Name:  "mnemonic.words",
+		Usage: "Number of words of the generated mnemonic phrase (12 or 24)",
+		Value: 12,
+	}
+	AccountImportDryRunFlag = cli.BoolFlag{
+		Name:  "dry-run",
+		Usage: "Validate the key files and print the derived addresses without importing them",
+	}
+	PassphraseCacheFlag = cli.BoolFlag{
+		Name:  "passphrase.cache",
+		Usage: "Reuse a passphrase entered for an account within the same command (kept only in memory, never written to disk)",
+	}
+	AccountManifestOutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "File to write the account manifest to (default = stdout)",
+	}
+	AccountRotateOldPasswordFlag = cli.StringFlag{
+		Name:  "old-password",
+		Usage: "Password file holding the current password of the accounts (first line)",
+	}
+	AccountRotateNewPasswordFlag = cli.StringFlag{
+		Name:  "new-password",
+		Usage: "Password file holding the new password of the accounts (first line)",
+	}
+	AccountRotateYesFlag = cli.BoolFlag{
+		Name:  "yes",
+		Usage: "Rotate the passwords without asking for confirmation",
+	}
+	AccountDeleteForceFlag = cli.BoolFlag{
+		Name:  "force",
+		Usage: "Delete the key file without asking for confirmation",
+	}
 	// TODO-Klaytn-Bootnode: redefine networkid
 	NetworkIdFlag = cli.Uint64Flag{
 		Name:   "networkid",
@@ -149,6 +197,14 @@ var (
 		Usage:  "Reduce key-derivation RAM & CPU usage at some expense of KDF strength",
 		EnvVar: "KLAYTN_LIGHTKDF",
 	}
+	ScryptNFlag = cli.IntFlag{
+		Name:  "scrypt-n",
+		Usage: "Scrypt N parameter of the key store, overriding --lightkdf (power of two, costs N KiB of memory)",
+	}
+	ScryptPFlag = cli.IntFlag{
+		Name:  "scrypt-p",
+		Usage: "Scrypt P parameter of the key store, overriding --lightkdf",
+	}
 	OverwriteGenesisFlag = cli.BoolFlag{
 		Name:   "overwrite-genesis",
 		Usage:  "Overwrites genesis block with the given new genesis block for testing purpose",
@@ -397,6 +453,26 @@ var (
 		Usage:  "Enables cluster-enabled mode of redis trie node cache",
 		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_CLUSTER",
 	}
+	TrieNodeCacheRedisSentinelFlag = cli.BoolFlag{
+		Name:   "statedb.cache.redis.sentinel",
+		Usage:  "Enables sentinel mode of redis trie node cache. The endpoints are the addresses of the sentinels",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_SENTINEL",
+	}
+	TrieNodeCacheRedisMasterNameFlag = cli.StringFlag{
+		Name:   "statedb.cache.redis.master",
+		Usage:  "Name of the master of redis trie node cache monitored by the sentinels (sentinel mode only)",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_MASTER",
+	}
+	TrieNodeCacheRedisUsernameFlag = cli.StringFlag{
+		Name:   "statedb.cache.redis.username",
+		Usage:  "ACL username of redis trie node cache. The default user is used if not set",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_USERNAME",
+	}
+	TrieNodeCacheRedisPasswordFlag = cli.StringFlag{
+		Name:   "statedb.cache.redis.password",
+		Usage:  "Password of redis trie node cache. Prefer the environment variable not to expose it in the process list",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_PASSWORD",
+	}
 	TrieNodeCacheRedisPublishBlockFlag = cli.BoolFlag{
 		Name:   "statedb.cache.redis.publish",
 		Usage:  "Publishes every committed block to redis trie node cache",
@@ -407,6 +483,105 @@ var (
 		Usage:  "Subscribes blocks from redis trie node cache",
 		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_SUBSCRIBE",
 	}
+	TrieNodeCacheRedisRepopulateFlag = cli.BoolFlag{
+		Name:   "statedb.cache.redis.repopulate",
+		Usage:  "Re-populates redis trie node cache with items found in local cache but evicted from redis, checked on sampled local hits (hybrid cache only)",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_REPOPULATE",
+	}
+	TrieNodeCacheRedisTLSFlag = cli.BoolFlag{
+		Name:   "statedb.cache.redis.tls",
+		Usage:  "Enables TLS of the connections to redis trie node cache",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_TLS",
+	}
+	TrieNodeCacheRedisTLSSkipVerifyFlag = cli.BoolFlag{
+		Name:   "statedb.cache.redis.tls.skipverify",
+		Usage:  "Skips verifying the certificate of redis trie node cache (insecure, for testing only)",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_TLS_SKIPVERIFY",
+	}
+	TrieNodeCacheRedisTLSCAFlag = cli.StringFlag{
+		Name:   "statedb.cache.redis.tls.ca",
+		Usage:  "CA certificate file verifying redis trie node cache. The system CAs are used if not set",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_TLS_CA",
+	}
+	TrieNodeCacheRedisTLSCertFlag = cli.StringFlag{
+		Name:   "statedb.cache.redis.tls.cert",
+		Usage:  "Client certificate file for mutual TLS with redis trie node cache",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_TLS_CERT",
+	}
+	TrieNodeCacheRedisTLSKeyFlag = cli.StringFlag{
+		Name:   "statedb.cache.redis.tls.key",
+		Usage:  "Client private key file for mutual TLS with redis trie node cache",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_TLS_KEY",
+	}
+	TrieNodeCacheRedisSetItemChannelSizeFlag = cli.IntFlag{
+		Name:   "statedb.cache.redis.setitem.channel",
+		Usage:  "Size of the channel of items written to redis trie node cache asynchronously. 0 is for the default (10000)",
+		Value:  0,
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_SETITEM_CHANNEL",
+	}
+	TrieNodeCacheRedisSetRetriesFlag = cli.IntFlag{
+		Name:   "statedb.cache.redis.setitem.retries",
+		Usage:  "Number of retries of an item failed to be written to redis trie node cache asynchronously. 0 is for the default (2), and a negative value is for no retry",
+		Value:  0,
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_SETITEM_RETRIES",
+	}
+	TrieNodeCacheRedisSetRetryBackoffFlag = cli.DurationFlag{
+		Name:   "statedb.cache.redis.setitem.backoff",
+		Usage:  "Backoff before the first retry of an asynchronous write to redis trie node cache, doubled on every retry. 0 is for the default (50ms)",
+		Value:  0,
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_SETITEM_BACKOFF",
+	}
+	TrieNodeCacheRedisKeyPrefixFlag = cli.StringFlag{
+		Name:   "statedb.cache.redis.prefix",
+		Usage:  "Prefix of every key and channel name in redis trie node cache, to share redis with other networks",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_PREFIX",
+	}
+	TrieNodeCacheRedisEntryTTLFlag = cli.DurationFlag{
+		Name:   "statedb.cache.redis.ttl",
+		Usage:  "Expiration of the items written to redis trie node cache. 0 is for no expiration",
+		Value:  0,
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_TTL",
+	}
+	TrieNodeCacheRedisDialTimeoutFlag = cli.DurationFlag{
+		Name:   "statedb.cache.redis.timeout.dial",
+		Usage:  "Timeout of connecting to redis trie node cache. 0 is for the default (900ms)",
+		Value:  0,
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_TIMEOUT_DIAL",
+	}
+	TrieNodeCacheRedisReadTimeoutFlag = cli.DurationFlag{
+		Name:   "statedb.cache.redis.timeout.read",
+		Usage:  "Timeout of reading from redis trie node cache. 0 is for the default (900ms)",
+		Value:  0,
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_TIMEOUT_READ",
+	}
+	TrieNodeCacheRedisWriteTimeoutFlag = cli.DurationFlag{
+		Name:   "statedb.cache.redis.timeout.write",
+		Usage:  "Timeout of writing to redis trie node cache. 0 is for the default (900ms)",
+		Value:  0,
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_TIMEOUT_WRITE",
+	}
+	TrieNodeCacheRedisPoolSizeFlag = cli.IntFlag{
+		Name:   "statedb.cache.redis.pool.size",
+		Usage:  "Maximum number of connections to redis trie node cache (per node in cluster mode). 0 is for the default (10 per CPU)",
+		Value:  0,
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_POOL_SIZE",
+	}
+	TrieNodeCacheRedisMinIdleConnsFlag = cli.IntFlag{
+		Name:   "statedb.cache.redis.pool.minidle",
+		Usage:  "Minimum number of idle connections to redis trie node cache. 0 is for the default (no idle connections kept)",
+		Value:  0,
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_POOL_MINIDLE",
+	}
+	TrieNodeCacheRedisCompressionFlag = cli.StringFlag{
+		Name:   "statedb.cache.redis.compression",
+		Usage:  "Compression of the values written to redis trie node cache: \"\" (none) or \"snappy\"",
+		EnvVar: "KLAYTN_STATEDB_CACHE_REDIS_COMPRESSION",
+	}
+	TrieNodeCacheLocalEvictionFlag = cli.StringFlag{
+		Name:   "statedb.cache.local.eviction",
+		Usage:  "Eviction policy of the local cache of hybrid trie node cache: \"\" (fastcache), \"lru\" or \"lfu\". Align it with maxmemory-policy of redis",
+		EnvVar: "KLAYTN_STATEDB_CACHE_LOCAL_EVICTION",
+	}
 	TrieNodeCacheLimitFlag = cli.IntFlag{
 		Name:   "state.trie-cache-limit",
 		Usage:  "Memory allowance (MiB) to use for caching trie nodes in memory. -1 is for auto-scaling",
@@ -471,6 +646,18 @@ var (
 		Usage:  "Enable the legacy transaction resend logic (For testing only)",
 		EnvVar: "KLAYTN_TXRESEND_USE_LEGACY",
 	}
+	StakingUnavailablePolicyFlag = cli.StringFlag{
+		Name:   "staking.unavailable-policy",
+		Usage:  "Staking info served when it cannot be resolved while producing a block: fail, usePrevious or empty (usePrevious and empty are for private or test chains only)",
+		Value:  string(reward.StakingUnavailableFail),
+		EnvVar: "KLAYTN_STAKING_UNAVAILABLE_POLICY",
+	}
+	StakingRecomputeTimeoutFlag = cli.DurationFlag{
+		Name:   "staking.recompute-timeout",
+		Usage:  "Maximum time to wait for staking info recomputation while producing a block (0 = no limit)",
+		Value:  0,
+		EnvVar: "KLAYTN_STAKING_RECOMPUTE_TIMEOUT",
+	}
 	// Account settings
 	UnlockedAccountFlag = cli.StringFlag{
 		Name:   "unlock",
@@ -1686,9 +1873,18 @@ func SetNodeConfig(ctx *cli.Context, cfg *node.Config) {
 	if ctx.GlobalIsSet(KeyStoreDirFlag.Name) {
 		cfg.KeyStoreDir = ctx.GlobalString(KeyStoreDirFlag.Name)
 	}
+	if ctx.GlobalIsSet(ExtraKeyStoreDirFlag.Name) {
+		cfg.ExtraKeyStoreDirs = ctx.GlobalStringSlice(ExtraKeyStoreDirFlag.Name)
+	}
 	if ctx.GlobalIsSet(LightKDFFlag.Name) {
 		cfg.UseLightweightKDF = ctx.GlobalBool(LightKDFFlag.Name)
 	}
+	if ctx.GlobalIsSet(ScryptNFlag.Name) {
+		cfg.ScryptN = ctx.GlobalInt(ScryptNFlag.Name)
+	}
+	if ctx.GlobalIsSet(ScryptPFlag.Name) {
+		cfg.ScryptP = ctx.GlobalInt(ScryptPFlag.Name)
+	}
 	if ctx.GlobalIsSet(RPCNonEthCompatibleFlag.Name) {
 		rpc.NonEthCompatible = ctx.GlobalBool(RPCNonEthCompatibleFlag.Name)
 	}
@@ -1904,8 +2100,30 @@ func SetKlayConfig(ctx *cli.Context, stack *node.Node, cfg *cn.Config) {
 		FastCacheSavePeriod:       ctx.GlobalDuration(TrieNodeCacheSavePeriodFlag.Name),
 		RedisEndpoints:            ctx.GlobalStringSlice(TrieNodeCacheRedisEndpointsFlag.Name),
 		RedisClusterEnable:        ctx.GlobalBool(TrieNodeCacheRedisClusterFlag.Name),
+		RedisSentinelEnable:       ctx.GlobalBool(TrieNodeCacheRedisSentinelFlag.Name),
+		RedisMasterName:           ctx.GlobalString(TrieNodeCacheRedisMasterNameFlag.Name),
+		RedisUsername:             ctx.GlobalString(TrieNodeCacheRedisUsernameFlag.Name),
+		RedisPassword:             ctx.GlobalString(TrieNodeCacheRedisPasswordFlag.Name),
 		RedisPublishBlockEnable:   ctx.GlobalBool(TrieNodeCacheRedisPublishBlockFlag.Name),
 		RedisSubscribeBlockEnable: ctx.GlobalBool(TrieNodeCacheRedisSubscribeBlockFlag.Name),
+		RedisRepopulateEnable:     ctx.GlobalBool(TrieNodeCacheRedisRepopulateFlag.Name),
+		RedisTLSEnable:            ctx.GlobalBool(TrieNodeCacheRedisTLSFlag.Name),
+		RedisTLSSkipVerify:        ctx.GlobalBool(TrieNodeCacheRedisTLSSkipVerifyFlag.Name),
+		RedisTLSCAFile:            ctx.GlobalString(TrieNodeCacheRedisTLSCAFlag.Name),
+		RedisTLSCertFile:          ctx.GlobalString(TrieNodeCacheRedisTLSCertFlag.Name),
+		RedisTLSKeyFile:           ctx.GlobalString(TrieNodeCacheRedisTLSKeyFlag.Name),
+		RedisSetItemChannelSize:   ctx.GlobalInt(TrieNodeCacheRedisSetItemChannelSizeFlag.Name),
+		RedisSetRetries:           ctx.GlobalInt(TrieNodeCacheRedisSetRetriesFlag.Name),
+		RedisSetRetryBackoff:      ctx.GlobalDuration(TrieNodeCacheRedisSetRetryBackoffFlag.Name),
+		RedisKeyPrefix:            ctx.GlobalString(TrieNodeCacheRedisKeyPrefixFlag.Name),
+		RedisEntryTTL:             ctx.GlobalDuration(TrieNodeCacheRedisEntryTTLFlag.Name),
+		RedisDialTimeout:          ctx.GlobalDuration(TrieNodeCacheRedisDialTimeoutFlag.Name),
+		RedisReadTimeout:          ctx.GlobalDuration(TrieNodeCacheRedisReadTimeoutFlag.Name),
+		RedisWriteTimeout:         ctx.GlobalDuration(TrieNodeCacheRedisWriteTimeoutFlag.Name),
+		RedisPoolSize:             ctx.GlobalInt(TrieNodeCacheRedisPoolSizeFlag.Name),
+		RedisMinIdleConns:         ctx.GlobalInt(TrieNodeCacheRedisMinIdleConnsFlag.Name),
+		RedisCompression:          statedb.RedisCompressionType(ctx.GlobalString(TrieNodeCacheRedisCompressionFlag.Name)).ToValid(),
+		LocalCacheEvictionPolicy:  statedb.LocalCacheEvictionPolicy(ctx.GlobalString(TrieNodeCacheLocalEvictionFlag.Name)).ToValid(),
 	}
 
 	if ctx.GlobalIsSet(VMEnableDebugFlag.Name) {
@@ -1966,6 +2184,18 @@ func SetKlayConfig(ctx *cli.Context, stack *node.Node, cfg *cn.Config) {
 	*/
 	// Set the Tx resending related configuration variables
 	setTxResendConfig(ctx, cfg)
+
+	// Only CNs could set StakingUnavailablePolicyFlag and StakingRecomputeTimeoutFlag
+	if ctx.GlobalIsSet(StakingUnavailablePolicyFlag.Name) {
+		policy, err := reward.ParseStakingUnavailablePolicy(ctx.GlobalString(StakingUnavailablePolicyFlag.Name))
+		if err != nil {
+			log.Fatalf("Option %q: %v", StakingUnavailablePolicyFlag.Name, err)
+		}
+		cfg.StakingUnavailablePolicy = policy
+	}
+	if ctx.GlobalIsSet(StakingRecomputeTimeoutFlag.Name) {
+		cfg.StakingRecomputeTimeout = ctx.GlobalDuration(StakingRecomputeTimeoutFlag.Name)
+	}
 }
 
 func MakeGenesis(ctx *cli.Context) *blockchain.Genesis {
@@ -2080,9 +2310,17 @@ func MakeConsolePreloads(ctx *cli.Context) []string {
 func MigrateFlags(action func(ctx *cli.Context) error) func(*cli.Context) error {
 	return func(ctx *cli.Context) error {
 		for _, name := range ctx.FlagNames() {
-			if ctx.IsSet(name) {
-				ctx.GlobalSet(name, ctx.String(name))
+			if !ctx.IsSet(name) {
+				continue
+			}
+			// Values of a slice flag are set one by one not to be merged into a value.
+			if values, ok := ctx.Generic(name).(*cli.StringSlice); ok {
+				for _, value := range values.Value() {
+					ctx.GlobalSet(name, value)
+				}
+				continue
 			}
+			ctx.GlobalSet(name, ctx.String(name))
 		}
 		return action(ctx)
 	}
@@ -21,12 +21,22 @@
 package nodecmd
 
 import (
+	"crypto/ecdsa"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/klaytn/klaytn/accounts"
 	"github.com/klaytn/klaytn/accounts/keystore"
+	"github.com/klaytn/klaytn/accounts/mnemonic"
 	"github.com/klaytn/klaytn/api/debug"
 	"github.com/klaytn/klaytn/cmd/utils"
+	"github.com/klaytn/klaytn/common"
 	"github.com/klaytn/klaytn/console"
 	"github.com/klaytn/klaytn/crypto"
 	"github.com/klaytn/klaytn/log"
@@ -40,7 +50,8 @@ var AccountCommand = cli.Command{
 	Description: `
 
 Manage accounts, list all existing accounts, import a private key into a new
-account, create a new account or update an existing account.
+account or a directory of private keys into new accounts, create a new account,
+update an existing account or delete one.
 
 It supports interactive mode, when you are prompted for password as well as
 non-interactive mode where passwords are supplied via a given password file.
@@ -53,10 +64,23 @@ either new or import). Without it you are not able to unlock your account.
 Note that exporting your key in unencrypted format is NOT supported.
 
 Keys are stored under <DATADIR>/keystore.
+Additional keystore directories can be given with --keystore.extra; accounts in
+them are listed and updated together, while new keys are stored in the primary one.
 It is safe to transfer the entire directory or the individual keys therein
 between klay nodes by simply copying.
 
-Make sure you backup your keys regularly.`,
+Make sure you backup your keys regularly.
+
+The account subcommands exit with the following codes so that scripts can tell
+failure classes apart:
+
+    0  success
+    1  unclassified failure
+    2  missing or malformed arguments
+    3  keystore or configuration could not be accessed
+    4  no key for the given address in the keystore
+    5  the key could not be decrypted with the given passphrase
+    6  the passphrase could not be read or was not confirmed`,
 	Subcommands: []cli.Command{
 		{
 			Name:   "list",
@@ -65,9 +89,73 @@ Make sure you backup your keys regularly.`,
 			Flags: []cli.Flag{
 				utils.DataDirFlag,
 				utils.KeyStoreDirFlag,
+				utils.ExtraKeyStoreDirFlag,
 			},
 			Description: `
 Print a short summary of all accounts`,
+		},
+		{
+			Name:      "has",
+			Usage:     "Check if a key file of an account exists",
+			Action:    utils.MigrateFlags(accountHas),
+			ArgsUsage: "<address>",
+			Flags: []cli.Flag{
+				utils.DataDirFlag,
+				utils.KeyStoreDirFlag,
+				utils.ExtraKeyStoreDirFlag,
+			},
+			Description: `
+    klay account has <address>
+
+Checks if a key file of the given address exists in the keystore and prints the
+paths of the matching key files. If more than one key file exists for the
+address, all of them are printed. The account is not unlocked, so no passphrase
+is needed.
+
+It exits with code 4 if no key file of the address exists.`,
+		},
+		{
+			Name:   "check",
+			Usage:  "Check if accounts to be unlocked by a node can be unlocked",
+			Action: utils.MigrateFlags(accountCheck),
+			Flags: []cli.Flag{
+				utils.DataDirFlag,
+				utils.KeyStoreDirFlag,
+				utils.UnlockedAccountFlag,
+				utils.PasswordFileFlag,
+			},
+			Description: `
+    klay account check --unlock <accounts> [--password <file>]
+
+Checks if the accounts given by the --unlock flag can be unlocked, before starting
+a node with the same flags. The accounts are unlocked exactly as the node does:
+an account is given by an address or an index of the keystore, and the password
+of the i-th account is the i-th line of the password file (or its last line if
+the file is shorter). Without a password file, you are prompted for passwords.
+
+The result of each account is printed, and it exits with a non-zero code if any
+account cannot be unlocked.`,
+		},
+		{
+			Name:   "manifest",
+			Usage:  "Print a JSON manifest of existing accounts",
+			Action: utils.MigrateFlags(accountManifest),
+			Flags: []cli.Flag{
+				utils.DataDirFlag,
+				utils.KeyStoreDirFlag,
+				utils.ExtraKeyStoreDirFlag,
+				utils.AccountManifestOutputFlag,
+			},
+			Description: `
+    klay account manifest [--output accounts.json]
+
+Prints a JSON manifest of all accounts for audits and backups. Each entry has
+the address, the URL, the path of the key file and its creation time taken from
+the modification time of the file. Accounts without a key file have neither a
+path nor a creation time.
+
+The manifest is written to the file given by --output, or to stdout.
+Nothing is written to the keystore.`,
 		},
 		{
 			Name:   "new",
@@ -78,12 +166,26 @@ Print a short summary of all accounts`,
 				utils.KeyStoreDirFlag,
 				utils.PasswordFileFlag,
 				utils.LightKDFFlag,
+				utils.ScryptNFlag,
+				utils.ScryptPFlag,
+				utils.AccountNewCountFlag,
+				utils.AccountNewMnemonicFlag,
+				utils.AccountMnemonicWordsFlag,
 			},
 			Description: `
-    klay account new
+    klay account new [--count <n>] [--mnemonic [--mnemonic.words <12|24>]]
 
 Creates a new account and prints the address.
 
+With the --count flag, creates the given number of accounts, each with its own
+key, locked with the same passphrase, and prints all the addresses. You are
+prompted for the passphrase only once. At most 1000 accounts can be created at once.
+
+With the --mnemonic flag, generates a BIP-39 mnemonic phrase of 12 words (or 24
+with --mnemonic.words 24) and derives the key at m/44'/8217'/0'/0/0 from it. The
+phrase is printed only once; write it down and keep it secret, as anyone knowing
+it controls the account. Use "klay account import-mnemonic" to recover the account.
+
 The account is saved in encrypted format, you are prompted for a passphrase.
 
 You must remember this passphrase to unlock your account in the future.
@@ -102,7 +204,13 @@ password to file or expose in any other way.
 			Flags: []cli.Flag{
 				utils.DataDirFlag,
 				utils.KeyStoreDirFlag,
+				utils.ExtraKeyStoreDirFlag,
 				utils.LightKDFFlag,
+				utils.ScryptNFlag,
+				utils.ScryptPFlag,
+				utils.PassphraseCacheFlag,
+				utils.AccountRotateOldPasswordFlag,
+				utils.AccountRotateNewPasswordFlag,
 			},
 			Description: `
     klay account update <address>
@@ -115,13 +223,72 @@ for a passphrase to unlock the account and another to save the updated file.
 This same command can therefore be used to migrate an account of a deprecated
 format to the newest format or change the password for an account.
 
-For non-interactive use the passphrase can be specified with the --password flag:
+For non-interactive use the current and the new passphrases can be given by
+the first lines of the --old-password and --new-password files:
+
+    klay account update --old-password <file> --new-password <file> <address>
 
-    klay account update [options] <address>
+Give the same file to both flags to update the format only. Without the flags,
+you are prompted for the passphrases.
 
-Since only one password can be given, only format update can be performed,
-changing your password is only possible interactively.
+When several accounts are given, the --passphrase.cache flag lets a passphrase
+entered for an account be reused for the same account later in the command, so
+that you are prompted only once. The passphrases are kept only in memory and are
+cleared when the command exits.
 `,
+		},
+		{
+			Name:   "rotate",
+			Usage:  "Re-encrypt all accounts with a new password",
+			Action: utils.MigrateFlags(accountRotate),
+			Flags: []cli.Flag{
+				utils.DataDirFlag,
+				utils.KeyStoreDirFlag,
+				utils.ExtraKeyStoreDirFlag,
+				utils.LightKDFFlag,
+				utils.ScryptNFlag,
+				utils.ScryptPFlag,
+				utils.AccountRotateOldPasswordFlag,
+				utils.AccountRotateNewPasswordFlag,
+				utils.AccountRotateYesFlag,
+			},
+			Description: `
+    klay account rotate --old-password <file> --new-password <file> [--yes]
+
+Re-encrypts every account in the keystores with a new password. Each account is
+unlocked with the current password of the first line of the --old-password file
+and saved in the newest version encrypted with the password of the first line of
+the --new-password file.
+
+The result of each account is printed. An account which cannot be re-encrypted,
+e.g. having another password, is skipped and the command goes on with the next
+one, exiting with a non-zero code at the end.
+
+You are asked for confirmation before any change unless --yes is given.`,
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete the key file of an existing account",
+			Action:    utils.MigrateFlags(accountDelete),
+			ArgsUsage: "<address>",
+			Flags: []cli.Flag{
+				utils.DataDirFlag,
+				utils.KeyStoreDirFlag,
+				utils.ExtraKeyStoreDirFlag,
+				utils.PasswordFileFlag,
+				utils.AccountDeleteForceFlag,
+			},
+			Description: `
+    klay account delete <address> [--force]
+
+Deletes the key file of the given address from the keystore. The account is
+unlocked first to prove the ownership, so you are prompted for its passphrase,
+or it is read from the --password file.
+
+The deleted key cannot be recovered. Make sure you have a backup of the key file
+if the account may be used later.
+
+You are asked for confirmation before the deletion unless --force is given.`,
 		},
 		{
 			Name:   "import",
@@ -132,6 +299,9 @@ changing your password is only possible interactively.
 				utils.KeyStoreDirFlag,
 				utils.PasswordFileFlag,
 				utils.LightKDFFlag,
+				utils.ScryptNFlag,
+				utils.ScryptPFlag,
+				utils.AccountImportDryRunFlag,
 			},
 			ArgsUsage: "<keyFile>",
 			Description: `
@@ -150,10 +320,69 @@ For non-interactive use the passphrase can be specified with the -password flag:
 
     klay account import [options] <keyfile>
 
+To validate key files before importing them, use the --dry-run flag. It loads
+each given key file and prints the derived address without writing anything to
+the keystore. It exits with a non-zero code if any of the key files is invalid:
+
+    klay account import --dry-run <keyfile> [<keyfile>...]
+
 Note:
 As you can directly copy your encrypted accounts to another klay instance,
 this import mechanism is not needed when you transfer an account between
 nodes.
+`,
+		},
+		{
+			Name:   "import-mnemonic",
+			Usage:  "Recover an account from a BIP-39 mnemonic phrase",
+			Action: utils.MigrateFlags(accountImportMnemonic),
+			Flags: []cli.Flag{
+				utils.DataDirFlag,
+				utils.KeyStoreDirFlag,
+				utils.PasswordFileFlag,
+				utils.LightKDFFlag,
+				utils.ScryptNFlag,
+				utils.ScryptPFlag,
+			},
+			ArgsUsage: "[<phraseFile>]",
+			Description: `
+    klay account import-mnemonic [<phrasefile>]
+
+Derives the key at m/44'/8217'/0'/0/0 from a BIP-39 mnemonic phrase, as created
+by "klay account new --mnemonic", and stores it as a new account. Prints the address.
+
+The phrase is read from <phrasefile> if given, otherwise you are prompted for it.
+The words and the checksum of the phrase are verified before anything is stored.
+
+The account is saved in encrypted format, you are prompted for a passphrase.
+`,
+		},
+		{
+			Name:   "import-bulk",
+			Usage:  "Import all private keys in a directory into new accounts",
+			Action: utils.MigrateFlags(accountImportBulk),
+			Flags: []cli.Flag{
+				utils.DataDirFlag,
+				utils.KeyStoreDirFlag,
+				utils.PasswordFileFlag,
+				utils.LightKDFFlag,
+				utils.ScryptNFlag,
+				utils.ScryptPFlag,
+			},
+			ArgsUsage: "<keyDir>",
+			Description: `
+    klay account import-bulk <keydir>
+
+Imports every file in <keydir> as an unencrypted private key in hexadecimal
+format, and creates a new account of each key. Subdirectories and hidden files
+are skipped.
+
+All the accounts are locked with the same passphrase, which you are prompted for
+once, or which is read from the --password file.
+
+The result of each key file is printed. A key file which cannot be imported, e.g.
+being malformed, is reported and the command goes on with the next one, exiting
+with a non-zero code at the end.
 `,
 		},
 	},
@@ -174,23 +403,210 @@ func accountList(ctx *cli.Context) error {
 	return nil
 }
 
-// tries unlocking the specified account a few times.
+func accountHas(ctx *cli.Context) error {
+	if glogger, err := debug.GetGlogger(); err == nil {
+		log.ChangeGlobalLogLevel(glogger, log.Lvl(log.LvlError))
+	}
+	if len(ctx.Args()) != 1 {
+		return accountError(accountExitInvalidArgs, "An address must be given as argument")
+	}
+	address := ctx.Args().First()
+	if !common.IsHexAddress(address) {
+		return accountError(accountExitInvalidArgs, "Invalid account address %q", address)
+	}
+	addr := common.HexToAddress(address)
+
+	stack, _ := makeConfigNode(ctx)
+	found := 0
+	for _, ks := range keystores(stack.AccountManager()) {
+		for _, account := range ks.Accounts() {
+			if account.Address == addr {
+				fmt.Printf("Account {%x}: %s\n", account.Address, account.URL.Path)
+				found++
+			}
+		}
+	}
+	if found == 0 {
+		return accountError(accountExitNoSuchAccount, "No key file of account %x", addr)
+	}
+	return nil
+}
+
+func accountCheck(ctx *cli.Context) error {
+	if glogger, err := debug.GetGlogger(); err == nil {
+		log.ChangeGlobalLogLevel(glogger, log.Lvl(log.LvlError))
+	}
+	if !ctx.GlobalIsSet(utils.UnlockedAccountFlag.Name) {
+		return accountError(accountExitInvalidArgs, "No accounts to check. Use the --%s flag", utils.UnlockedAccountFlag.Name)
+	}
+
+	stack, _ := makeConfigNode(ctx)
+	// the node unlocks accounts only in the primary keystore
+	ks := keystores(stack.AccountManager())[:1]
+
+	var (
+		passwords = utils.MakePasswordList(ctx)
+		unlocks   = strings.Split(ctx.GlobalString(utils.UnlockedAccountFlag.Name), ",")
+		checked   = 0
+		failed    = 0
+		firstErr  error
+	)
+	for i, account := range unlocks {
+		trimmed := strings.TrimSpace(account)
+		if trimmed == "" {
+			continue
+		}
+		checked++
+		// i is the index of the password, as the node does
+		a, _, _, err := unlockAccount(ks, trimmed, i, passwords)
+		if err != nil {
+			fmt.Printf("Account #%d %s: failed: %v\n", i, trimmed, err)
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed++
+			continue
+		}
+		fmt.Printf("Account #%d %s: unlocked {%x}\n", i, trimmed, a.Address)
+	}
+
+	if failed > 0 {
+		code := accountExitFailure
+		if coder, ok := firstErr.(cli.ExitCoder); ok {
+			code = coder.ExitCode()
+		}
+		return accountError(code, "%d of %d accounts cannot be unlocked", failed, checked)
+	}
+	return nil
+}
+
+// accountManifestEntry is an entry of the account manifest.
+type accountManifestEntry struct {
+	Address common.Address `json:"address"`
+	URL     string         `json:"url"`
+	Path    string         `json:"path,omitempty"`    // empty if the account has no key file
+	Created string         `json:"created,omitempty"` // modification time of the key file in RFC 3339
+}
+
+func accountManifest(ctx *cli.Context) error {
+	if glogger, err := debug.GetGlogger(); err == nil {
+		log.ChangeGlobalLogLevel(glogger, log.Lvl(log.LvlError))
+	}
+	stack, _ := makeConfigNode(ctx)
+
+	manifest := make([]accountManifestEntry, 0)
+	for _, wallet := range stack.AccountManager().Wallets() {
+		for _, account := range wallet.Accounts() {
+			entry := accountManifestEntry{Address: account.Address, URL: account.URL.String()}
+			if account.URL.Scheme == keystore.KeyStoreScheme {
+				entry.Path = account.URL.Path
+				if info, err := os.Stat(entry.Path); err == nil {
+					entry.Created = info.ModTime().UTC().Format(time.RFC3339)
+				}
+			}
+			manifest = append(manifest, entry)
+		}
+	}
+
+	out, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return accountError(accountExitFailure, "Failed to encode the account manifest: %v", err)
+	}
+	output := ctx.String(utils.AccountManifestOutputFlag.Name)
+	if output == "" {
+		fmt.Println(string(out))
+		return nil
+	}
+	if err := ioutil.WriteFile(output, append(out, '\n'), 0o644); err != nil {
+		return accountError(accountExitFailure, "Failed to write the account manifest: %v", err)
+	}
+	return nil
+}
+
+// Exit codes of the account subcommands. Keep them in sync with the
+// description of AccountCommand; scripts depend on these values.
+const (
+	accountExitFailure       = 1
+	accountExitInvalidArgs   = 2
+	accountExitKeystore      = 3
+	accountExitNoSuchAccount = 4
+	accountExitBadPassword   = 5
+	accountExitPassphrase    = 6
+)
+
+// accountError returns an error which makes an account subcommand exit with the given code.
+func accountError(code int, format string, args ...interface{}) error {
+	return cli.NewExitError(fmt.Sprintf(format, args...), code)
+}
+
+// keystoreExitCode classifies an error returned by the keystore into an account exit code.
+func keystoreExitCode(err error) int {
+	switch err {
+	case keystore.ErrNoMatch, accounts.ErrUnknownAccount:
+		return accountExitNoSuchAccount
+	case keystore.ErrDecrypt:
+		return accountExitBadPassword
+	}
+	return accountExitKeystore
+}
+
+// keystores returns all keystore backends registered in the account manager.
+// The first one is the primary keystore where new keys are stored.
+func keystores(am *accounts.Manager) []*keystore.KeyStore {
+	backends := am.Backends(keystore.KeyStoreType)
+	kss := make([]*keystore.KeyStore, len(backends))
+	for i, backend := range backends {
+		kss[i] = backend.(*keystore.KeyStore)
+	}
+	return kss
+}
+
+// UnlockAccount tries unlocking the specified account a few times.
+// It terminates the process if the account cannot be unlocked.
 func UnlockAccount(ctx *cli.Context, ks *keystore.KeyStore, address string, i int, passwords []string) (accounts.Account, string) {
-	account, err := utils.MakeAddress(ks, address)
+	account, password, _, err := unlockAccount([]*keystore.KeyStore{ks}, address, i, passwords)
 	if err != nil {
-		log.Fatalf("Could not list accounts: %v", err)
+		log.Fatalf("%v", err)
+	}
+	return account, password
+}
+
+// unlockAccount tries unlocking the specified account a few times, searching all the given keystores.
+// An index is resolved in the first keystore. It returns the keystore holding the unlocked account.
+// The returned error carries the exit code of the failure class.
+func unlockAccount(kss []*keystore.KeyStore, address string, i int, passwords []string) (accounts.Account, string, *keystore.KeyStore, error) {
+	account, err := utils.MakeAddress(kss[0], address)
+	if err != nil {
+		return accounts.Account{}, "", nil, accountError(accountExitInvalidArgs, "Could not list accounts: %v", err)
+	}
+	// Try the passphrase entered before, if any
+	if password, ok := sessionPassphrases.get(account.Address); ok {
+		if ks, err := unlockInKeystores(kss, account, password); err == nil {
+			logger.Info("Unlocked account", "address", account.Address.Hex())
+			return account, password, ks, nil
+		}
+		sessionPassphrases.remove(account.Address)
 	}
 	for trials := 0; trials < 3; trials++ {
 		prompt := fmt.Sprintf("Unlocking account %s | Attempt %d/%d", address, trials+1, 3)
-		password := getPassPhrase(prompt, false, i, passwords)
-		err = ks.Unlock(account, password)
+		password, perr := getPassPhrase(prompt, false, i, passwords)
+		if perr != nil {
+			return accounts.Account{}, "", nil, perr
+		}
+		var ks *keystore.KeyStore
+		ks, err = unlockInKeystores(kss, account, password)
 		if err == nil {
 			logger.Info("Unlocked account", "address", account.Address.Hex())
-			return account, password
+			sessionPassphrases.put(account.Address, password)
+			return account, password, ks, nil
 		}
 		if err, ok := err.(*keystore.AmbiguousAddrError); ok {
+			match, ks, rerr := ambiguousAddrRecovery(kss, err, password)
+			if rerr != nil {
+				return accounts.Account{}, "", nil, rerr
+			}
 			logger.Info("Unlocked account", "address", account.Address.Hex())
-			return ambiguousAddrRecovery(ks, err, password), password
+			return match, password, ks, nil
 		}
 		if err != keystore.ErrDecrypt {
 			// No need to prompt again if the error is not decryption-related.
@@ -198,20 +614,92 @@ func UnlockAccount(ctx *cli.Context, ks *keystore.KeyStore, address string, i in
 		}
 	}
 	// All trials expended to unlock account, bail out
-	log.Fatalf("Failed to unlock account %s (%v)", address, err)
+	return accounts.Account{}, "", nil, accountError(keystoreExitCode(err), "Failed to unlock account %s (%v)", address, err)
+}
 
-	return accounts.Account{}, ""
+// passphraseCache keeps passphrases entered for accounts during a command, so that
+// the user is prompted only once per account. It lives only in memory and must be
+// cleared when the command exits. It stores nothing unless it is enabled.
+type passphraseCache struct {
+	mu          sync.Mutex
+	enabled     bool
+	passphrases map[common.Address]string
+}
+
+// sessionPassphrases is the passphrase cache of the running command.
+var sessionPassphrases = &passphraseCache{}
+
+// enable enables or disables the cache. Disabling it clears the cache.
+func (c *passphraseCache) enable(enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enabled = enabled
+	if !enabled {
+		c.passphrases = nil
+	}
+}
+
+func (c *passphraseCache) get(addr common.Address) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	passphrase, ok := c.passphrases[addr]
+	return passphrase, ok
+}
+
+func (c *passphraseCache) put(addr common.Address, passphrase string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.enabled {
+		return
+	}
+	if c.passphrases == nil {
+		c.passphrases = make(map[common.Address]string)
+	}
+	c.passphrases[addr] = passphrase
+}
+
+func (c *passphraseCache) remove(addr common.Address) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.passphrases, addr)
+}
+
+// unlockInKeystores unlocks the account in the keystore holding it. If key files of
+// the account exist in more than one place, even across keystores, it returns
+// a *keystore.AmbiguousAddrError listing all of them.
+func unlockInKeystores(kss []*keystore.KeyStore, account accounts.Account, password string) (*keystore.KeyStore, error) {
+	var (
+		holder  *keystore.KeyStore
+		matches []accounts.Account
+	)
+	for _, ks := range kss {
+		a, err := ks.Find(account)
+		switch err := err.(type) {
+		case nil:
+			holder = ks
+			matches = append(matches, a)
+		case *keystore.AmbiguousAddrError:
+			matches = append(matches, err.Matches...)
+		}
+	}
+	switch {
+	case len(matches) == 0:
+		return nil, keystore.ErrNoMatch
+	case len(matches) > 1:
+		return nil, &keystore.AmbiguousAddrError{Addr: account.Address, Matches: matches}
+	}
+	return holder, holder.Unlock(matches[0], password)
 }
 
 // getPassPhrase retrieves the password associated with an account, either fetched
 // from a list of preloaded passphrases, or requested interactively from the user.
-func getPassPhrase(prompt string, confirmation bool, i int, passwords []string) string {
+func getPassPhrase(prompt string, confirmation bool, i int, passwords []string) (string, error) {
 	// If a list of passwords was supplied, retrieve from them
 	if len(passwords) > 0 {
 		if i < len(passwords) {
-			return passwords[i]
+			return passwords[i], nil
 		}
-		return passwords[len(passwords)-1]
+		return passwords[len(passwords)-1], nil
 	}
 	// Otherwise prompt the user for the password
 	if prompt != "" {
@@ -219,35 +707,44 @@ func getPassPhrase(prompt string, confirmation bool, i int, passwords []string)
 	}
 	password, err := console.Stdin.PromptPassword("Passphrase: ")
 	if err != nil {
-		log.Fatalf("Failed to read passphrase: %v", err)
+		return "", accountError(accountExitPassphrase, "Failed to read passphrase: %v", err)
 	}
 	if confirmation {
 		confirm, err := console.Stdin.PromptPassword("Repeat passphrase: ")
 		if err != nil {
-			log.Fatalf("Failed to read passphrase confirmation: %v", err)
+			return "", accountError(accountExitPassphrase, "Failed to read passphrase confirmation: %v", err)
 		}
 		if password != confirm {
-			log.Fatalf("Passphrases do not match")
+			return "", accountError(accountExitPassphrase, "Passphrases do not match")
 		}
 	}
-	return password
+	return password, nil
 }
 
-func ambiguousAddrRecovery(ks *keystore.KeyStore, err *keystore.AmbiguousAddrError, auth string) accounts.Account {
+func ambiguousAddrRecovery(kss []*keystore.KeyStore, err *keystore.AmbiguousAddrError, auth string) (accounts.Account, *keystore.KeyStore, error) {
 	fmt.Printf("Multiple key files exist for address %x:\n", err.Addr)
 	for _, a := range err.Matches {
 		fmt.Println("  ", a.URL)
 	}
 	fmt.Println("Testing your passphrase against all of them...")
-	var match *accounts.Account
+	var (
+		match  *accounts.Account
+		holder *keystore.KeyStore
+	)
 	for _, a := range err.Matches {
-		if err := ks.Unlock(a, auth); err == nil {
-			match = &a
+		// Only the keystore holding the key file can unlock an account with URL
+		for _, ks := range kss {
+			if err := ks.Unlock(a, auth); err == nil {
+				match, holder = &a, ks
+				break
+			}
+		}
+		if match != nil {
 			break
 		}
 	}
 	if match == nil {
-		log.Fatalf("None of the listed files could be unlocked.")
+		return accounts.Account{}, nil, accountError(accountExitBadPassword, "None of the listed files could be unlocked.")
 	}
 	fmt.Printf("Your passphrase unlocked %s\n", match.URL)
 	fmt.Println("In order to avoid this warning, you need to remove the following duplicate key files:")
@@ -256,37 +753,97 @@ func ambiguousAddrRecovery(ks *keystore.KeyStore, err *keystore.AmbiguousAddrErr
 			fmt.Println("  ", a.URL)
 		}
 	}
-	return *match
+	return *match, holder, nil
 }
 
-// accountCreate creates a new account into the keystore defined by the CLI flags.
+// maxAccountNewCount is the maximum number of accounts created by a run of account new.
+const maxAccountNewCount = 1000
+
+// accountCreate creates new accounts into the keystore defined by the CLI flags.
 func accountCreate(ctx *cli.Context) error {
 	if glogger, err := debug.GetGlogger(); err == nil {
 		log.ChangeGlobalLogLevel(glogger, log.Lvl(log.LvlError))
 	}
+	count := ctx.Int(utils.AccountNewCountFlag.Name)
+	if count < 1 || count > maxAccountNewCount {
+		return accountError(accountExitInvalidArgs, "The number of accounts should be between 1 and %d: %d", maxAccountNewCount, count)
+	}
+	useMnemonic := ctx.Bool(utils.AccountNewMnemonicFlag.Name)
+	words := ctx.Int(utils.AccountMnemonicWordsFlag.Name)
+	if useMnemonic && count != 1 {
+		return accountError(accountExitInvalidArgs, "--%s cannot be used with --%s", utils.AccountNewMnemonicFlag.Name, utils.AccountNewCountFlag.Name)
+	}
+	if useMnemonic && words != 12 && words != 24 {
+		return accountError(accountExitInvalidArgs, "The mnemonic phrase should have 12 or 24 words: %d", words)
+	}
 	cfg := klayConfig{Node: defaultNodeConfig()}
 	// Load config file.
 	if file := ctx.GlobalString(utils.ConfigFileFlag.Name); file != "" {
 		if err := loadConfig(file, &cfg); err != nil {
-			log.Fatalf("%v", err)
+			return accountError(accountExitKeystore, "%v", err)
 		}
 	}
 	utils.SetNodeConfig(ctx, &cfg.Node)
 	scryptN, scryptP, keydir, err := cfg.Node.AccountConfig()
 	if err != nil {
-		log.Fatalf("Failed to read configuration: %v", err)
+		return accountError(accountExitKeystore, "Failed to read configuration: %v", err)
 	}
 
-	password := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+	password, err := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+	if err != nil {
+		return err
+	}
+
+	if useMnemonic {
+		return accountCreateMnemonic(keystore.NewKeyStore(keydir, scryptN, scryptP), password, words)
+	}
 
-	address, err := keystore.StoreKey(keydir, password, scryptN, scryptP)
+	// each key is generated independently
+	for i := 0; i < count; i++ {
+		address, err := keystore.StoreKey(keydir, password, scryptN, scryptP)
+		if err != nil {
+			return accountError(accountExitKeystore, "Failed to create account: %v", err)
+		}
+		fmt.Printf("Address: {%x}\n", address)
+	}
+	return nil
+}
+
+// accountCreateMnemonic creates an account from a new mnemonic phrase of the given
+// number of words, and prints the phrase once.
+func accountCreateMnemonic(ks *keystore.KeyStore, password string, words int) error {
+	entropy, err := mnemonic.NewEntropy(words / 3 * 32)
+	if err != nil {
+		return accountError(accountExitFailure, "Failed to generate the mnemonic: %v", err)
+	}
+	phrase, err := mnemonic.NewMnemonic(entropy)
+	if err != nil {
+		return accountError(accountExitFailure, "Failed to generate the mnemonic: %v", err)
+	}
+	key, err := mnemonicKey(phrase)
 	if err != nil {
-		log.Fatalf("Failed to create account: %v", err)
+		return accountError(accountExitFailure, "Failed to derive the key: %v", err)
 	}
-	fmt.Printf("Address: {%x}\n", address)
+	acct, err := ks.ImportECDSA(key, password)
+	if err != nil {
+		return accountError(accountExitKeystore, "Failed to create account: %v", err)
+	}
+	fmt.Printf("Address: {%x}\n", acct.Address)
+	fmt.Printf("Mnemonic: %s\n", phrase)
+	fmt.Println("WARNING: The mnemonic is shown only this time. Write it down and keep it secret;")
+	fmt.Println("anyone who knows it controls the account.")
 	return nil
 }
 
+// mnemonicKey derives the key of the first account from a mnemonic phrase.
+func mnemonicKey(phrase string) (*ecdsa.PrivateKey, error) {
+	seed, err := mnemonic.NewSeed(phrase, "")
+	if err != nil {
+		return nil, err
+	}
+	return mnemonic.DeriveKey(seed, accounts.DefaultBaseDerivationPath)
+}
+
 // accountUpdate transitions an account from a previous format to the current
 // one, also providing the possibility to change the pass-phrase.
 func accountUpdate(ctx *cli.Context) error {
@@ -294,40 +851,190 @@ func accountUpdate(ctx *cli.Context) error {
 		log.ChangeGlobalLogLevel(glogger, log.Lvl(log.LvlError))
 	}
 	if len(ctx.Args()) == 0 {
-		log.Fatalf("No accounts specified to update")
+		return accountError(accountExitInvalidArgs, "No accounts specified to update")
+	}
+	// the passphrases are read from files for non-interactive use
+	var oldPasswords, newPasswords []string
+	oldFile, newFile := ctx.String(utils.AccountRotateOldPasswordFlag.Name), ctx.String(utils.AccountRotateNewPasswordFlag.Name)
+	if oldFile != "" || newFile != "" {
+		if oldFile == "" || newFile == "" {
+			return accountError(accountExitInvalidArgs, "Both --%s and --%s should be given",
+				utils.AccountRotateOldPasswordFlag.Name, utils.AccountRotateNewPasswordFlag.Name)
+		}
+		oldPassword, err := readPasswordFile(oldFile)
+		if err != nil {
+			return err
+		}
+		newPassword, err := readPasswordFile(newFile)
+		if err != nil {
+			return err
+		}
+		oldPasswords, newPasswords = []string{oldPassword}, []string{newPassword}
 	}
+
 	stack, _ := makeConfigNode(ctx)
-	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
+	kss := keystores(stack.AccountManager())
+
+	sessionPassphrases.enable(ctx.Bool(utils.PassphraseCacheFlag.Name))
+	defer sessionPassphrases.enable(false)
 
 	for _, addr := range ctx.Args() {
-		account, oldPassword := UnlockAccount(ctx, ks, addr, 0, nil)
-		newPassword := getPassPhrase("Please give a new password. Do not forget this password.", true, 0, nil)
+		account, oldPassword, ks, err := unlockAccount(kss, addr, 0, oldPasswords)
+		if err != nil {
+			return err
+		}
+		newPassword, err := getPassPhrase("Please give a new password. Do not forget this password.", true, 0, newPasswords)
+		if err != nil {
+			return err
+		}
 		if err := ks.Update(account, oldPassword, newPassword); err != nil {
-			log.Fatalf("Could not update the account: %v", err)
+			return accountError(keystoreExitCode(err), "Could not update the account: %v", err)
 		}
+		sessionPassphrases.put(account.Address, newPassword)
 	}
 	return nil
 }
 
+// readPasswordFile returns the password of the first line of the given file.
+func readPasswordFile(path string) (string, error) {
+	text, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", accountError(accountExitPassphrase, "Failed to read password file: %v", err)
+	}
+	return strings.TrimRight(strings.SplitN(string(text), "\n", 2)[0], "\r"), nil
+}
+
+func accountRotate(ctx *cli.Context) error {
+	if glogger, err := debug.GetGlogger(); err == nil {
+		log.ChangeGlobalLogLevel(glogger, log.Lvl(log.LvlError))
+	}
+	oldFile, newFile := ctx.String(utils.AccountRotateOldPasswordFlag.Name), ctx.String(utils.AccountRotateNewPasswordFlag.Name)
+	if oldFile == "" || newFile == "" {
+		return accountError(accountExitInvalidArgs, "Both --%s and --%s should be given",
+			utils.AccountRotateOldPasswordFlag.Name, utils.AccountRotateNewPasswordFlag.Name)
+	}
+	oldPassword, err := readPasswordFile(oldFile)
+	if err != nil {
+		return err
+	}
+	newPassword, err := readPasswordFile(newFile)
+	if err != nil {
+		return err
+	}
+
+	stack, _ := makeConfigNode(ctx)
+	kss := keystores(stack.AccountManager())
+
+	total := 0
+	for _, ks := range kss {
+		total += len(ks.Accounts())
+	}
+	if total == 0 {
+		return accountError(accountExitNoSuchAccount, "No accounts to rotate")
+	}
+	if !ctx.Bool(utils.AccountRotateYesFlag.Name) {
+		confirmed, err := console.Stdin.PromptConfirm(fmt.Sprintf("Re-encrypt %d accounts with the new password?", total))
+		if err != nil {
+			return accountError(accountExitPassphrase, "Failed to read confirmation: %v", err)
+		}
+		if !confirmed {
+			return accountError(accountExitFailure, "Aborted")
+		}
+	}
+
+	var (
+		index    = 0
+		failed   = 0
+		firstErr error
+	)
+	for _, ks := range kss {
+		for _, account := range ks.Accounts() {
+			if err := ks.Update(account, oldPassword, newPassword); err != nil {
+				fmt.Printf("Account #%d: {%x} failed: %v\n", index, account.Address, err)
+				if firstErr == nil {
+					firstErr = err
+				}
+				failed++
+			} else {
+				fmt.Printf("Account #%d: {%x} rotated\n", index, account.Address)
+			}
+			index++
+		}
+	}
+
+	if failed > 0 {
+		return accountError(keystoreExitCode(firstErr), "%d of %d accounts could not be rotated", failed, total)
+	}
+	return nil
+}
+
+// accountDelete deletes the key file of an account after unlocking it.
+func accountDelete(ctx *cli.Context) error {
+	if glogger, err := debug.GetGlogger(); err == nil {
+		log.ChangeGlobalLogLevel(glogger, log.Lvl(log.LvlError))
+	}
+	if len(ctx.Args()) != 1 {
+		return accountError(accountExitInvalidArgs, "An address must be given as argument")
+	}
+	address := ctx.Args().First()
+	if !common.IsHexAddress(address) {
+		return accountError(accountExitInvalidArgs, "Invalid account address %q", address)
+	}
+
+	stack, _ := makeConfigNode(ctx)
+	kss := keystores(stack.AccountManager())
+
+	account, password, ks, err := unlockAccount(kss, address, 0, utils.MakePasswordList(ctx))
+	if err != nil {
+		return err
+	}
+	// the unlocked account carries the URL of its key file
+	if found, err := ks.Find(account); err == nil {
+		account = found
+	}
+	if !ctx.Bool(utils.AccountDeleteForceFlag.Name) {
+		confirmed, err := console.Stdin.PromptConfirm(fmt.Sprintf("Delete the key file %s?", account.URL.Path))
+		if err != nil {
+			return accountError(accountExitPassphrase, "Failed to read confirmation: %v", err)
+		}
+		if !confirmed {
+			return accountError(accountExitFailure, "Aborted")
+		}
+	}
+
+	// the keystore removes the file and refreshes its wallets
+	if err := ks.Delete(account, password); err != nil {
+		return accountError(keystoreExitCode(err), "Could not delete the account: %v", err)
+	}
+	fmt.Printf("Account {%x} deleted\n", account.Address)
+	return nil
+}
+
 func accountImport(ctx *cli.Context) error {
 	if glogger, err := debug.GetGlogger(); err == nil {
 		log.ChangeGlobalLogLevel(glogger, log.Lvl(log.LvlError))
 	}
 	keyfile := ctx.Args().First()
 	if len(keyfile) == 0 {
-		log.Fatalf("keyfile must be given as argument")
+		return accountError(accountExitInvalidArgs, "keyfile must be given as argument")
+	}
+	if ctx.Bool(utils.AccountImportDryRunFlag.Name) {
+		return accountImportDryRun(ctx.Args())
 	}
 	key, err := crypto.LoadECDSA(keyfile)
 	if err != nil {
-		log.Fatalf("Failed to load the private key: %v", err)
+		return accountError(accountExitInvalidArgs, "Failed to load the private key: %v", err)
 	}
 	stack, _ := makeConfigNode(ctx)
-	passphrase := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+	passphrase, err := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+	if err != nil {
+		return err
+	}
 
 	ks := stack.AccountManager().Backends(keystore.KeyStoreType)[0].(*keystore.KeyStore)
 	acct, err := ks.ImportECDSA(key, passphrase)
 	if err != nil {
-		log.Fatalf("Could not create the account: %v", err)
+		return accountError(accountExitKeystore, "Could not create the account: %v", err)
 	}
 	fmt.Printf("Address: {%x}\n", acct.Address)
 	if _acct, err := ks.Find(acct); err == nil {
@@ -335,3 +1042,120 @@ func accountImport(ctx *cli.Context) error {
 	}
 	return nil
 }
+
+// accountImportMnemonic recovers an account from a mnemonic phrase.
+func accountImportMnemonic(ctx *cli.Context) error {
+	if glogger, err := debug.GetGlogger(); err == nil {
+		log.ChangeGlobalLogLevel(glogger, log.Lvl(log.LvlError))
+	}
+	var (
+		phrase string
+		err    error
+	)
+	if file := ctx.Args().First(); file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return accountError(accountExitInvalidArgs, "Failed to read the mnemonic file: %v", err)
+		}
+		phrase = string(data)
+	} else if phrase, err = console.Stdin.PromptPassword("Mnemonic: "); err != nil {
+		return accountError(accountExitInvalidArgs, "Failed to read the mnemonic: %v", err)
+	}
+	key, err := mnemonicKey(phrase)
+	if err != nil {
+		return accountError(accountExitInvalidArgs, "Invalid mnemonic: %v", err)
+	}
+	stack, _ := makeConfigNode(ctx)
+	passphrase, err := getPassPhrase("Your new account is locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+	if err != nil {
+		return err
+	}
+	acct, err := keystores(stack.AccountManager())[0].ImportECDSA(key, passphrase)
+	if err != nil {
+		return accountError(accountExitKeystore, "Could not create the account: %v", err)
+	}
+	fmt.Printf("Address: {%x}\n", acct.Address)
+	return nil
+}
+
+// accountImportBulk imports all the key files in a directory. A failure of a key file
+// does not stop importing the others.
+func accountImportBulk(ctx *cli.Context) error {
+	if glogger, err := debug.GetGlogger(); err == nil {
+		log.ChangeGlobalLogLevel(glogger, log.Lvl(log.LvlError))
+	}
+	if len(ctx.Args()) != 1 {
+		return accountError(accountExitInvalidArgs, "A key directory must be given as argument")
+	}
+	keydir := ctx.Args().First()
+	entries, err := ioutil.ReadDir(keydir)
+	if err != nil {
+		return accountError(accountExitInvalidArgs, "Failed to read the key directory: %v", err)
+	}
+	var keyfiles []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		keyfiles = append(keyfiles, filepath.Join(keydir, entry.Name()))
+	}
+	if len(keyfiles) == 0 {
+		return accountError(accountExitInvalidArgs, "No key files in %s", keydir)
+	}
+
+	stack, _ := makeConfigNode(ctx)
+	passphrase, err := getPassPhrase("Your new accounts are locked with a password. Please give a password. Do not forget this password.", true, 0, utils.MakePasswordList(ctx))
+	if err != nil {
+		return err
+	}
+	ks := keystores(stack.AccountManager())[0]
+
+	var (
+		failed   = 0
+		failCode = accountExitFailure
+	)
+	for _, keyfile := range keyfiles {
+		key, err := crypto.LoadECDSA(keyfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid key file %s: %v\n", keyfile, err)
+			if failed == 0 {
+				failCode = accountExitInvalidArgs
+			}
+			failed++
+			continue
+		}
+		acct, err := ks.ImportECDSA(key, passphrase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not import key file %s: %v\n", keyfile, err)
+			if failed == 0 {
+				failCode = accountExitKeystore
+			}
+			failed++
+			continue
+		}
+		fmt.Printf("Key file %s: {%x} imported\n", keyfile, acct.Address)
+	}
+	if failed > 0 {
+		return accountError(failCode, "%d of %d key files could not be imported", failed, len(keyfiles))
+	}
+	return nil
+}
+
+// accountImportDryRun loads the given key files and prints the derived addresses
+// without importing them. It returns an error if any of the key files is invalid.
+func accountImportDryRun(keyfiles []string) error {
+	failed := 0
+	for _, keyfile := range keyfiles {
+		key, err := crypto.LoadECDSA(keyfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid key file %s: %v\n", keyfile, err)
+			failed++
+			continue
+		}
+		fmt.Printf("Key file %s: {%x}\n", keyfile, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	if failed > 0 {
+		return accountError(accountExitInvalidArgs, "%d of %d key files are invalid", failed, len(keyfiles))
+	}
+	return nil
+}
@@ -21,12 +21,18 @@
 package nodecmd
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
 
 	"github.com/cespare/cp"
+	"github.com/klaytn/klaytn/common"
+	"github.com/klaytn/klaytn/crypto"
 )
 
 // These tests are 'smoke tests' for the account related
@@ -69,6 +75,59 @@ Account #2: {289d485d9771714cce91d3393d764e1311907acc} keystore://{{.Datadir}}/k
 	}
 }
 
+// tmpDatadirWithSplitKeystore returns a datadir whose keystore holds f466859e only
+// and an extra keystore directory holding 289d485d only.
+func tmpDatadirWithSplitKeystore(t *testing.T) (string, string) {
+	datadir := tmpdir(t)
+	source := filepath.Join("..", "..", "..", "accounts", "keystore", "testdata", "keystore")
+	extra := filepath.Join(datadir, "cold")
+	for _, dir := range []string{filepath.Join(datadir, "keystore"), extra} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := cp.CopyFile(filepath.Join(datadir, "keystore", "aaa"), filepath.Join(source, "aaa")); err != nil {
+		t.Fatal(err)
+	}
+	if err := cp.CopyFile(filepath.Join(extra, "zzz"), filepath.Join(source, "zzz")); err != nil {
+		t.Fatal(err)
+	}
+	return datadir, extra
+}
+
+func TestAccountListExtraKeystore(t *testing.T) {
+	datadir, extra := tmpDatadirWithSplitKeystore(t)
+	klay := runKlay(t, "klay-test", "account", "list", "--datadir", datadir, "--keystore.extra", extra)
+	defer klay.ExpectExit()
+	if runtime.GOOS == "windows" {
+		klay.Expect(`
+Account #0: {289d485d9771714cce91d3393d764e1311907acc} keystore://{{.Datadir}}\cold\zzz
+Account #1: {f466859ead1932d743d622cb74fc058882e8648a} keystore://{{.Datadir}}\keystore\aaa
+`)
+	} else {
+		klay.Expect(`
+Account #0: {289d485d9771714cce91d3393d764e1311907acc} keystore://{{.Datadir}}/cold/zzz
+Account #1: {f466859ead1932d743d622cb74fc058882e8648a} keystore://{{.Datadir}}/keystore/aaa
+`)
+	}
+}
+
+func TestAccountUpdateExtraKeystore(t *testing.T) {
+	datadir, extra := tmpDatadirWithSplitKeystore(t)
+	klay := runKlay(t, "klay-test", "account", "update",
+		"--datadir", datadir, "--keystore.extra", extra, "--lightkdf",
+		"289d485d9771714cce91d3393d764e1311907acc")
+	defer klay.ExpectExit()
+	klay.Expect(`
+Unlocking account 289d485d9771714cce91d3393d764e1311907acc | Attempt 1/3
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar"}}
+Please give a new password. Do not forget this password.
+Passphrase: {{.InputLine "foobar2"}}
+Repeat passphrase: {{.InputLine "foobar2"}}
+`)
+}
+
 func TestAccountNew(t *testing.T) {
 	klay := runKlay(t, "klay-test", "account", "new", "--lightkdf")
 	defer klay.ExpectExit()
@@ -81,6 +140,116 @@ Repeat passphrase: {{.InputLine "foobar"}}
 	klay.ExpectRegexp(`Address: \{[0-9a-f]{40}\}\n`)
 }
 
+func TestAccountNewCount(t *testing.T) {
+	datadir := tmpdir(t)
+	passwordFile := filepath.Join(datadir, "password.txt")
+	if err := ioutil.WriteFile(passwordFile, []byte("foobar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	klay := runKlay(t, "klay-test", "account", "new", "--datadir", datadir, "--lightkdf",
+		"--password", passwordFile, "--count", "5")
+	for i := 0; i < 5; i++ {
+		klay.ExpectRegexp(`Address: \{[0-9a-f]{40}\}\n`)
+	}
+	klay.ExpectExit()
+
+	files, err := ioutil.ReadDir(filepath.Join(datadir, "keystore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 5 {
+		t.Errorf("unexpected number of key files: have %d, want 5", len(files))
+	}
+}
+
+// Tests that the account created with a mnemonic is recovered from the mnemonic.
+func TestAccountNewMnemonic(t *testing.T) {
+	datadir := tmpdir(t)
+	passwordFile := filepath.Join(datadir, "password.txt")
+	if err := ioutil.WriteFile(passwordFile, []byte("foobar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	klay := runKlay(t, "klay-test", "account", "new", "--datadir", datadir, "--lightkdf",
+		"--password", passwordFile, "--mnemonic")
+	_, matches := klay.ExpectRegexp(`Address: \{([0-9a-f]{40})\}\nMnemonic: ((?:[a-z]+ ){11}[a-z]+)\nWARNING: [^\n]*\n[^\n]*controls the account\.\n`)
+	klay.ExpectExit()
+	addr, phrase := matches[1], matches[2]
+
+	phraseFile := filepath.Join(datadir, "mnemonic.txt")
+	if err := ioutil.WriteFile(phraseFile, []byte(phrase+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	klay = runKlay(t, "klay-test", "account", "import-mnemonic", "--datadir", filepath.Join(datadir, "recovered"),
+		"--lightkdf", "--password", passwordFile, phraseFile)
+	klay.Expect(fmt.Sprintf("Address: {%s}\n", addr))
+	klay.ExpectExit()
+}
+
+func TestAccountImportMnemonicInvalid(t *testing.T) {
+	datadir := tmpdir(t)
+	phraseFile := filepath.Join(datadir, "mnemonic.txt")
+	phrase := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon"
+	if err := ioutil.WriteFile(phraseFile, []byte(phrase), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	klay := runKlay(t, "klay-test", "account", "import-mnemonic", "--datadir", datadir, "--lightkdf", phraseFile)
+	klay.ExpectExit()
+	if status := klay.ExitStatus(); status != accountExitInvalidArgs {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitInvalidArgs)
+	}
+}
+
+func TestAccountNewScrypt(t *testing.T) {
+	datadir := tmpdir(t)
+	passwordFile := filepath.Join(datadir, "password.txt")
+	if err := ioutil.WriteFile(passwordFile, []byte("foobar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	klay := runKlay(t, "klay-test", "account", "new", "--datadir", datadir,
+		"--password", passwordFile, "--scrypt-n", "1024", "--scrypt-p", "2")
+	klay.ExpectRegexp(`Address: \{[0-9a-f]{40}\}\n`)
+	klay.ExpectExit()
+
+	files, err := ioutil.ReadDir(filepath.Join(datadir, "keystore"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("unexpected key files: %v, %v", files, err)
+	}
+	keyJSON, err := ioutil.ReadFile(filepath.Join(datadir, "keystore", files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var key struct {
+		Keyring [][]struct {
+			KDFParams struct {
+				N int `json:"n"`
+				P int `json:"p"`
+			} `json:"kdfparams"`
+		} `json:"keyring"`
+	}
+	if err := json.Unmarshal(keyJSON, &key); err != nil {
+		t.Fatal(err)
+	}
+	if params := key.Keyring[0][0].KDFParams; params.N != 1024 || params.P != 2 {
+		t.Errorf("unexpected scrypt params: have (%d, %d), want (1024, 2)", params.N, params.P)
+	}
+}
+
+func TestAccountNewScryptInvalid(t *testing.T) {
+	klay := runKlay(t, "klay-test", "account", "new", "--scrypt-n", "1000")
+	klay.ExpectExit()
+	if status := klay.ExitStatus(); status != accountExitKeystore {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitKeystore)
+	}
+}
+
+func TestAccountNewCountInvalid(t *testing.T) {
+	klay := runKlay(t, "klay-test", "account", "new", "--lightkdf", "--count", "0")
+	klay.ExpectExit()
+	if status := klay.ExitStatus(); status != accountExitInvalidArgs {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitInvalidArgs)
+	}
+}
+
 func TestAccountNewBadRepeat(t *testing.T) {
 	klay := runKlay(t, "klay-test", "account", "new", "--lightkdf")
 	defer klay.ExpectExit()
@@ -89,8 +258,15 @@ Your new account is locked with a password. Please give a password. Do not forge
 !! Unsupported terminal, password will be echoed.
 Passphrase: {{.InputLine "something"}}
 Repeat passphrase: {{.InputLine "something else"}}
-Fatal: Passphrases do not match
 `)
+	klay.ExpectExit()
+
+	if !strings.Contains(klay.StderrText(), "Passphrases do not match") {
+		t.Errorf("stderr text does not contain the mismatch error")
+	}
+	if status := klay.ExitStatus(); status != accountExitPassphrase {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitPassphrase)
+	}
 }
 
 func TestAccountUpdate(t *testing.T) {
@@ -109,6 +285,72 @@ Repeat passphrase: {{.InputLine "foobar2"}}
 `)
 }
 
+func TestAccountUpdatePasswordFiles(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	oldFile, newFile := filepath.Join(datadir, "old.txt"), filepath.Join(datadir, "new.txt")
+	if err := ioutil.WriteFile(oldFile, []byte("foobar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(newFile, []byte("foobar2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// no prompt is expected
+	klay := runKlay(t, "klay-test", "account", "update", "--datadir", datadir, "--lightkdf",
+		"--old-password", oldFile, "--new-password", newFile,
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	klay.ExpectExit()
+	if status := klay.ExitStatus(); status != 0 {
+		t.Fatalf("unexpected exit status: have %d, want 0", status)
+	}
+
+	// the account is unlocked with the new password
+	klay = runKlay(t, "klay-test", "account", "check", "--datadir", datadir,
+		"--password", newFile, "--unlock", "f466859ead1932d743d622cb74fc058882e8648a")
+	defer klay.ExpectExit()
+	klay.Expect(`
+Account #0 f466859ead1932d743d622cb74fc058882e8648a: unlocked {f466859ead1932d743d622cb74fc058882e8648a}
+`)
+}
+
+func TestAccountUpdateWrongPassword(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	klay := runKlay(t, "klay-test", "account", "update",
+		"--datadir", datadir, "--lightkdf",
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	klay.Expect(`
+Unlocking account f466859ead1932d743d622cb74fc058882e8648a | Attempt 1/3
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "wrong1"}}
+Unlocking account f466859ead1932d743d622cb74fc058882e8648a | Attempt 2/3
+Passphrase: {{.InputLine "wrong2"}}
+Unlocking account f466859ead1932d743d622cb74fc058882e8648a | Attempt 3/3
+Passphrase: {{.InputLine "wrong3"}}
+`)
+	klay.ExpectExit()
+
+	if status := klay.ExitStatus(); status != accountExitBadPassword {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitBadPassword)
+	}
+}
+
+func TestAccountUpdateNoSuchAccount(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	klay := runKlay(t, "klay-test", "account", "update",
+		"--datadir", datadir, "--lightkdf",
+		"0000000000000000000000000000000000000001")
+	klay.Expect(`
+Unlocking account 0000000000000000000000000000000000000001 | Attempt 1/3
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar"}}
+`)
+	klay.ExpectExit()
+
+	if status := klay.ExitStatus(); status != accountExitNoSuchAccount {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitNoSuchAccount)
+	}
+}
+
 func TestUnlockFlag(t *testing.T) {
 	datadir := tmpDatadirWithKeystore(t)
 	klay := runKlay(t, "klay-test",
@@ -272,3 +514,352 @@ Fatal: None of the listed files could be unlocked.
 `)
 	klay.ExpectExit()
 }
+
+func TestAccountImportDryRun(t *testing.T) {
+	datadir := tmpdir(t)
+	valid := filepath.Join(datadir, "valid.key")
+	invalid := filepath.Join(datadir, "invalid.key")
+	if err := ioutil.WriteFile(valid, []byte("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(invalid, []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	klay := runKlay(t, "klay-test", "account", "import", "--datadir", datadir, "--dry-run", valid, invalid)
+	klay.Expect(`
+Key file {{.Datadir}}/valid.key: {71562b71999873db5b286df957af199ec94617f7}
+`)
+	klay.ExpectExit()
+
+	if !strings.Contains(klay.StderrText(), "invalid.key") {
+		t.Errorf("stderr text does not report the invalid key file")
+	}
+	if status := klay.ExitStatus(); status != accountExitInvalidArgs {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitInvalidArgs)
+	}
+	// nothing must be written to the keystore
+	if _, err := os.Stat(filepath.Join(datadir, "keystore")); !os.IsNotExist(err) {
+		t.Errorf("keystore directory is created by a dry run")
+	}
+}
+
+func TestAccountImportBulk(t *testing.T) {
+	datadir := tmpdir(t)
+	keydir := filepath.Join(datadir, "keys")
+	if err := os.Mkdir(keydir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	var expected string
+	for _, name := range []string{"key1", "key2", "key3"} {
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := crypto.SaveECDSA(filepath.Join(keydir, name), key); err != nil {
+			t.Fatal(err)
+		}
+		expected += fmt.Sprintf("Key file %s: {%x} imported\n", filepath.Join(keydir, name), crypto.PubkeyToAddress(key.PublicKey))
+	}
+	if err := ioutil.WriteFile(filepath.Join(keydir, "bad"), []byte("not a key"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	passwordFile := filepath.Join(datadir, "password.txt")
+	if err := ioutil.WriteFile(passwordFile, []byte("foobar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	klay := runKlay(t, "klay-test", "account", "import-bulk", "--datadir", datadir, "--lightkdf",
+		"--password", passwordFile, keydir)
+	klay.Expect("\n" + expected)
+	klay.ExpectExit()
+
+	if !strings.Contains(klay.StderrText(), filepath.Join(keydir, "bad")) {
+		t.Errorf("stderr text does not report the malformed key file")
+	}
+	if status := klay.ExitStatus(); status != accountExitInvalidArgs {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitInvalidArgs)
+	}
+	files, err := ioutil.ReadDir(filepath.Join(datadir, "keystore"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 {
+		t.Errorf("unexpected number of key files: have %d, want 3", len(files))
+	}
+}
+
+func TestAccountManifest(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	output := filepath.Join(datadir, "accounts.json")
+	klay := runKlay(t, "klay-test", "account", "manifest", "--datadir", datadir, "--output", output)
+	klay.ExpectExit()
+
+	data, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest []accountManifestEntry
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"0x7ef5a6135f1fd6a02593eedc869c6d41d934aef8",
+		"0xf466859ead1932d743d622cb74fc058882e8648a",
+		"0x289d485d9771714cce91d3393d764e1311907acc",
+	}
+	if len(manifest) != len(expected) {
+		t.Fatalf("unexpected number of accounts: have %d, want %d", len(manifest), len(expected))
+	}
+	for i, entry := range manifest {
+		if have := strings.ToLower(entry.Address.Hex()); have != expected[i] {
+			t.Errorf("account #%d: address mismatch: have %s, want %s", i, have, expected[i])
+		}
+		if filepath.Dir(entry.Path) != filepath.Join(datadir, "keystore") {
+			t.Errorf("account #%d: unexpected path %s", i, entry.Path)
+		}
+		if entry.Created == "" {
+			t.Errorf("account #%d: creation time is missing", i)
+		}
+	}
+}
+
+func TestPassphraseCache(t *testing.T) {
+	var (
+		c     = &passphraseCache{}
+		addr1 = common.HexToAddress("f466859ead1932d743d622cb74fc058882e8648a")
+		addr2 = common.HexToAddress("289d485d9771714cce91d3393d764e1311907acc")
+	)
+
+	// nothing is stored unless enabled
+	c.put(addr1, "foobar")
+	if _, ok := c.get(addr1); ok {
+		t.Fatal("passphrase is cached while the cache is disabled")
+	}
+
+	c.enable(true)
+	c.put(addr1, "foobar")
+	if passphrase, ok := c.get(addr1); !ok || passphrase != "foobar" {
+		t.Fatalf("unexpected cached passphrase: have (%q, %v), want (%q, true)", passphrase, ok, "foobar")
+	}
+	// a passphrase does not leak to another address
+	if _, ok := c.get(addr2); ok {
+		t.Fatal("passphrase of another address is returned")
+	}
+
+	c.remove(addr1)
+	if _, ok := c.get(addr1); ok {
+		t.Fatal("removed passphrase is returned")
+	}
+
+	// disabling clears the cache
+	c.put(addr1, "foobar")
+	c.enable(false)
+	if _, ok := c.get(addr1); ok {
+		t.Fatal("passphrase is kept after the cache is disabled")
+	}
+}
+
+func TestAccountUpdatePassphraseCache(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	klay := runKlay(t, "klay-test", "account", "update",
+		"--datadir", datadir, "--lightkdf", "--passphrase.cache",
+		"f466859ead1932d743d622cb74fc058882e8648a",
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	defer klay.ExpectExit()
+	// the second update unlocks the account with the cached passphrase
+	klay.Expect(`
+Unlocking account f466859ead1932d743d622cb74fc058882e8648a | Attempt 1/3
+!! Unsupported terminal, password will be echoed.
+Passphrase: {{.InputLine "foobar"}}
+Please give a new password. Do not forget this password.
+Passphrase: {{.InputLine "foobar2"}}
+Repeat passphrase: {{.InputLine "foobar2"}}
+Please give a new password. Do not forget this password.
+Passphrase: {{.InputLine "foobar3"}}
+Repeat passphrase: {{.InputLine "foobar3"}}
+`)
+}
+
+func TestAccountHas(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	klay := runKlay(t, "klay-test", "account", "has", "--datadir", datadir,
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	defer klay.ExpectExit()
+	klay.Expect(`
+Account {f466859ead1932d743d622cb74fc058882e8648a}: ` + filepath.Join("{{.Datadir}}", "keystore", "aaa") + `
+`)
+}
+
+func TestAccountHasAmbiguous(t *testing.T) {
+	dir := filepath.Join("..", "..", "..", "accounts", "keystore", "testdata", "dupes")
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	klay := runKlay(t, "klay-test", "account", "has", "--keystore", dir,
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	defer klay.ExpectExit()
+	klay.Expect(`
+Account {f466859ead1932d743d622cb74fc058882e8648a}: ` + filepath.Join(abs, "1") + `
+Account {f466859ead1932d743d622cb74fc058882e8648a}: ` + filepath.Join(abs, "2") + `
+`)
+}
+
+func TestAccountHasNoSuchAccount(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	klay := runKlay(t, "klay-test", "account", "has", "--datadir", datadir,
+		"0000000000000000000000000000000000000001")
+	klay.ExpectExit()
+
+	if status := klay.ExitStatus(); status != accountExitNoSuchAccount {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitNoSuchAccount)
+	}
+}
+
+func TestAccountCheck(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	klay := runKlay(t, "klay-test", "account", "check", "--datadir", datadir,
+		"--password", "testdata/passwords.txt", "--unlock", "0,2")
+	defer klay.ExpectExit()
+	klay.Expect(`
+Account #0 0: unlocked {7ef5a6135f1fd6a02593eedc869c6d41d934aef8}
+Account #2 2: unlocked {289d485d9771714cce91d3393d764e1311907acc}
+`)
+}
+
+func TestAccountCheckPasswordIndex(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	// the password of the i-th account is the i-th line, so the second line is not used
+	passwordFile := filepath.Join(datadir, "passwords.txt")
+	if err := ioutil.WriteFile(passwordFile, []byte("foobar\nwrong\nfoobar\nwrong"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	klay := runKlay(t, "klay-test", "account", "check", "--datadir", datadir,
+		"--password", passwordFile, "--unlock", "0,,2,f466859ead1932d743d622cb74fc058882e8648a")
+	klay.Expect(`
+Account #0 0: unlocked {7ef5a6135f1fd6a02593eedc869c6d41d934aef8}
+Account #2 2: unlocked {289d485d9771714cce91d3393d764e1311907acc}
+Account #3 f466859ead1932d743d622cb74fc058882e8648a: failed: Failed to unlock account f466859ead1932d743d622cb74fc058882e8648a (could not decrypt key with given passphrase)
+`)
+	klay.ExpectExit()
+
+	if status := klay.ExitStatus(); status != accountExitBadPassword {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitBadPassword)
+	}
+}
+
+func TestAccountRotate(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	oldFile, newFile := filepath.Join(datadir, "old.txt"), filepath.Join(datadir, "new.txt")
+	if err := ioutil.WriteFile(oldFile, []byte("foobar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(newFile, []byte("foobar2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	klay := runKlay(t, "klay-test", "account", "rotate", "--datadir", datadir, "--lightkdf",
+		"--old-password", oldFile, "--new-password", newFile, "--yes")
+	klay.Expect(`
+Account #0: {7ef5a6135f1fd6a02593eedc869c6d41d934aef8} rotated
+Account #1: {f466859ead1932d743d622cb74fc058882e8648a} rotated
+Account #2: {289d485d9771714cce91d3393d764e1311907acc} rotated
+`)
+	klay.ExpectExit()
+
+	// the old password does not work anymore, and every account is tried
+	klay = runKlay(t, "klay-test", "account", "rotate", "--datadir", datadir, "--lightkdf",
+		"--old-password", oldFile, "--new-password", newFile, "--yes")
+	klay.Expect(`
+Account #0: {7ef5a6135f1fd6a02593eedc869c6d41d934aef8} failed: could not decrypt key with given passphrase
+Account #1: {f466859ead1932d743d622cb74fc058882e8648a} failed: could not decrypt key with given passphrase
+Account #2: {289d485d9771714cce91d3393d764e1311907acc} failed: could not decrypt key with given passphrase
+`)
+	klay.ExpectExit()
+	if status := klay.ExitStatus(); status != accountExitBadPassword {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitBadPassword)
+	}
+
+	// the new password works
+	klay = runKlay(t, "klay-test", "account", "rotate", "--datadir", datadir, "--lightkdf",
+		"--old-password", newFile, "--new-password", oldFile, "--yes")
+	klay.Expect(`
+Account #0: {7ef5a6135f1fd6a02593eedc869c6d41d934aef8} rotated
+Account #1: {f466859ead1932d743d622cb74fc058882e8648a} rotated
+Account #2: {289d485d9771714cce91d3393d764e1311907acc} rotated
+`)
+	klay.ExpectExit()
+}
+
+func TestAccountRotateConfirm(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	passwordFile := filepath.Join(datadir, "password.txt")
+	if err := ioutil.WriteFile(passwordFile, []byte("foobar\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	klay := runKlay(t, "klay-test", "account", "rotate", "--datadir", datadir, "--lightkdf",
+		"--old-password", passwordFile, "--new-password", passwordFile)
+	klay.Expect(`
+Re-encrypt 3 accounts with the new password? [y/N] {{.InputLine "n"}}
+`)
+	klay.ExpectExit()
+	if status := klay.ExitStatus(); status != accountExitFailure {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitFailure)
+	}
+}
+
+func TestAccountDelete(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	klay := runKlay(t, "klay-test", "account", "delete", "--datadir", datadir,
+		"--password", "testdata/passwords.txt", "--force",
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	klay.Expect(`
+Account {f466859ead1932d743d622cb74fc058882e8648a} deleted
+`)
+	klay.ExpectExit()
+
+	klay = runKlay(t, "klay-test", "account", "list", "--datadir", datadir)
+	defer klay.ExpectExit()
+	klay.Expect(`
+Account #0: {7ef5a6135f1fd6a02593eedc869c6d41d934aef8} keystore://` + filepath.Join("{{.Datadir}}", "keystore", "UTC--2016-03-22T12-57-55.920751759Z--7ef5a6135f1fd6a02593eedc869c6d41d934aef8") + `
+Account #1: {289d485d9771714cce91d3393d764e1311907acc} keystore://` + filepath.Join("{{.Datadir}}", "keystore", "zzz") + `
+`)
+}
+
+func TestAccountDeleteConfirm(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	klay := runKlay(t, "klay-test", "account", "delete", "--datadir", datadir,
+		"--password", "testdata/passwords.txt",
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	klay.Expect(`
+Delete the key file ` + filepath.Join("{{.Datadir}}", "keystore", "aaa") + `? [y/N] {{.InputLine "n"}}
+`)
+	klay.ExpectExit()
+	if status := klay.ExitStatus(); status != accountExitFailure {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitFailure)
+	}
+	if _, err := os.Stat(filepath.Join(datadir, "keystore", "aaa")); err != nil {
+		t.Errorf("the key file should not be deleted: %v", err)
+	}
+}
+
+func TestAccountDeleteWrongPassword(t *testing.T) {
+	datadir := tmpDatadirWithKeystore(t)
+	passwordFile := filepath.Join(datadir, "password.txt")
+	if err := ioutil.WriteFile(passwordFile, []byte("wrong\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	klay := runKlay(t, "klay-test", "account", "delete", "--datadir", datadir,
+		"--password", passwordFile, "--force",
+		"f466859ead1932d743d622cb74fc058882e8648a")
+	klay.ExpectExit()
+	if status := klay.ExitStatus(); status != accountExitBadPassword {
+		t.Errorf("unexpected exit status: have %d, want %d", status, accountExitBadPassword)
+	}
+	if _, err := os.Stat(filepath.Join(datadir, "keystore", "aaa")); err != nil {
+		t.Errorf("the key file should not be deleted: %v", err)
+	}
+}
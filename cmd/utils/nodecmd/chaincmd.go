@@ -23,15 +23,21 @@ package nodecmd
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
 
 	"github.com/klaytn/klaytn/blockchain"
 	"github.com/klaytn/klaytn/blockchain/types"
+	"github.com/klaytn/klaytn/blockchain/vm"
 	"github.com/klaytn/klaytn/cmd/utils"
+	"github.com/klaytn/klaytn/consensus/gxhash"
 	"github.com/klaytn/klaytn/governance"
 	"github.com/klaytn/klaytn/log"
+	"github.com/klaytn/klaytn/networks/rpc"
 	"github.com/klaytn/klaytn/params"
+	"github.com/klaytn/klaytn/reward"
 	"github.com/klaytn/klaytn/rlp"
 	"github.com/klaytn/klaytn/storage/database"
 	"gopkg.in/urfave/cli.v1"
@@ -81,6 +87,35 @@ It expects the genesis file as argument.`,
 		Description: `
 The dumpgenesis command dumps the genesis block configuration in JSON format to stdout.`,
 	}
+
+	DumpStakingInfoCommand = cli.Command{
+		Action:    utils.MigrateFlags(dumpStakingInfo),
+		Name:      "dumpstakinginfo",
+		Usage:     "Dumps the staking info used by a block in JSON to stdout",
+		ArgsUsage: "<blockNumber>",
+		Flags: []cli.Flag{
+			utils.DbTypeFlag,
+			utils.SingleDBFlag,
+			utils.NumStateTrieShardsFlag,
+			utils.DynamoDBTableNameFlag,
+			utils.DynamoDBRegionFlag,
+			utils.DynamoDBIsProvisionedFlag,
+			utils.DynamoDBReadCapacityFlag,
+			utils.DynamoDBWriteCapacityFlag,
+			utils.DynamoDBReadOnlyFlag,
+			utils.LevelDBCompressionTypeFlag,
+			utils.DataDirFlag,
+		},
+		Category: "BLOCKCHAIN COMMANDS",
+		Description: `
+The dumpstakinginfo command dumps the staking info used by the given block, with its
+consolidated nodes and their Gini coefficient, in JSON format to stdout.
+It prints the same data as klay_getStakingInfo, without running a node.
+
+The staking info is read from the chain database, or recomputed from the AddressBook
+contract if it is not stored. The recomputed staking info is not written to the database.
+Do not use it while a node is running on the same data directory.`,
+	}
 )
 
 // initGenesis will initialise the given JSON format genesis file and writes it as
@@ -128,35 +163,10 @@ func initGenesis(ctx *cli.Context) error {
 
 	// Open an initialise both full and light databases
 	stack := MakeFullNode(ctx)
-	parallelDBWrite := !ctx.GlobalIsSet(utils.NoParallelDBWriteFlag.Name)
-	singleDB := ctx.GlobalIsSet(utils.SingleDBFlag.Name)
-	numStateTrieShards := ctx.GlobalUint(utils.NumStateTrieShardsFlag.Name)
 	overwriteGenesis := ctx.GlobalBool(utils.OverwriteGenesisFlag.Name)
 
-	dbtype := database.DBType(ctx.GlobalString(utils.DbTypeFlag.Name)).ToValid()
-	if len(dbtype) == 0 {
-		logger.Crit("invalid dbtype", "dbtype", ctx.GlobalString(utils.DbTypeFlag.Name))
-	}
-
-	var dynamoDBConfig *database.DynamoDBConfig
-	if dbtype == database.DynamoDB {
-		dynamoDBConfig = &database.DynamoDBConfig{
-			TableName:          ctx.GlobalString(utils.DynamoDBTableNameFlag.Name),
-			Region:             ctx.GlobalString(utils.DynamoDBRegionFlag.Name),
-			IsProvisioned:      ctx.GlobalBool(utils.DynamoDBIsProvisionedFlag.Name),
-			ReadCapacityUnits:  ctx.GlobalInt64(utils.DynamoDBReadCapacityFlag.Name),
-			WriteCapacityUnits: ctx.GlobalInt64(utils.DynamoDBWriteCapacityFlag.Name),
-			ReadOnly:           ctx.GlobalBool(utils.DynamoDBReadOnlyFlag.Name),
-		}
-	}
-
 	for _, name := range []string{"chaindata"} { // Removed "lightchaindata" since Klaytn doesn't use it
-		dbc := &database.DBConfig{
-			Dir: name, DBType: dbtype, ParallelDBWrite: parallelDBWrite,
-			SingleDB: singleDB, NumStateTrieShards: numStateTrieShards,
-			LevelDBCacheSize: 0, OpenFilesLimit: 0, DynamoDBConfig: dynamoDBConfig,
-		}
-		chainDB := stack.OpenDatabase(dbc)
+		chainDB := stack.OpenDatabase(makeChainDBConfig(ctx, name))
 
 		// Initialize DeriveSha implementation
 		blockchain.InitDeriveSha(genesis.Config.DeriveShaImpl)
@@ -179,6 +189,32 @@ func initGenesis(ctx *cli.Context) error {
 	return nil
 }
 
+// makeChainDBConfig returns the configuration of the chain database of the given directory name from the flags.
+func makeChainDBConfig(ctx *cli.Context, name string) *database.DBConfig {
+	dbtype := database.DBType(ctx.GlobalString(utils.DbTypeFlag.Name)).ToValid()
+	if len(dbtype) == 0 {
+		logger.Crit("invalid dbtype", "dbtype", ctx.GlobalString(utils.DbTypeFlag.Name))
+	}
+
+	var dynamoDBConfig *database.DynamoDBConfig
+	if dbtype == database.DynamoDB {
+		dynamoDBConfig = &database.DynamoDBConfig{
+			TableName:          ctx.GlobalString(utils.DynamoDBTableNameFlag.Name),
+			Region:             ctx.GlobalString(utils.DynamoDBRegionFlag.Name),
+			IsProvisioned:      ctx.GlobalBool(utils.DynamoDBIsProvisionedFlag.Name),
+			ReadCapacityUnits:  ctx.GlobalInt64(utils.DynamoDBReadCapacityFlag.Name),
+			WriteCapacityUnits: ctx.GlobalInt64(utils.DynamoDBWriteCapacityFlag.Name),
+			ReadOnly:           ctx.GlobalBool(utils.DynamoDBReadOnlyFlag.Name),
+		}
+	}
+
+	return &database.DBConfig{
+		Dir: name, DBType: dbtype, ParallelDBWrite: !ctx.GlobalIsSet(utils.NoParallelDBWriteFlag.Name),
+		SingleDB: ctx.GlobalIsSet(utils.SingleDBFlag.Name), NumStateTrieShards: ctx.GlobalUint(utils.NumStateTrieShardsFlag.Name),
+		LevelDBCacheSize: 0, OpenFilesLimit: 0, DynamoDBConfig: dynamoDBConfig,
+	}
+}
+
 func dumpGenesis(ctx *cli.Context) error {
 	genesis := utils.MakeGenesis(ctx)
 	if genesis == nil {
@@ -190,6 +226,69 @@ func dumpGenesis(ctx *cli.Context) error {
 	return nil
 }
 
+// readOnlyStakingInfoDB is a staking info database which does not write recomputed staking info.
+type readOnlyStakingInfoDB struct {
+	db database.DBManager
+}
+
+func (r readOnlyStakingInfoDB) ReadStakingInfo(blockNum uint64) ([]byte, error) {
+	return r.db.ReadStakingInfo(blockNum)
+}
+
+func (r readOnlyStakingInfoDB) WriteStakingInfo(blockNum uint64, stakingInfo []byte) error {
+	return nil
+}
+
+// dumpStakingInfo prints the staking info used by the given block as klay_getStakingInfo does.
+func dumpStakingInfo(ctx *cli.Context) error {
+	if len(ctx.Args()) != 1 {
+		return errors.New("must supply a block number")
+	}
+	blockNum, err := strconv.ParseUint(ctx.Args().First(), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid block number %q: %v", ctx.Args().First(), err)
+	}
+
+	stack := MakeFullNode(ctx)
+	chainDB := stack.OpenDatabase(makeChainDBConfig(ctx, "chaindata"))
+	defer chainDB.Close()
+
+	genesisHash := chainDB.ReadCanonicalHash(0)
+	chainConfig := chainDB.ReadChainConfig(genesisHash)
+	if chainConfig == nil {
+		return errors.New("chain config is not found. Initialize the data directory first")
+	}
+	if chainConfig.Governance == nil || chainConfig.Governance.Reward == nil {
+		return errors.New("reward policies are not configured in the chain config")
+	}
+	params.SetStakingUpdateInterval(chainConfig.Governance.Reward.StakingUpdateInterval)
+	params.SetProposerUpdateInterval(chainConfig.Governance.Reward.ProposerUpdateInterval)
+
+	gov := governance.NewMixedEngine(chainConfig, chainDB)
+	bc, err := blockchain.NewBlockChain(chainDB, nil, chainConfig, gxhash.NewFaker(), vm.Config{})
+	if err != nil {
+		return err
+	}
+	defer bc.Stop()
+	gov.SetBlockchain(bc)
+
+	if reward.NewStakingManager(bc, gov, readOnlyStakingInfoDB{chainDB}) == nil {
+		return reward.ErrStakingManagerNotSet
+	}
+	num := rpc.BlockNumber(blockNum)
+	stakingInfo, err := governance.NewGovernanceKlayAPI(gov, bc).GetStakingInfo(&num)
+	if err != nil {
+		return err
+	}
+
+	enc, err := json.MarshalIndent(stakingInfo, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(enc))
+	return nil
+}
+
 func ValidateGenesisConfig(g *blockchain.Genesis) error {
 	if g.Config.ChainID == nil {
 		return errors.New("chainID is not specified")
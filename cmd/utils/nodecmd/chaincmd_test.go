@@ -0,0 +1,50 @@
+// Copyright 2022 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package nodecmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDumpStakingInfoInvalidArgs(t *testing.T) {
+	datadir := tmpdir(t)
+	defer os.RemoveAll(datadir)
+
+	testCases := []struct {
+		args   []string
+		errMsg string
+	}{
+		{nil, "must supply a block number"},
+		{[]string{"latest"}, "invalid block number"},
+		{[]string{"-1"}, "invalid block number"},
+		{[]string{"100"}, "chain config is not found"}, // not initialized
+	}
+
+	for _, tc := range testCases {
+		args := append([]string{"klay-test", "--datadir", datadir, "--verbosity", "0", "dumpstakinginfo"}, tc.args...)
+		klay := runKlay(t, args...)
+		klay.ExpectExit()
+		if status := klay.ExitStatus(); status != 1 {
+			t.Errorf("args %v: unexpected exit status: have %d, want 1", tc.args, status)
+		}
+		if stderr := klay.StderrText(); !strings.Contains(stderr, tc.errMsg) {
+			t.Errorf("args %v: unexpected stderr: have %q, want %q", tc.args, stderr, tc.errMsg)
+		}
+	}
+}
@@ -117,6 +117,7 @@ var CommonNodeFlags = []cli.Flag{
 	altsrc.NewBoolFlag(utils.OverwriteGenesisFlag),
 	altsrc.NewUint64Flag(utils.StartBlockNumberFlag),
 	utils.NewWrappedDirectoryFlag(utils.KeyStoreDirFlag),
+	altsrc.NewStringSliceFlag(utils.ExtraKeyStoreDirFlag),
 	altsrc.NewBoolFlag(utils.TxPoolNoLocalsFlag),
 	altsrc.NewBoolFlag(utils.TxPoolAllowLocalAnchorTxFlag),
 	altsrc.NewBoolFlag(utils.TxPoolDenyRemoteTxFlag),
@@ -133,6 +134,8 @@ var CommonNodeFlags = []cli.Flag{
 	utils.NewWrappedTextMarshalerFlag(utils.SyncModeFlag),
 	altsrc.NewStringFlag(utils.GCModeFlag),
 	altsrc.NewBoolFlag(utils.LightKDFFlag),
+	altsrc.NewIntFlag(utils.ScryptNFlag),
+	altsrc.NewIntFlag(utils.ScryptPFlag),
 	altsrc.NewBoolFlag(utils.SingleDBFlag),
 	altsrc.NewUintFlag(utils.NumStateTrieShardsFlag),
 	altsrc.NewIntFlag(utils.LevelDBCompressionTypeFlag),
@@ -161,8 +164,30 @@ var CommonNodeFlags = []cli.Flag{
 	altsrc.NewDurationFlag(utils.TrieNodeCacheSavePeriodFlag),
 	altsrc.NewStringSliceFlag(utils.TrieNodeCacheRedisEndpointsFlag),
 	altsrc.NewBoolFlag(utils.TrieNodeCacheRedisClusterFlag),
+	altsrc.NewBoolFlag(utils.TrieNodeCacheRedisSentinelFlag),
+	altsrc.NewStringFlag(utils.TrieNodeCacheRedisMasterNameFlag),
+	altsrc.NewStringFlag(utils.TrieNodeCacheRedisUsernameFlag),
+	altsrc.NewStringFlag(utils.TrieNodeCacheRedisPasswordFlag),
 	altsrc.NewBoolFlag(utils.TrieNodeCacheRedisPublishBlockFlag),
 	altsrc.NewBoolFlag(utils.TrieNodeCacheRedisSubscribeBlockFlag),
+	altsrc.NewBoolFlag(utils.TrieNodeCacheRedisRepopulateFlag),
+	altsrc.NewBoolFlag(utils.TrieNodeCacheRedisTLSFlag),
+	altsrc.NewBoolFlag(utils.TrieNodeCacheRedisTLSSkipVerifyFlag),
+	altsrc.NewStringFlag(utils.TrieNodeCacheRedisTLSCAFlag),
+	altsrc.NewStringFlag(utils.TrieNodeCacheRedisTLSCertFlag),
+	altsrc.NewStringFlag(utils.TrieNodeCacheRedisTLSKeyFlag),
+	altsrc.NewIntFlag(utils.TrieNodeCacheRedisSetItemChannelSizeFlag),
+	altsrc.NewIntFlag(utils.TrieNodeCacheRedisSetRetriesFlag),
+	altsrc.NewDurationFlag(utils.TrieNodeCacheRedisSetRetryBackoffFlag),
+	altsrc.NewStringFlag(utils.TrieNodeCacheRedisKeyPrefixFlag),
+	altsrc.NewDurationFlag(utils.TrieNodeCacheRedisEntryTTLFlag),
+	altsrc.NewDurationFlag(utils.TrieNodeCacheRedisDialTimeoutFlag),
+	altsrc.NewDurationFlag(utils.TrieNodeCacheRedisReadTimeoutFlag),
+	altsrc.NewDurationFlag(utils.TrieNodeCacheRedisWriteTimeoutFlag),
+	altsrc.NewIntFlag(utils.TrieNodeCacheRedisPoolSizeFlag),
+	altsrc.NewIntFlag(utils.TrieNodeCacheRedisMinIdleConnsFlag),
+	altsrc.NewStringFlag(utils.TrieNodeCacheRedisCompressionFlag),
+	altsrc.NewStringFlag(utils.TrieNodeCacheLocalEvictionFlag),
 	altsrc.NewIntFlag(utils.ListenPortFlag),
 	altsrc.NewIntFlag(utils.SubListenPortFlag),
 	altsrc.NewBoolFlag(utils.MultiChannelUseFlag),
@@ -235,6 +260,8 @@ var KCNFlags = []cli.Flag{
 	altsrc.NewBoolFlag(utils.BaobabFlag),
 	altsrc.NewInt64Flag(utils.BlockGenerationIntervalFlag),
 	altsrc.NewDurationFlag(utils.BlockGenerationTimeLimitFlag),
+	altsrc.NewStringFlag(utils.StakingUnavailablePolicyFlag),
+	altsrc.NewDurationFlag(utils.StakingRecomputeTimeoutFlag),
 }
 
 var KPNFlags = []cli.Flag{
@@ -71,6 +71,7 @@ func init() {
 	app.Commands = []cli.Command{
 		// See chaincmd.go:
 		InitCommand,
+		DumpStakingInfoCommand,
 
 		// See accountcmd.go
 		AccountCommand,
@@ -189,6 +189,15 @@ func (tt *TestCmd) WaitExit() {
 	tt.cmd.Wait()
 }
 
+// ExitStatus returns the exit code of the child process.
+// It must be called after the process has exited.
+func (tt *TestCmd) ExitStatus() int {
+	if tt.cmd.ProcessState == nil {
+		return -1
+	}
+	return tt.cmd.ProcessState.ExitCode()
+}
+
 func (tt *TestCmd) Interrupt() {
 	tt.cmd.Process.Signal(os.Interrupt)
 }
@@ -495,7 +495,19 @@ func (sb *backend) Finalize(chain consensus.ChainReader, header *types.Header, s
 			logger.Trace(logMsg, "header.Number", header.Number.Uint64(), "node address", sb.address, "rewardbase", header.Rewardbase)
 		}
 
-		if stakingInfo := reward.GetStakingInfo(header.Number.Uint64()); stakingInfo != nil {
+		var stakingInfo *reward.StakingInfo
+		if common.EmptyHash(header.Root) {
+			// When mining, a slow recomputation of staking info should not delay sealing.
+			// On a timeout the round is skipped, since validators recompute the staking info without timeout.
+			var err error
+			stakingInfo, err = reward.GetStakingInfoWithTimeout(header.Number.Uint64())
+			if errors.Is(err, reward.ErrStakingInfoTimeout) {
+				return nil, err
+			}
+		} else {
+			stakingInfo = reward.GetStakingInfo(header.Number.Uint64())
+		}
+		if stakingInfo != nil {
 			kirAddr = stakingInfo.KIRAddr
 			pocAddr = stakingInfo.PoCAddr
 		}
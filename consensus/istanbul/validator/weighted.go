@@ -809,7 +809,7 @@ func calcTotalAmount(weightedValidators []*weightedValidator, stakingInfo *rewar
 		gini = reward.CalcGiniCoefficient(tempStakingAmounts)
 
 		for i := range stakingAmounts {
-			stakingAmounts[i] = math.Round(math.Pow(stakingAmounts[i], 1.0/(1+gini)))
+			stakingAmounts[i] = reward.GiniAdjustedStakingAmount(stakingAmounts[i], gini)
 			totalStaking += stakingAmounts[i]
 		}
 	} else {
@@ -827,11 +827,7 @@ func calcWeight(weightedValidators []*weightedValidator, stakingAmounts []float6
 	localLogger := logger.NewWith()
 	if totalStaking > 0 {
 		for i, weightedVal := range weightedValidators {
-			weight := uint64(math.Round(stakingAmounts[i] * 100 / totalStaking))
-			if weight <= 0 {
-				// A validator, who holds zero or small stake, has minimum weight, 1.
-				weight = 1
-			}
+			weight := reward.ProposerWeight(stakingAmounts[i], totalStaking)
 			atomic.StoreUint64(&weightedVal.weight, weight)
 			localLogger = localLogger.NewWith(weightedVal.String(), weight)
 		}
@@ -18,6 +18,7 @@ package governance
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
 	"strings"
 
@@ -209,6 +210,44 @@ func (api *PublicGovernanceAPI) GetStakingInfo(num *rpc.BlockNumber) (*reward.St
 	return reward.GetStakingInfo(blockNumber), nil
 }
 
+// StakingInfoWithConsolidation is the staking info of a block with its consolidated view,
+// where the council nodes sharing a reward address are aggregated.
+type StakingInfoWithConsolidation struct {
+	*reward.StakingInfo
+	ConsolidatedNodes []reward.ConsolidatedNode
+	ConsolidatedGini  float64 // Gini coefficient of the consolidated nodes staking at least the minimum staking amount
+}
+
+// GetStakingInfo returns the staking info used by the given block with its consolidated view.
+// The staking info is the one of the staking block of the given block.
+func (api *GovernanceKlayAPI) GetStakingInfo(num *rpc.BlockNumber) (*StakingInfoWithConsolidation, error) {
+	if reward.GetStakingManager() == nil {
+		return nil, reward.ErrStakingManagerNotSet
+	}
+	blockNumber := uint64(0)
+	if num == nil || *num == rpc.LatestBlockNumber || *num == rpc.PendingBlockNumber {
+		blockNumber = api.chain.CurrentHeader().Number.Uint64()
+	} else {
+		blockNumber = uint64(num.Int64())
+	}
+
+	stakingInfo := reward.GetStakingInfo(blockNumber)
+	if stakingInfo == nil {
+		return nil, fmt.Errorf("%w. blockNum: %d, staking block number: %d",
+			reward.ErrStakingInfoNotFound, blockNumber, params.CalcStakingBlockNumber(blockNumber))
+	}
+	minStaking, err := api.governance.GetMinimumStakingAtNumber(blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	consolidated := stakingInfo.GetConsolidatedStakingInfo()
+	return &StakingInfoWithConsolidation{
+		StakingInfo:       stakingInfo,
+		ConsolidatedNodes: consolidated.GetAllNodes(),
+		ConsolidatedGini:  consolidated.CalcGiniCoefficientMinStake(minStaking),
+	}, nil
+}
+
 func (api *PublicGovernanceAPI) PendingChanges() map[string]interface{} {
 	return api.governance.PendingChanges()
 }
@@ -4,8 +4,10 @@ import (
 	"testing"
 
 	"github.com/klaytn/klaytn/common"
+	"github.com/klaytn/klaytn/networks/rpc"
 	"github.com/klaytn/klaytn/params"
 	"github.com/klaytn/klaytn/pkg/testutil/assert"
+	"github.com/klaytn/klaytn/reward"
 	"github.com/klaytn/klaytn/storage/database"
 )
 
@@ -36,3 +38,40 @@ func TestLowerBoundFeeSet(t *testing.T) {
 	_, err := govApi.Vote("kip71.lowerboundbasefee", invalidLowerBoundBaseFee)
 	assert.Equal(t, err, errInvalidLowerBound)
 }
+
+func TestGetStakingInfoWithConsolidation(t *testing.T) {
+	config := params.CypressChainConfig
+	config.Governance.KIP71 = params.GetDefaultKIP71Config()
+	gov := NewMixedEngine(config, database.NewMemoryDBManager())
+	api := NewGovernanceKlayAPI(gov, nil)
+	num := rpc.BlockNumber(1)
+
+	// no staking manager
+	reward.SetTestStakingManager(nil)
+	_, err := api.GetStakingInfo(&num)
+	assert.Equal(t, err, reward.ErrStakingManagerNotSet)
+
+	// the first two nodes share a reward address
+	stakingInfo := &reward.StakingInfo{
+		BlockNum:              0,
+		CouncilNodeAddrs:      []common.Address{{0x1}, {0x2}, {0x3}},
+		CouncilStakingAddrs:   []common.Address{{0x11}, {0x12}, {0x13}},
+		CouncilRewardAddrs:    []common.Address{{0x21}, {0x21}, {0x23}},
+		CouncilStakingAmounts: []uint64{3000000, 4000000, 10000000},
+		UseGini:               true,
+		Gini:                  0.19,
+	}
+	reward.SetTestStakingManagerWithStakingInfoCache(stakingInfo)
+	defer reward.SetTestStakingManager(nil)
+
+	result, err := api.GetStakingInfo(&num)
+	assert.NilError(t, err)
+	assert.DeepEqual(t, result.StakingInfo, stakingInfo)
+	assert.DeepEqual(t, result.ConsolidatedNodes, []reward.ConsolidatedNode{
+		{NodeAddrs: []common.Address{{0x1}, {0x2}}, StakingAddrs: []common.Address{{0x11}, {0x12}}, RewardAddr: common.Address{0x21}, StakingAmount: 7000000},
+		{NodeAddrs: []common.Address{{0x3}}, StakingAddrs: []common.Address{{0x13}}, RewardAddr: common.Address{0x23}, StakingAmount: 10000000},
+	})
+	minStaking, err := gov.GetMinimumStakingAtNumber(1)
+	assert.NilError(t, err)
+	assert.Equal(t, result.ConsolidatedGini, stakingInfo.GetConsolidatedStakingInfo().CalcGiniCoefficientMinStake(minStaking))
+}
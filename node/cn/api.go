@@ -201,6 +201,11 @@ func (api *PrivateAdminAPI) SaveTrieNodeCacheToDisk() error {
 	return api.cn.BlockChain().SaveTrieNodeCacheToDisk()
 }
 
+// FlushRedisTrieNodeCache removes all items of the redis trie node cache, e.g. after a migration.
+func (api *PrivateAdminAPI) FlushRedisTrieNodeCache() error {
+	return api.cn.BlockChain().FlushRedisTrieNodeCache()
+}
+
 func (api *PrivateAdminAPI) SpamThrottlerConfig(ctx context.Context) (*blockchain.ThrottlerConfig, error) {
 	throttler := blockchain.GetSpamThrottler()
 	if throttler == nil {
@@ -323,7 +323,9 @@ func New(ctx *node.ServiceContext, config *Config) (*CN, error) {
 
 	if governance.ProposerPolicy() == uint64(istanbul.WeightedRandom) {
 		// NewStakingManager is called with proper non-nil parameters
-		reward.NewStakingManager(cn.blockchain, governance, cn.chainDB)
+		sm := reward.NewStakingManager(cn.blockchain, governance, cn.chainDB)
+		sm.SetStakingUnavailablePolicy(config.StakingUnavailablePolicy)
+		sm.SetRecomputeTimeout(config.StakingRecomputeTimeout)
 	}
 
 	// set worker
@@ -37,6 +37,7 @@ import (
 	"github.com/klaytn/klaytn/log"
 	"github.com/klaytn/klaytn/node/cn/gasprice"
 	"github.com/klaytn/klaytn/params"
+	"github.com/klaytn/klaytn/reward"
 	"github.com/klaytn/klaytn/storage/database"
 )
 
@@ -156,6 +157,13 @@ type Config struct {
 	TxResendCount     int
 	TxResendUseLegacy bool
 
+	// StakingUnavailablePolicy decides the staking info served when it cannot be resolved while producing a block.
+	// See reward.StakingUnavailablePolicy.
+	StakingUnavailablePolicy reward.StakingUnavailablePolicy `toml:",omitempty"`
+	// StakingRecomputeTimeout bounds the wait for staking info recomputation while producing a block.
+	// Zero means no limit.
+	StakingRecomputeTimeout time.Duration `toml:",omitempty"`
+
 	// Service Chain
 	NoAccountCreation bool
 
@@ -12,6 +12,7 @@ import (
 	"github.com/klaytn/klaytn/consensus/istanbul"
 	"github.com/klaytn/klaytn/datasync/downloader"
 	"github.com/klaytn/klaytn/node/cn/gasprice"
+	"github.com/klaytn/klaytn/reward"
 	"github.com/klaytn/klaytn/storage/database"
 	"github.com/klaytn/klaytn/storage/statedb"
 )
@@ -21,54 +22,56 @@ var _ = (*configMarshaling)(nil)
 // MarshalTOML marshals as TOML.
 func (c Config) MarshalTOML() (interface{}, error) {
 	type Config struct {
-		Genesis                 *blockchain.Genesis `toml:",omitempty"`
-		NetworkId               uint64
-		SyncMode                downloader.SyncMode
-		NoPruning               bool
-		WorkerDisable           bool
-		DownloaderDisable       bool
-		FetcherDisable          bool
-		ParentOperatorAddr      *common.Address `toml:",omitempty"`
-		AnchoringPeriod         uint64
-		SentChainTxsLimit       uint64
-		OverwriteGenesis        bool
-		StartBlockNumber        uint64
-		DBType                  database.DBType
-		SkipBcVersionCheck      bool `toml:"-"`
-		SingleDB                bool
-		NumStateTrieShards      uint
-		EnableDBPerfMetrics     bool
-		LevelDBCompression      database.LevelDBCompressionType
-		LevelDBBufferPool       bool
-		LevelDBCacheSize        int
-		DynamoDBConfig          database.DynamoDBConfig
-		TrieCacheSize           int
-		TrieTimeout             time.Duration
-		TrieBlockInterval       uint
-		TriesInMemory           uint64
-		SenderTxHashIndexing    bool
-		ParallelDBWrite         bool
-		TrieNodeCacheConfig     statedb.TrieNodeCacheConfig
-		ServiceChainSigner      common.Address `toml:",omitempty"`
-		ExtraData               hexutil.Bytes  `toml:",omitempty"`
-		GasPrice                *big.Int
-		Rewardbase              common.Address `toml:",omitempty"`
-		TxPool                  blockchain.TxPoolConfig
-		GPO                     gasprice.Config
-		EnablePreimageRecording bool
-		EnableInternalTxTracing bool
-		Istanbul                istanbul.Config
-		DocRoot                 string `toml:"-"`
-		WsEndpoint              string `toml:",omitempty"`
-		TxResendInterval        uint64
-		TxResendCount           int
-		TxResendUseLegacy       bool
-		NoAccountCreation       bool
-		IsPrivate               bool
-		AutoRestartFlag         bool
-		RestartTimeOutFlag      time.Duration
-		DaemonPathFlag          string
-		RPCGasCap               *big.Int `toml:",omitempty"`
+		Genesis                  *blockchain.Genesis `toml:",omitempty"`
+		NetworkId                uint64
+		SyncMode                 downloader.SyncMode
+		NoPruning                bool
+		WorkerDisable            bool
+		DownloaderDisable        bool
+		FetcherDisable           bool
+		ParentOperatorAddr       *common.Address `toml:",omitempty"`
+		AnchoringPeriod          uint64
+		SentChainTxsLimit        uint64
+		OverwriteGenesis         bool
+		StartBlockNumber         uint64
+		DBType                   database.DBType
+		SkipBcVersionCheck       bool `toml:"-"`
+		SingleDB                 bool
+		NumStateTrieShards       uint
+		EnableDBPerfMetrics      bool
+		LevelDBCompression       database.LevelDBCompressionType
+		LevelDBBufferPool        bool
+		LevelDBCacheSize         int
+		DynamoDBConfig           database.DynamoDBConfig
+		TrieCacheSize            int
+		TrieTimeout              time.Duration
+		TrieBlockInterval        uint
+		TriesInMemory            uint64
+		SenderTxHashIndexing     bool
+		ParallelDBWrite          bool
+		TrieNodeCacheConfig      statedb.TrieNodeCacheConfig
+		ServiceChainSigner       common.Address `toml:",omitempty"`
+		ExtraData                hexutil.Bytes  `toml:",omitempty"`
+		GasPrice                 *big.Int
+		Rewardbase               common.Address `toml:",omitempty"`
+		TxPool                   blockchain.TxPoolConfig
+		GPO                      gasprice.Config
+		EnablePreimageRecording  bool
+		EnableInternalTxTracing  bool
+		Istanbul                 istanbul.Config
+		DocRoot                  string `toml:"-"`
+		WsEndpoint               string `toml:",omitempty"`
+		TxResendInterval         uint64
+		TxResendCount            int
+		TxResendUseLegacy        bool
+		StakingUnavailablePolicy reward.StakingUnavailablePolicy `toml:",omitempty"`
+		StakingRecomputeTimeout  time.Duration                   `toml:",omitempty"`
+		NoAccountCreation        bool
+		IsPrivate                bool
+		AutoRestartFlag          bool
+		RestartTimeOutFlag       time.Duration
+		DaemonPathFlag           string
+		RPCGasCap                *big.Int `toml:",omitempty"`
 	}
 	var enc Config
 	enc.Genesis = c.Genesis
@@ -113,6 +116,8 @@ func (c Config) MarshalTOML() (interface{}, error) {
 	enc.TxResendInterval = c.TxResendInterval
 	enc.TxResendCount = c.TxResendCount
 	enc.TxResendUseLegacy = c.TxResendUseLegacy
+	enc.StakingUnavailablePolicy = c.StakingUnavailablePolicy
+	enc.StakingRecomputeTimeout = c.StakingRecomputeTimeout
 	enc.NoAccountCreation = c.NoAccountCreation
 	enc.IsPrivate = c.IsPrivate
 	enc.AutoRestartFlag = c.AutoRestartFlag
@@ -125,54 +130,56 @@ func (c Config) MarshalTOML() (interface{}, error) {
 // UnmarshalTOML unmarshals from TOML.
 func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	type Config struct {
-		Genesis                 *blockchain.Genesis `toml:",omitempty"`
-		NetworkId               *uint64
-		SyncMode                *downloader.SyncMode
-		NoPruning               *bool
-		WorkerDisable           *bool
-		DownloaderDisable       *bool
-		FetcherDisable          *bool
-		ParentOperatorAddr      *common.Address `toml:",omitempty"`
-		AnchoringPeriod         *uint64
-		SentChainTxsLimit       *uint64
-		OverwriteGenesis        *bool
-		StartBlockNumber        *uint64
-		DBType                  *database.DBType
-		SkipBcVersionCheck      *bool `toml:"-"`
-		SingleDB                *bool
-		NumStateTrieShards      *uint
-		EnableDBPerfMetrics     *bool
-		LevelDBCompression      *database.LevelDBCompressionType
-		LevelDBBufferPool       *bool
-		LevelDBCacheSize        *int
-		DynamoDBConfig          *database.DynamoDBConfig
-		TrieCacheSize           *int
-		TrieTimeout             *time.Duration
-		TrieBlockInterval       *uint
-		TriesInMemory           *uint64
-		SenderTxHashIndexing    *bool
-		ParallelDBWrite         *bool
-		TrieNodeCacheConfig     *statedb.TrieNodeCacheConfig
-		ServiceChainSigner      *common.Address `toml:",omitempty"`
-		ExtraData               *hexutil.Bytes  `toml:",omitempty"`
-		GasPrice                *big.Int
-		Rewardbase              *common.Address `toml:",omitempty"`
-		TxPool                  *blockchain.TxPoolConfig
-		GPO                     *gasprice.Config
-		EnablePreimageRecording *bool
-		EnableInternalTxTracing *bool
-		Istanbul                *istanbul.Config
-		DocRoot                 *string `toml:"-"`
-		WsEndpoint              *string `toml:",omitempty"`
-		TxResendInterval        *uint64
-		TxResendCount           *int
-		TxResendUseLegacy       *bool
-		NoAccountCreation       *bool
-		IsPrivate               *bool
-		AutoRestartFlag         *bool
-		RestartTimeOutFlag      *time.Duration
-		DaemonPathFlag          *string
-		RPCGasCap               *big.Int `toml:",omitempty"`
+		Genesis                  *blockchain.Genesis `toml:",omitempty"`
+		NetworkId                *uint64
+		SyncMode                 *downloader.SyncMode
+		NoPruning                *bool
+		WorkerDisable            *bool
+		DownloaderDisable        *bool
+		FetcherDisable           *bool
+		ParentOperatorAddr       *common.Address `toml:",omitempty"`
+		AnchoringPeriod          *uint64
+		SentChainTxsLimit        *uint64
+		OverwriteGenesis         *bool
+		StartBlockNumber         *uint64
+		DBType                   *database.DBType
+		SkipBcVersionCheck       *bool `toml:"-"`
+		SingleDB                 *bool
+		NumStateTrieShards       *uint
+		EnableDBPerfMetrics      *bool
+		LevelDBCompression       *database.LevelDBCompressionType
+		LevelDBBufferPool        *bool
+		LevelDBCacheSize         *int
+		DynamoDBConfig           *database.DynamoDBConfig
+		TrieCacheSize            *int
+		TrieTimeout              *time.Duration
+		TrieBlockInterval        *uint
+		TriesInMemory            *uint64
+		SenderTxHashIndexing     *bool
+		ParallelDBWrite          *bool
+		TrieNodeCacheConfig      *statedb.TrieNodeCacheConfig
+		ServiceChainSigner       *common.Address `toml:",omitempty"`
+		ExtraData                *hexutil.Bytes  `toml:",omitempty"`
+		GasPrice                 *big.Int
+		Rewardbase               *common.Address `toml:",omitempty"`
+		TxPool                   *blockchain.TxPoolConfig
+		GPO                      *gasprice.Config
+		EnablePreimageRecording  *bool
+		EnableInternalTxTracing  *bool
+		Istanbul                 *istanbul.Config
+		DocRoot                  *string `toml:"-"`
+		WsEndpoint               *string `toml:",omitempty"`
+		TxResendInterval         *uint64
+		TxResendCount            *int
+		TxResendUseLegacy        *bool
+		StakingUnavailablePolicy *reward.StakingUnavailablePolicy `toml:",omitempty"`
+		StakingRecomputeTimeout  *time.Duration                   `toml:",omitempty"`
+		NoAccountCreation        *bool
+		IsPrivate                *bool
+		AutoRestartFlag          *bool
+		RestartTimeOutFlag       *time.Duration
+		DaemonPathFlag           *string
+		RPCGasCap                *big.Int `toml:",omitempty"`
 	}
 	var dec Config
 	if err := unmarshal(&dec); err != nil {
@@ -304,6 +311,12 @@ func (c *Config) UnmarshalTOML(unmarshal func(interface{}) error) error {
 	if dec.TxResendUseLegacy != nil {
 		c.TxResendUseLegacy = *dec.TxResendUseLegacy
 	}
+	if dec.StakingUnavailablePolicy != nil {
+		c.StakingUnavailablePolicy = *dec.StakingUnavailablePolicy
+	}
+	if dec.StakingRecomputeTimeout != nil {
+		c.StakingRecomputeTimeout = *dec.StakingRecomputeTimeout
+	}
 	if dec.NoAccountCreation != nil {
 		c.NoAccountCreation = *dec.NoAccountCreation
 	}
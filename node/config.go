@@ -48,6 +48,15 @@ const (
 	datadirNodeDatabase    = "nodes"              // Path within the datadir to store the node infos
 )
 
+const (
+	// Bounds of the scrypt parameters of the key store given by the user.
+	// N costs N KiB of memory, so the maximum uses 4 GiB.
+	minScryptN = 1 << 10
+	maxScryptN = 1 << 22
+	minScryptP = 1
+	maxScryptP = 16
+)
+
 // Config represents a small collection of configuration values to fine tune the
 // P2P network layer of a protocol stack. These values can be further extended by
 // all registered services.
@@ -86,10 +95,21 @@ type Config struct {
 	// is created by New and destroyed when the node is stopped.
 	KeyStoreDir string `toml:",omitempty"`
 
+	// ExtraKeyStoreDirs are additional file system folders that contain private keys.
+	// Keys in these directories are managed together with the ones in KeyStoreDir,
+	// while new keys are always stored in KeyStoreDir. Relative paths are resolved
+	// relative to the current directory.
+	ExtraKeyStoreDirs []string `toml:",omitempty"`
+
 	// UseLightweightKDF lowers the memory and CPU requirements of the key store
 	// scrypt KDF at the expense of security.
 	UseLightweightKDF bool `toml:",omitempty"`
 
+	// ScryptN and ScryptP override the scrypt KDF parameters of the key store, including
+	// the lightweight ones, if they are non-zero. N should be a power of two.
+	ScryptN int `toml:",omitempty"`
+	ScryptP int `toml:",omitempty"`
+
 	// IPCPath is the requested location to place the IPC endpoint. If the path is
 	// a simple file name, it is placed inside the data directory (or on the root
 	// pipe path on Windows), whereas if it's a resolvable path name (absolute or
@@ -414,6 +434,18 @@ func (c *Config) AccountConfig() (int, int, string, error) {
 		scryptN = keystore.LightScryptN
 		scryptP = keystore.LightScryptP
 	}
+	if c.ScryptN != 0 {
+		if c.ScryptN < minScryptN || c.ScryptN > maxScryptN || c.ScryptN&(c.ScryptN-1) != 0 {
+			return 0, 0, "", fmt.Errorf("scrypt N should be a power of two between %d and %d: %d", minScryptN, maxScryptN, c.ScryptN)
+		}
+		scryptN = c.ScryptN
+	}
+	if c.ScryptP != 0 {
+		if c.ScryptP < minScryptP || c.ScryptP > maxScryptP {
+			return 0, 0, "", fmt.Errorf("scrypt P should be between %d and %d: %d", minScryptP, maxScryptP, c.ScryptP)
+		}
+		scryptP = c.ScryptP
+	}
 
 	var (
 		keydir string
@@ -436,6 +468,9 @@ func (c *Config) AccountConfig() (int, int, string, error) {
 
 func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 	scryptN, scryptP, keydir, err := conf.AccountConfig()
+	if err != nil {
+		return nil, "", err
+	}
 	var ephemeral string
 	if keydir == "" {
 		// There is no datadir.
@@ -453,5 +488,12 @@ func makeAccountManager(conf *Config) (*accounts.Manager, string, error) {
 	backends := []accounts.Backend{
 		keystore.NewKeyStore(keydir, scryptN, scryptP),
 	}
+	for _, dir := range conf.ExtraKeyStoreDirs {
+		extraKeydir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, "", err
+		}
+		backends = append(backends, keystore.NewKeyStore(extraKeydir, scryptN, scryptP))
+	}
 	return accounts.NewManager(backends...), ephemeral, nil
 }
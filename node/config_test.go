@@ -28,6 +28,7 @@ import (
 	"runtime"
 	"testing"
 
+	"github.com/klaytn/klaytn/accounts/keystore"
 	"github.com/klaytn/klaytn/crypto"
 	"github.com/klaytn/klaytn/networks/p2p"
 )
@@ -155,3 +156,39 @@ func TestNodeKeyPersistency(t *testing.T) {
 		}
 	*/
 }
+
+// Tests that the scrypt parameters given by the user override the presets and are validated.
+func TestAccountConfigScrypt(t *testing.T) {
+	tests := []struct {
+		config         Config
+		scryptN        int
+		scryptP        int
+		expectFailures bool
+	}{
+		{config: Config{}, scryptN: keystore.StandardScryptN, scryptP: keystore.StandardScryptP},
+		{config: Config{UseLightweightKDF: true}, scryptN: keystore.LightScryptN, scryptP: keystore.LightScryptP},
+		{config: Config{UseLightweightKDF: true, ScryptN: 1 << 14, ScryptP: 2}, scryptN: 1 << 14, scryptP: 2},
+		{config: Config{ScryptP: 4}, scryptN: keystore.StandardScryptN, scryptP: 4},
+		{config: Config{ScryptN: 3000}, expectFailures: true},    // not a power of two
+		{config: Config{ScryptN: 1 << 8}, expectFailures: true},  // too small
+		{config: Config{ScryptN: 1 << 24}, expectFailures: true}, // too big
+		{config: Config{ScryptP: -1}, expectFailures: true},
+		{config: Config{ScryptP: 17}, expectFailures: true},
+	}
+	for i, test := range tests {
+		scryptN, scryptP, _, err := test.config.AccountConfig()
+		if test.expectFailures {
+			if err == nil {
+				t.Errorf("test %d: expected a failure", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("test %d: unexpected failure: %v", i, err)
+			continue
+		}
+		if scryptN != test.scryptN || scryptP != test.scryptP {
+			t.Errorf("test %d: scrypt params mismatch: have (%d, %d), want (%d, %d)", i, scryptN, scryptP, test.scryptN, test.scryptP)
+		}
+	}
+}
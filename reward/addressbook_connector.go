@@ -40,7 +40,13 @@ const (
 	addressTypeKIRAddr
 )
 
-var errAddressBookIncomplete = errors.New("incomplete node information from AddressBook")
+var (
+	errAddressBookIncomplete = errors.New("incomplete node information from AddressBook")
+
+	// ErrAddressBookNotDeployed is returned for the blocks before the AddressBook contract is deployed.
+	// The council of those blocks is empty, so it is not a failure.
+	ErrAddressBookNotDeployed = errors.New("AddressBook contract is not deployed")
+)
 
 var addressBookContractAddress = contract.AddressBookContractAddress
 
@@ -49,6 +55,7 @@ type addressBookConnector struct {
 	gh              governanceHelper
 	abi             string
 	contractAddress common.Address
+	stakingLimit    uint64 // cap of a staking amount in KLAY. Not capped if zero.
 }
 
 // create and return addressBookConnector
@@ -58,6 +65,7 @@ func newAddressBookConnector(bc blockChain, gh governanceHelper) *addressBookCon
 		gh:              gh,
 		abi:             contract.AddressBookABI,
 		contractAddress: common.HexToAddress(addressBookContractAddress),
+		stakingLimit:    maxStakingLimit,
 	}
 }
 
@@ -164,6 +172,7 @@ func (ac *addressBookConnector) parseAllAddresses(result []byte) (nodeIds []comm
 }
 
 // getStakingInfoFromAddressBook returns stakingInfo when calling AddressBook succeeded.
+// If addressBook is not deployed yet, ErrAddressBookNotDeployed is returned.
 // If addressBook is not activated, emptyStakingInfo is returned.
 // After addressBook is activated, it returns stakingInfo with addresses and stakingAmount.
 // Otherwise, it returns an error.
@@ -186,6 +195,9 @@ func (ac *addressBookConnector) getStakingInfoFromAddressBook(blockNum uint64) (
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("failed to make a state for interval block. blockNum: %d, root err: %s", blockNum, err))
 	}
+	if statedb.GetCodeSize(ac.contractAddress) == 0 {
+		return nil, ErrAddressBookNotDeployed
+	}
 
 	// Create a new context to be used in the EVM environment
 	context := blockchain.NewEVMContext(msg, intervalBlock.Header(), ac.bc, nil)
@@ -213,7 +225,7 @@ func (ac *addressBookConnector) getStakingInfoFromAddressBook(blockNum uint64) (
 		return newEmptyStakingInfo(blockNum), nil
 	}
 
-	return newStakingInfo(ac.bc, ac.gh, blockNum, nodeAddrs, stakingAddrs, rewardAddrs, KIRAddr, PoCAddr)
+	return newStakingInfo(ac.bc, ac.gh, blockNum, nodeAddrs, stakingAddrs, rewardAddrs, KIRAddr, PoCAddr, ac.stakingLimit)
 }
 
 // Only for testing purpose.
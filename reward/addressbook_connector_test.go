@@ -17,11 +17,16 @@
 package reward
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/klaytn/klaytn/blockchain"
+	"github.com/klaytn/klaytn/blockchain/state"
+	"github.com/klaytn/klaytn/common"
 	"github.com/klaytn/klaytn/params"
+	"github.com/klaytn/klaytn/storage/database"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func newTestBlockChain() *blockchain.BlockChain {
@@ -37,3 +42,41 @@ func TestAddressBookManager_makeMsgToAddressBook(t *testing.T) {
 	}
 	assert.Equal(t, targetAddress, msg.To().String())
 }
+
+func TestAddressBookConnector_NotDeployed(t *testing.T) {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(database.NewMemoryDBManager()), nil)
+	require.NoError(t, err)
+
+	bc := &stateTestBlockChain{newTestBlockChain(), statedb}
+	gov := newDefaultTestGovernance()
+	ac := newAddressBookConnector(bc, gov)
+	interval := params.StakingUpdateInterval()
+
+	// the state has no AddressBook contract
+	_, err = ac.getStakingInfoFromAddressBook(interval)
+	assert.True(t, errors.Is(err, ErrAddressBookNotDeployed))
+
+	// an empty council is served and stored
+	sm := &StakingManager{
+		addressBookConnector: ac,
+		stakingInfoCache:     newStakingInfoCache(),
+		stakingInfoDB:        NewMemoryStakingInfoDB(),
+		governanceHelper:     gov,
+		blockchain:           bc,
+	}
+	stakingInfo, err := sm.updateStakingInfo(interval)
+	require.NoError(t, err)
+	assert.Equal(t, interval, stakingInfo.BlockNum)
+	assert.Empty(t, stakingInfo.CouncilNodeAddrs)
+	assert.Equal(t, stakingInfo, sm.stakingInfoCache.get(interval))
+
+	stakingInfo, err = sm.HistoricalStakingInfo(interval)
+	require.NoError(t, err)
+	assert.Empty(t, stakingInfo.CouncilNodeAddrs)
+
+	// a failure to read the state is not regarded as not deployed
+	ac = newAddressBookConnector(&prunedTestBlockChain{newTestBlockChain()}, gov)
+	_, err = ac.getStakingInfoFromAddressBook(interval)
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, ErrAddressBookNotDeployed))
+}
@@ -0,0 +1,32 @@
+// Copyright 2022 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package reward
+
+import "github.com/rcrowley/go-metrics"
+
+var (
+	// staleStakingInfoCounter counts lookups of staking info too far ahead of the latest refresh.
+	staleStakingInfoCounter = metrics.NewRegisteredCounter("reward/stakinginfo/stale", nil)
+
+	// counters of the sources serving staking info lookups. See StakingManager.StakingInfoStats.
+	stakingInfoCacheHitCounter  = metrics.NewRegisteredCounter("reward/stakinginfo/cache/hit", nil)
+	stakingInfoDBHitCounter     = metrics.NewRegisteredCounter("reward/stakinginfo/db/hit", nil)
+	stakingInfoRecomputeCounter = metrics.NewRegisteredCounter("reward/stakinginfo/recompute", nil)
+
+	// stakingInfoInconsistentCounter counts cache hits differing from the database. See StakingManagerConfig.CheckConsistency.
+	stakingInfoInconsistentCounter = metrics.NewRegisteredCounter("reward/stakinginfo/inconsistent", nil)
+)
@@ -75,6 +75,8 @@ func (governance *testGovernance) GetItemAtNumberByIntKey(num uint64, key int) (
 		return governance.unitPrice, nil
 	case params.Epoch:
 		return governance.epoch, nil
+	case params.UseGiniCoeff:
+		return governance.useGiniCoeff, nil
 	default:
 		return nil, errors.New("Unhandled key on testGovernance")
 	}
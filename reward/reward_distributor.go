@@ -112,21 +112,7 @@ func (rd *RewardDistributor) distributeBlockReward(b BalanceAdder, header *types
 	// Block reward
 	blockReward := big.NewInt(0).Add(rewardConfig.mintingAmount, totalTxFee)
 
-	tmpInt := big.NewInt(0)
-
-	tmpInt = tmpInt.Mul(blockReward, rewardConfig.cnRatio)
-	cnReward := big.NewInt(0).Div(tmpInt, rewardConfig.totalRatio)
-
-	tmpInt = tmpInt.Mul(blockReward, rewardConfig.pocRatio)
-	pocIncentive := big.NewInt(0).Div(tmpInt, rewardConfig.totalRatio)
-
-	tmpInt = tmpInt.Mul(blockReward, rewardConfig.kirRatio)
-	kirIncentive := big.NewInt(0).Div(tmpInt, rewardConfig.totalRatio)
-
-	remaining := tmpInt.Sub(blockReward, cnReward)
-	remaining = tmpInt.Sub(remaining, pocIncentive)
-	remaining = tmpInt.Sub(remaining, kirIncentive)
-	pocIncentive = pocIncentive.Add(pocIncentive, remaining)
+	cnReward, pocIncentive, kirIncentive := splitBlockReward(blockReward, rewardConfig)
 
 	// CN reward
 	b.AddBalance(proposer, cnReward)
@@ -149,3 +135,25 @@ func (rd *RewardDistributor) distributeBlockReward(b BalanceAdder, header *types
 		"PoC address", pocAddr, "Poc incentive", pocIncentive,
 		"KIR address", kirAddr, "KIR incentive", kirIncentive)
 }
+
+// splitBlockReward splits a block reward into CN reward, PoC incentive and KIR incentive by the ratio of rewardConfig.
+// The remainder of the division is added to PoC incentive.
+func splitBlockReward(blockReward *big.Int, rewardConfig *rewardConfig) (*big.Int, *big.Int, *big.Int) {
+	tmpInt := big.NewInt(0)
+
+	tmpInt = tmpInt.Mul(blockReward, rewardConfig.cnRatio)
+	cnReward := big.NewInt(0).Div(tmpInt, rewardConfig.totalRatio)
+
+	tmpInt = tmpInt.Mul(blockReward, rewardConfig.pocRatio)
+	pocIncentive := big.NewInt(0).Div(tmpInt, rewardConfig.totalRatio)
+
+	tmpInt = tmpInt.Mul(blockReward, rewardConfig.kirRatio)
+	kirIncentive := big.NewInt(0).Div(tmpInt, rewardConfig.totalRatio)
+
+	remaining := tmpInt.Sub(blockReward, cnReward)
+	remaining = tmpInt.Sub(remaining, pocIncentive)
+	remaining = tmpInt.Sub(remaining, kirIncentive)
+	pocIncentive = pocIncentive.Add(pocIncentive, remaining)
+
+	return cnReward, pocIncentive, kirIncentive
+}
@@ -17,6 +17,7 @@
 package reward
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -26,6 +27,7 @@ import (
 	"sort"
 
 	"github.com/klaytn/klaytn/common"
+	"github.com/klaytn/klaytn/crypto"
 	"github.com/klaytn/klaytn/params"
 	"github.com/klaytn/klaytn/rlp"
 )
@@ -34,15 +36,30 @@ const (
 	AddrNotFoundInCouncilNodes = -1
 	maxStakingLimit            = uint64(100000000000)
 	DefaultGiniCoefficient     = -1.0
+	giniEqualityEpsilon        = 1e-9 // tolerance of Gini coefficients compared by StakingInfo.Equal
+
+	// StakingInfoSchemaVersion is the schema version of StakingInfo created by this version.
+	// Staking info of an older schema is migrated by StakingInfo.Upgrade.
+	//   0: legacy, without SchemaVersion
+	//   1: SchemaVersion is introduced
+	StakingInfoSchemaVersion = uint64(1)
 )
 
 var (
-	maxStakingLimitBigInt = big.NewInt(0).SetUint64(maxStakingLimit)
-
 	ErrAddrNotInStakingInfo = errors.New("Address is not in stakingInfo")
+	ErrRankOutOfRange       = errors.New("rank is out of range")
+
+	// errors for StakingInfo.Validate
+	ErrCouncilLengthMismatch = errors.New("lengths of council entries differ")
+	ErrDuplicateNodeAddr     = errors.New("duplicate node address in stakingInfo")
+	ErrDuplicateStakingAddr  = errors.New("duplicate staking address in stakingInfo")
 )
 
 // StakingInfo contains staking information.
+//
+// A StakingInfo served by StakingManager is shared by the cache and all callers, and it is not safe to modify.
+// Callers modifying it, e.g. sorting its slices, should modify a copy made by Clone,
+// or enable StakingManager.SetSafeCopy to be served copies.
 type StakingInfo struct {
 	BlockNum uint64 // Block number where staking information of Council is fetched
 
@@ -58,6 +75,8 @@ type StakingInfo struct {
 
 	// Derived from CouncilStakingAddrs
 	CouncilStakingAmounts []uint64 // Staking amounts of Council
+
+	SchemaVersion uint64 // schema version of the staking info. 0 if it is stored by a legacy version
 }
 
 // Refined staking information suitable for proposer selection.
@@ -74,18 +93,34 @@ type StakingInfo struct {
 //     CN1 = {[N1,N2], [S1,S2], R1, A1+A2}
 //     CN3 = {[N3],    [S3],    R3, A3}
 //
-type consolidatedNode struct {
+type ConsolidatedNode struct {
 	NodeAddrs     []common.Address
 	StakingAddrs  []common.Address
 	RewardAddr    common.Address // common reward address
 	StakingAmount uint64         // sum of staking amounts
 }
 
+// DecentralizationReport shows how decentralized the consolidated nodes are, by stake and by node count.
+type DecentralizationReport struct {
+	Gini                float64 `json:"gini"`                // Gini coefficient of the eligible staking amounts
+	NakamotoCoefficient int     `json:"nakamotoCoefficient"` // minimum number of eligible nodes holding more than 1/3 of the eligible stake
+	EffectiveStakers    float64 `json:"effectiveStakers"`    // inverse of the Herfindahl index of the eligible staking amounts
+	NodeCount           int     `json:"nodeCount"`           // number of the consolidated nodes
+	EligibleNodeCount   int     `json:"eligibleNodeCount"`   // number of the consolidated nodes staking at least the minimum
+}
+
 type ConsolidatedStakingInfo struct {
-	nodes     []consolidatedNode
-	nodeIndex map[common.Address]int // nodeAddr -> index in []nodes
+	nodes       []ConsolidatedNode
+	nodeIndex   map[common.Address]int // nodeAddr -> index in []nodes
+	rewardIndex map[common.Address]int // rewardAddr -> index in []nodes
 }
 
+// stakingInfoRLP is the RLP layout of StakingInfo.
+//
+// The layout of the legacy schema (version 0) ends at CouncilStakingAmounts. Later fields must be appended
+// with the "optional" tag, so that an older blob still decodes with the missing fields set to zero values.
+// Fields appended by a newer version, unknown to this version, are swallowed by Rest and dropped,
+// so that this version can still decode a blob stored by a newer one.
 type stakingInfoRLP struct {
 	BlockNum              uint64
 	CouncilNodeAddrs      []common.Address
@@ -96,6 +131,8 @@ type stakingInfoRLP struct {
 	UseGini               bool
 	Gini                  uint64
 	CouncilStakingAmounts []uint64
+	SchemaVersion         uint64         `rlp:"optional"`
+	Rest                  []rlp.RawValue `rlp:"tail"` // fields unknown to this version
 }
 
 func newEmptyStakingInfo(blockNum uint64) *StakingInfo {
@@ -109,11 +146,14 @@ func newEmptyStakingInfo(blockNum uint64) *StakingInfo {
 		CouncilStakingAmounts: make([]uint64, 0, 0),
 		Gini:                  DefaultGiniCoefficient,
 		UseGini:               false,
+		SchemaVersion:         StakingInfoSchemaVersion,
 	}
 	return stakingInfo
 }
 
-func newStakingInfo(bc blockChain, helper governanceHelper, blockNum uint64, nodeAddrs []common.Address, stakingAddrs []common.Address, rewardAddrs []common.Address, KIRAddr common.Address, PoCAddr common.Address) (*StakingInfo, error) {
+// newStakingInfo creates a staking info from the balances of the staking addresses at the given block.
+// Each staking amount is capped at stakingLimit KLAY, which is not capped if zero.
+func newStakingInfo(bc blockChain, helper governanceHelper, blockNum uint64, nodeAddrs []common.Address, stakingAddrs []common.Address, rewardAddrs []common.Address, KIRAddr common.Address, PoCAddr common.Address, stakingLimit uint64) (*StakingInfo, error) {
 	intervalBlock := bc.GetBlockByNumber(blockNum)
 	if intervalBlock == nil {
 		logger.Trace("Failed to get the block by the given number", "blockNum", blockNum)
@@ -126,11 +166,16 @@ func newStakingInfo(bc blockChain, helper governanceHelper, blockNum uint64, nod
 	}
 
 	// Get balance of stakingAddrs
+	stakingLimitBigInt := new(big.Int).SetUint64(stakingLimit)
+	if stakingLimit == 0 {
+		// staking amounts in KLAY are still bounded by uint64
+		stakingLimitBigInt.SetUint64(math.MaxUint64)
+	}
 	stakingAmounts := make([]uint64, len(stakingAddrs))
 	for i, stakingAddr := range stakingAddrs {
 		tempStakingAmount := big.NewInt(0).Div(statedb.GetBalance(stakingAddr), big.NewInt(0).SetUint64(params.KLAY))
-		if tempStakingAmount.Cmp(maxStakingLimitBigInt) > 0 {
-			tempStakingAmount.SetUint64(maxStakingLimit)
+		if tempStakingAmount.Cmp(stakingLimitBigInt) > 0 {
+			tempStakingAmount.Set(stakingLimitBigInt)
 		}
 		stakingAmounts[i] = tempStakingAmount.Uint64()
 	}
@@ -154,10 +199,194 @@ func newStakingInfo(bc blockChain, helper governanceHelper, blockNum uint64, nod
 		CouncilStakingAmounts: stakingAmounts,
 		Gini:                  gini,
 		UseGini:               useGini,
+		SchemaVersion:         StakingInfoSchemaVersion,
+	}
+
+	// The balance of a duplicate staking address is counted more than once. It is kept as it is for consensus,
+	// but it indicates a misconfiguration of the AddressBook.
+	if addr, ok := findDuplicateAddress(stakingAddrs); ok {
+		logger.Warn("Duplicate staking address in AddressBook; its stake is counted more than once", "blockNum", blockNum, "stakingAddr", addr)
 	}
 	return stakingInfo, nil
 }
 
+// Clone returns a deep copy of the staking info sharing no slice with the original.
+func (s *StakingInfo) Clone() *StakingInfo {
+	c := *s
+	c.CouncilNodeAddrs = copyAddresses(s.CouncilNodeAddrs)
+	c.CouncilStakingAddrs = copyAddresses(s.CouncilStakingAddrs)
+	c.CouncilRewardAddrs = copyAddresses(s.CouncilRewardAddrs)
+	if s.CouncilStakingAmounts != nil {
+		c.CouncilStakingAmounts = make([]uint64, len(s.CouncilStakingAmounts))
+		copy(c.CouncilStakingAmounts, s.CouncilStakingAmounts)
+	}
+	return &c
+}
+
+// CloneForBlock returns a copy of the staking info for the given block number, sharing no slice with the original.
+// It carries forward the council and stakes to another staking interval. Gini is reset to DefaultGiniCoefficient
+// so that it is recomputed for the new interval.
+func (s *StakingInfo) CloneForBlock(blockNum uint64) *StakingInfo {
+	c := s.Clone()
+	c.BlockNum = blockNum
+	c.Gini = DefaultGiniCoefficient
+	return c
+}
+
+// Equal reports whether two staking infos have the same block number, council entries in the same order,
+// KIR and PoC addresses, staking amounts, and UseGini. Gini coefficients are compared within giniEqualityEpsilon.
+// A nil slice equals an empty one.
+func (s *StakingInfo) Equal(other *StakingInfo) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	if s.BlockNum != other.BlockNum || s.KIRAddr != other.KIRAddr || s.PoCAddr != other.PoCAddr || s.UseGini != other.UseGini {
+		return false
+	}
+	if !equalAddresses(s.CouncilNodeAddrs, other.CouncilNodeAddrs) ||
+		!equalAddresses(s.CouncilStakingAddrs, other.CouncilStakingAddrs) ||
+		!equalAddresses(s.CouncilRewardAddrs, other.CouncilRewardAddrs) {
+		return false
+	}
+	if len(s.CouncilStakingAmounts) != len(other.CouncilStakingAmounts) {
+		return false
+	}
+	for i := range s.CouncilStakingAmounts {
+		if s.CouncilStakingAmounts[i] != other.CouncilStakingAmounts[i] {
+			return false
+		}
+	}
+	return math.Abs(s.Gini-other.Gini) <= giniEqualityEpsilon
+}
+
+func equalAddresses(a, b []common.Address) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// copyAddresses returns a copy of the given addresses. It returns nil if the given addresses is nil.
+func copyAddresses(addrs []common.Address) []common.Address {
+	if addrs == nil {
+		return nil
+	}
+	c := make([]common.Address, len(addrs))
+	copy(c, addrs)
+	return c
+}
+
+// Filter returns a new StakingInfo containing only the council entries of the given node addresses.
+// The council slices of the result stay aligned, and BlockNum, KIRAddr, PoCAddr, UseGini and SchemaVersion are preserved.
+// Nothing is recomputed, so Gini of the result is DefaultGiniCoefficient until it is recomputed.
+func (s *StakingInfo) Filter(nodes []common.Address) *StakingInfo {
+	wanted := make(map[common.Address]bool, len(nodes))
+	for _, node := range nodes {
+		wanted[node] = true
+	}
+
+	filtered := newEmptyStakingInfo(s.BlockNum)
+	filtered.KIRAddr, filtered.PoCAddr, filtered.UseGini, filtered.SchemaVersion = s.KIRAddr, s.PoCAddr, s.UseGini, s.SchemaVersion
+	for i, nodeAddr := range s.CouncilNodeAddrs {
+		if !wanted[nodeAddr] {
+			continue
+		}
+		filtered.CouncilNodeAddrs = append(filtered.CouncilNodeAddrs, nodeAddr)
+		filtered.CouncilStakingAddrs = append(filtered.CouncilStakingAddrs, s.CouncilStakingAddrs[i])
+		filtered.CouncilRewardAddrs = append(filtered.CouncilRewardAddrs, s.CouncilRewardAddrs[i])
+		filtered.CouncilStakingAmounts = append(filtered.CouncilStakingAmounts, s.CouncilStakingAmounts[i])
+	}
+	return filtered
+}
+
+// Upgrade migrates the staking info of an older schema to the current schema in place.
+// Fields derivable from the others are back-filled, and the others are set to their unknown values
+// so that consumers can tell which fields are populated. It does nothing to the staking info of the current schema.
+//
+// From version 0:
+//   - nil council slices are replaced with empty slices.
+//   - Gini is set to DefaultGiniCoefficient (unknown), so that it is recomputed from the staking amounts.
+func (s *StakingInfo) Upgrade() {
+	if s.SchemaVersion >= StakingInfoSchemaVersion {
+		return
+	}
+
+	if s.SchemaVersion == 0 {
+		if s.CouncilNodeAddrs == nil {
+			s.CouncilNodeAddrs = make([]common.Address, 0)
+		}
+		if s.CouncilStakingAddrs == nil {
+			s.CouncilStakingAddrs = make([]common.Address, 0)
+		}
+		if s.CouncilRewardAddrs == nil {
+			s.CouncilRewardAddrs = make([]common.Address, 0)
+		}
+		if s.CouncilStakingAmounts == nil {
+			s.CouncilStakingAmounts = make([]uint64, 0)
+		}
+		s.Gini = DefaultGiniCoefficient
+	}
+
+	s.SchemaVersion = StakingInfoSchemaVersion
+}
+
+// Validate checks that the council entries are aligned and have unique node addresses and staking addresses.
+func (s *StakingInfo) Validate() error {
+	if err := s.validateLengths(); err != nil {
+		return err
+	}
+	if addr, ok := findDuplicateAddress(s.CouncilNodeAddrs); ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateNodeAddr, addr.String())
+	}
+	if addr, ok := findDuplicateAddress(s.CouncilStakingAddrs); ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateStakingAddr, addr.String())
+	}
+	return nil
+}
+
+// validateRelaxed checks staking info given from outside of the node like Validate, but it only warns on
+// a duplicate staking address, which newStakingInfo keeps as it is for consensus.
+func (s *StakingInfo) validateRelaxed() error {
+	if err := s.validateLengths(); err != nil {
+		return err
+	}
+	if addr, ok := findDuplicateAddress(s.CouncilNodeAddrs); ok {
+		return fmt.Errorf("%w: %s", ErrDuplicateNodeAddr, addr.String())
+	}
+	if addr, ok := findDuplicateAddress(s.CouncilStakingAddrs); ok {
+		logger.Warn("Duplicate staking address in stakingInfo; its stake is counted more than once", "blockNum", s.BlockNum, "stakingAddr", addr)
+	}
+	return nil
+}
+
+// validateLengths checks that the council entries have the same length,
+// which is assumed by all the methods indexing them by a council index.
+func (s *StakingInfo) validateLengths() error {
+	n := len(s.CouncilNodeAddrs)
+	if len(s.CouncilStakingAddrs) != n || len(s.CouncilRewardAddrs) != n || len(s.CouncilStakingAmounts) != n {
+		return fmt.Errorf("%w: nodes %d, stakings %d, rewards %d, amounts %d", ErrCouncilLengthMismatch,
+			n, len(s.CouncilStakingAddrs), len(s.CouncilRewardAddrs), len(s.CouncilStakingAmounts))
+	}
+	return nil
+}
+
+// findDuplicateAddress returns the first address appearing more than once in the given addresses.
+func findDuplicateAddress(addrs []common.Address) (common.Address, bool) {
+	seen := make(map[common.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		if _, ok := seen[addr]; ok {
+			return addr, true
+		}
+		seen[addr] = struct{}{}
+	}
+	return common.Address{}, false
+}
+
 func (s *StakingInfo) GetIndexByNodeAddress(nodeAddress common.Address) (int, error) {
 	for i, addr := range s.CouncilNodeAddrs {
 		if addr == nodeAddress {
@@ -175,6 +404,65 @@ func (s *StakingInfo) GetStakingAmountByNodeId(nodeAddress common.Address) (uint
 	return s.CouncilStakingAmounts[i], nil
 }
 
+// GetRewardAddrByNodeId returns the reward address of the given council node.
+// Council nodes of a consolidated node share the same reward address.
+func (s *StakingInfo) GetRewardAddrByNodeId(nodeAddress common.Address) (common.Address, error) {
+	i, err := s.GetIndexByNodeAddress(nodeAddress)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return s.CouncilRewardAddrs[i], nil
+}
+
+// GetStakingAddrByNodeId returns the staking address of the given council node.
+func (s *StakingInfo) GetStakingAddrByNodeId(nodeAddress common.Address) (common.Address, error) {
+	i, err := s.GetIndexByNodeAddress(nodeAddress)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return s.CouncilStakingAddrs[i], nil
+}
+
+// StakingAmountKLAY returns the staking amount of the i-th council node in KLAY.
+// Staking amounts are truncated to whole KLAY when staking info is computed, so sub-KLAY precision is discarded
+// and amounts in smaller units (see StakingAmountSton and StakingAmountPeb) are always multiples of a KLAY.
+// It returns nil if i is out of range.
+func (s *StakingInfo) StakingAmountKLAY(i int) *big.Int {
+	return s.stakingAmountIn(i, 1)
+}
+
+// StakingAmountSton returns the staking amount of the i-th council node in ston (Gpeb).
+// It returns nil if i is out of range.
+func (s *StakingInfo) StakingAmountSton(i int) *big.Int {
+	return s.stakingAmountIn(i, params.KLAY/params.Ston)
+}
+
+// StakingAmountPeb returns the staking amount of the i-th council node in peb.
+// It returns nil if i is out of range.
+func (s *StakingInfo) StakingAmountPeb(i int) *big.Int {
+	return s.stakingAmountIn(i, params.KLAY/params.Peb)
+}
+
+// stakingAmountIn returns the staking amount of the i-th council node multiplied by unitsPerKLAY.
+func (s *StakingInfo) stakingAmountIn(i int, unitsPerKLAY int64) *big.Int {
+	if i < 0 || i >= len(s.CouncilStakingAmounts) {
+		return nil
+	}
+	amount := new(big.Int).SetUint64(s.CouncilStakingAmounts[i])
+	return amount.Mul(amount, big.NewInt(unitsPerKLAY))
+}
+
+// TotalStaking returns the sum of the staking amounts of the council in KLAY.
+// It is a big.Int since the sum over a large council can exceed the range of uint64.
+func (s *StakingInfo) TotalStaking() *big.Int {
+	total := new(big.Int)
+	amount := new(big.Int)
+	for _, a := range s.CouncilStakingAmounts {
+		total.Add(total, amount.SetUint64(a))
+	}
+	return total
+}
+
 func (s *StakingInfo) String() string {
 	j, err := json.Marshal(s)
 	if err != nil {
@@ -183,9 +471,69 @@ func (s *StakingInfo) String() string {
 	return string(j)
 }
 
+// SortCanonical sorts the council entries in place by node address, and then by staking address.
+// The council slices are kept aligned, so the result represents the same council.
+func (s *StakingInfo) SortCanonical() {
+	sort.Sort(councilSorter{s})
+}
+
+// councilSorter sorts the council entries of a StakingInfo keeping the slices aligned.
+type councilSorter struct{ s *StakingInfo }
+
+func (c councilSorter) Len() int { return len(c.s.CouncilNodeAddrs) }
+func (c councilSorter) Less(i, j int) bool {
+	if cmp := bytes.Compare(c.s.CouncilNodeAddrs[i].Bytes(), c.s.CouncilNodeAddrs[j].Bytes()); cmp != 0 {
+		return cmp < 0
+	}
+	return bytes.Compare(c.s.CouncilStakingAddrs[i].Bytes(), c.s.CouncilStakingAddrs[j].Bytes()) < 0
+}
+
+func (c councilSorter) Swap(i, j int) {
+	s := c.s
+	s.CouncilNodeAddrs[i], s.CouncilNodeAddrs[j] = s.CouncilNodeAddrs[j], s.CouncilNodeAddrs[i]
+	s.CouncilStakingAddrs[i], s.CouncilStakingAddrs[j] = s.CouncilStakingAddrs[j], s.CouncilStakingAddrs[i]
+	s.CouncilRewardAddrs[i], s.CouncilRewardAddrs[j] = s.CouncilRewardAddrs[j], s.CouncilRewardAddrs[i]
+	s.CouncilStakingAmounts[i], s.CouncilStakingAmounts[j] = s.CouncilStakingAmounts[j], s.CouncilStakingAmounts[i]
+}
+
+// stakingInfoContent is the content of a StakingInfo identifying a council and its stakes.
+type stakingInfoContent struct {
+	CouncilNodeAddrs      []common.Address
+	CouncilStakingAddrs   []common.Address
+	CouncilRewardAddrs    []common.Address
+	KIRAddr               common.Address
+	PoCAddr               common.Address
+	UseGini               bool
+	CouncilStakingAmounts []uint64
+}
+
+// CanonicalContentKey returns a stable key over all fields except BlockNum and the derived Gini.
+// Two StakingInfos having the same council and stakes produce the same key regardless of
+// their block numbers and the order of council entries. The receiver is not modified.
+func (s *StakingInfo) CanonicalContentKey() string {
+	sorted := &StakingInfo{
+		CouncilNodeAddrs:      append([]common.Address{}, s.CouncilNodeAddrs...),
+		CouncilStakingAddrs:   append([]common.Address{}, s.CouncilStakingAddrs...),
+		CouncilRewardAddrs:    append([]common.Address{}, s.CouncilRewardAddrs...),
+		CouncilStakingAmounts: append([]uint64{}, s.CouncilStakingAmounts...),
+	}
+	sorted.SortCanonical()
+
+	enc, err := rlp.EncodeToBytes(&stakingInfoContent{
+		sorted.CouncilNodeAddrs, sorted.CouncilStakingAddrs, sorted.CouncilRewardAddrs,
+		s.KIRAddr, s.PoCAddr, s.UseGini, sorted.CouncilStakingAmounts,
+	})
+	if err != nil {
+		// It never happens since all fields are rlp serializable.
+		logger.Error("failed to encode staking info content", "err", err)
+		return ""
+	}
+	return crypto.Keccak256Hash(enc).Hex()
+}
+
 func (s *StakingInfo) EncodeRLP(w io.Writer) error {
 	// float64 is not rlp serializable, so it converts to bytes
-	return rlp.Encode(w, &stakingInfoRLP{s.BlockNum, s.CouncilNodeAddrs, s.CouncilStakingAddrs, s.CouncilRewardAddrs, s.KIRAddr, s.PoCAddr, s.UseGini, math.Float64bits(s.Gini), s.CouncilStakingAmounts})
+	return rlp.Encode(w, &stakingInfoRLP{s.BlockNum, s.CouncilNodeAddrs, s.CouncilStakingAddrs, s.CouncilRewardAddrs, s.KIRAddr, s.PoCAddr, s.UseGini, math.Float64bits(s.Gini), s.CouncilStakingAmounts, s.SchemaVersion, nil})
 }
 
 func (s *StakingInfo) DecodeRLP(st *rlp.Stream) error {
@@ -197,17 +545,39 @@ func (s *StakingInfo) DecodeRLP(st *rlp.Stream) error {
 	s.CouncilNodeAddrs, s.CouncilStakingAddrs, s.CouncilRewardAddrs = dec.CouncilNodeAddrs, dec.CouncilStakingAddrs, dec.CouncilRewardAddrs
 	s.KIRAddr, s.PoCAddr, s.UseGini, s.Gini = dec.KIRAddr, dec.PoCAddr, dec.UseGini, math.Float64frombits(dec.Gini)
 	s.CouncilStakingAmounts = dec.CouncilStakingAmounts
-	return nil
+	s.SchemaVersion = dec.SchemaVersion
+	// reject a corrupted encoding here, not to panic later by indexing the council entries
+	return s.validateLengths()
+}
+
+// ConsolidationRatio returns the number of consolidated nodes divided by the number of council nodes.
+// 1.0 means that no reward address is shared, and a smaller value means more nodes share reward addresses.
+// For example, it is 0.5 if four council nodes share two reward addresses.
+// It returns 1.0 for an empty council, as there is nothing consolidated.
+func (s *StakingInfo) ConsolidationRatio() float64 {
+	if len(s.CouncilNodeAddrs) == 0 {
+		return 1.0
+	}
+	return float64(len(s.GetConsolidatedStakingInfo().GetAllNodes())) / float64(len(s.CouncilNodeAddrs))
+}
+
+// addStakingAmounts returns the sum of two staking amounts. The sum saturates at math.MaxUint64
+// instead of wrapping around, so that a sum of many staking amounts never becomes smaller than its terms.
+func addStakingAmounts(a, b uint64) uint64 {
+	if b > math.MaxUint64-a {
+		logger.Warn("Sum of staking amounts saturated", "a", a, "b", b)
+		return math.MaxUint64
+	}
+	return a + b
 }
 
 func (s *StakingInfo) GetConsolidatedStakingInfo() *ConsolidatedStakingInfo {
 	c := &ConsolidatedStakingInfo{
-		nodes:     make([]consolidatedNode, 0),
-		nodeIndex: make(map[common.Address]int),
+		nodes:       make([]ConsolidatedNode, 0),
+		nodeIndex:   make(map[common.Address]int),
+		rewardIndex: make(map[common.Address]int),
 	}
 
-	rewardIndex := make(map[common.Address]int) // temporarily map rewardAddr -> index in []nodes
-
 	for j := 0; j < len(s.CouncilNodeAddrs); j++ {
 		var (
 			nodeAddr      = s.CouncilNodeAddrs[j]
@@ -215,51 +585,325 @@ func (s *StakingInfo) GetConsolidatedStakingInfo() *ConsolidatedStakingInfo {
 			rewardAddr    = s.CouncilRewardAddrs[j]
 			stakingAmount = s.CouncilStakingAmounts[j]
 		)
-		if idx, ok := rewardIndex[rewardAddr]; !ok {
-			c.nodes = append(c.nodes, consolidatedNode{
+		if idx, ok := c.rewardIndex[rewardAddr]; !ok {
+			c.nodes = append(c.nodes, ConsolidatedNode{
 				NodeAddrs:     []common.Address{nodeAddr},
 				StakingAddrs:  []common.Address{stakingAddr},
 				RewardAddr:    rewardAddr,
 				StakingAmount: stakingAmount,
 			})
 			c.nodeIndex[nodeAddr] = len(c.nodes) - 1 // point to new element
-			rewardIndex[rewardAddr] = len(c.nodes) - 1
+			c.rewardIndex[rewardAddr] = len(c.nodes) - 1
 		} else {
 			c.nodes[idx].NodeAddrs = append(c.nodes[idx].NodeAddrs, nodeAddr)
 			c.nodes[idx].StakingAddrs = append(c.nodes[idx].StakingAddrs, stakingAddr)
-			c.nodes[idx].StakingAmount += stakingAmount
+			c.nodes[idx].StakingAmount = addStakingAmounts(c.nodes[idx].StakingAmount, stakingAmount)
 			c.nodeIndex[nodeAddr] = idx // point to existing element
 		}
 	}
 	return c
 }
 
-func (c *ConsolidatedStakingInfo) GetAllNodes() []consolidatedNode {
+// GetAllNodes returns the consolidated nodes in the order their reward addresses first appear in the council,
+// which depends on the order of StakingInfo.CouncilNodeAddrs. After SortCanonical, they are in a canonical order.
+func (c *ConsolidatedStakingInfo) GetAllNodes() []ConsolidatedNode {
 	return c.nodes
 }
 
-func (c *ConsolidatedStakingInfo) GetConsolidatedNode(nodeAddr common.Address) *consolidatedNode {
+// SortCanonical sorts the consolidated nodes by reward address, and the node and staking addresses of each
+// consolidated node by node address, so that consolidated views of the same council can be compared across nodes
+// regardless of the order of the council.
+func (c *ConsolidatedStakingInfo) SortCanonical() {
+	for i := range c.nodes {
+		sort.Sort(consolidatedNodeSorter{&c.nodes[i]})
+	}
+	sort.Slice(c.nodes, func(i, j int) bool {
+		return bytes.Compare(c.nodes[i].RewardAddr.Bytes(), c.nodes[j].RewardAddr.Bytes()) < 0
+	})
+
+	for i, node := range c.nodes {
+		for _, nodeAddr := range node.NodeAddrs {
+			c.nodeIndex[nodeAddr] = i
+		}
+		c.rewardIndex[node.RewardAddr] = i
+	}
+}
+
+// consolidatedNodeSorter sorts the node and staking addresses of a ConsolidatedNode keeping them aligned.
+type consolidatedNodeSorter struct{ n *ConsolidatedNode }
+
+func (c consolidatedNodeSorter) Len() int { return len(c.n.NodeAddrs) }
+func (c consolidatedNodeSorter) Less(i, j int) bool {
+	if cmp := bytes.Compare(c.n.NodeAddrs[i].Bytes(), c.n.NodeAddrs[j].Bytes()); cmp != 0 {
+		return cmp < 0
+	}
+	return bytes.Compare(c.n.StakingAddrs[i].Bytes(), c.n.StakingAddrs[j].Bytes()) < 0
+}
+
+func (c consolidatedNodeSorter) Swap(i, j int) {
+	n := c.n
+	n.NodeAddrs[i], n.NodeAddrs[j] = n.NodeAddrs[j], n.NodeAddrs[i]
+	n.StakingAddrs[i], n.StakingAddrs[j] = n.StakingAddrs[j], n.StakingAddrs[i]
+}
+
+func (c *ConsolidatedStakingInfo) GetConsolidatedNode(nodeAddr common.Address) *ConsolidatedNode {
 	if idx, ok := c.nodeIndex[nodeAddr]; ok {
 		return &c.nodes[idx]
 	}
 	return nil
 }
 
+// GetConsolidatedNodeByRewardAddr returns the consolidated node of the given reward address, whose StakingAmount
+// is the total staking amount backing the reward address. It returns nil if no council node has the reward address.
+func (c *ConsolidatedStakingInfo) GetConsolidatedNodeByRewardAddr(rewardAddr common.Address) *ConsolidatedNode {
+	if idx, ok := c.rewardIndex[rewardAddr]; ok {
+		return &c.nodes[idx]
+	}
+	return nil
+}
+
+// NodeAddrsByRewardAddr returns the node addresses consolidated under each reward address.
+// The returned slices are copies, so modifying them does not affect the ConsolidatedStakingInfo.
+func (c *ConsolidatedStakingInfo) NodeAddrsByRewardAddr() map[common.Address][]common.Address {
+	m := make(map[common.Address][]common.Address, len(c.nodes))
+	for _, node := range c.nodes {
+		nodeAddrs := make([]common.Address, len(node.NodeAddrs))
+		copy(nodeAddrs, node.NodeAddrs)
+		m[node.RewardAddr] = nodeAddrs
+	}
+	return m
+}
+
+// TotalStaking returns the sum of the staking amounts of the consolidated nodes in KLAY.
+// It saturates at math.MaxUint64; use StakingInfo.TotalStaking for the exact sum.
+func (c *ConsolidatedStakingInfo) TotalStaking() uint64 {
+	total := uint64(0)
+	for _, node := range c.nodes {
+		total = addStakingAmounts(total, node.StakingAmount)
+	}
+	return total
+}
+
+// MedianStaking returns the median of the staking amounts of the consolidated nodes, i.e. per reward address, in KLAY.
+// For an even number of consolidated nodes, the mean of the two middle amounts rounded down is returned.
+// It returns 0 if there is no consolidated node.
+func (c *ConsolidatedStakingInfo) MedianStaking() uint64 {
+	if len(c.nodes) == 0 {
+		return 0
+	}
+
+	amounts := make([]uint64, len(c.nodes))
+	for i, node := range c.nodes {
+		amounts[i] = node.StakingAmount
+	}
+	sort.Slice(amounts, func(i, j int) bool { return amounts[i] < amounts[j] })
+
+	mid := len(amounts) / 2
+	if len(amounts)%2 == 1 {
+		return amounts[mid]
+	}
+	a, b := amounts[mid-1], amounts[mid]
+	return a/2 + b/2 + (a%2+b%2)/2 // not to overflow
+}
+
 // Calculate Gini coefficient of the StakingAmounts.
 // Only amounts greater or equal to `minStake` are included in the calculation.
 // Set `minStake` to 0 to calculate Gini coefficient of all amounts.
 func (c *ConsolidatedStakingInfo) CalcGiniCoefficientMinStake(minStake uint64) float64 {
-	var amounts []float64
+	return c.CalcGiniCoefficientMinStakeWith(minStake, DefaultGiniCalculator)
+}
+
+// CalcGiniCoefficientMinStakeWith is like CalcGiniCoefficientMinStake, but it uses the given GiniCalculator.
+func (c *ConsolidatedStakingInfo) CalcGiniCoefficientMinStakeWith(minStake uint64, calc GiniCalculator) float64 {
+	nodes := c.EligibleNodes(minStake)
+	if len(nodes) == 0 {
+		return DefaultGiniCoefficient
+	}
+
+	amounts := make([]float64, len(nodes))
+	for i, node := range nodes {
+		amounts[i] = float64(node.StakingAmount)
+	}
+	return calc.Calc(amounts)
+}
+
+// EligibleNodes returns the consolidated nodes whose staking amount is greater than or equal to `minStake`,
+// in the order of the consolidated nodes.
+func (c *ConsolidatedStakingInfo) EligibleNodes(minStake uint64) []ConsolidatedNode {
+	nodes := make([]ConsolidatedNode, 0, len(c.nodes))
+	for _, node := range c.nodes {
+		if node.StakingAmount >= minStake {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// CountAboveMinStake returns the number of the consolidated nodes whose staking amount is greater than
+// or equal to `minStake`.
+func (c *ConsolidatedStakingInfo) CountAboveMinStake(minStake uint64) int {
+	count := 0
+	for _, node := range c.nodes {
+		if node.StakingAmount >= minStake {
+			count++
+		}
+	}
+	return count
+}
+
+// LorenzPoints returns the points of the Lorenz curve of the StakingAmounts.
+// Each point is a pair of (cumulative population fraction, cumulative stake fraction)
+// over the nodes sorted by staking amount in ascending order. The points start at (0,0)
+// and end at (1,1). Only amounts greater or equal to `minStake` are included.
+// It returns nil if there is no eligible node or the eligible nodes have no stake.
+func (c *ConsolidatedStakingInfo) LorenzPoints(minStake uint64) [][2]float64 {
+	var amounts float64Slice
+	total := float64(0)
 	for _, node := range c.nodes {
 		if node.StakingAmount >= minStake {
 			amounts = append(amounts, float64(node.StakingAmount))
+			total += float64(node.StakingAmount)
 		}
 	}
+	if len(amounts) == 0 || total == 0 {
+		return nil
+	}
+	sort.Sort(amounts)
+
+	points := make([][2]float64, 0, len(amounts)+1)
+	points = append(points, [2]float64{0, 0})
+	cumulative := float64(0)
+	for i, amount := range amounts {
+		cumulative += amount
+		points = append(points, [2]float64{float64(i+1) / float64(len(amounts)), cumulative / total})
+	}
+	// avoid floating point errors on the last point
+	points[len(points)-1] = [2]float64{1, 1}
+	return points
+}
 
-	if len(amounts) == 0 {
-		return DefaultGiniCoefficient
+// LorenzCurve returns the points of LorenzPoints as two slices, the cumulative population fractions and
+// the cumulative stake fractions, for plotting. Both are in ascending order. Twice the area between the curve
+// and the line of equality is the Gini coefficient of CalcGiniCoefficientMinStake with the same minStake,
+// before rounding. It returns nil slices if there is no eligible node or the eligible nodes have no stake.
+func (c *ConsolidatedStakingInfo) LorenzCurve(minStake uint64) ([]float64, []float64) {
+	points := c.LorenzPoints(minStake)
+	if points == nil {
+		return nil, nil
 	}
-	return CalcGiniCoefficient(amounts)
+
+	population := make([]float64, len(points))
+	stake := make([]float64, len(points))
+	for i, point := range points {
+		population[i], stake[i] = point[0], point[1]
+	}
+	return population, stake
+}
+
+// SortedByStake returns a copy of the consolidated nodes sorted by staking amount in descending order.
+// Nodes with the same staking amount keep their original order.
+func (c *ConsolidatedStakingInfo) SortedByStake() []ConsolidatedNode {
+	nodes := make([]ConsolidatedNode, len(c.nodes))
+	copy(nodes, c.nodes)
+	sort.SliceStable(nodes, func(i, j int) bool {
+		return nodes[i].StakingAmount > nodes[j].StakingAmount
+	})
+	return nodes
+}
+
+// TopN returns a copy of the n consolidated nodes with the highest staking amounts in descending order.
+// Ties are broken by reward address in ascending order, so the result does not depend on the order of the council.
+// All the nodes are returned if n is larger than the number of the nodes.
+func (c *ConsolidatedStakingInfo) TopN(n int) []ConsolidatedNode {
+	if n <= 0 {
+		return []ConsolidatedNode{}
+	}
+	nodes := make([]ConsolidatedNode, len(c.nodes))
+	copy(nodes, c.nodes)
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].StakingAmount != nodes[j].StakingAmount {
+			return nodes[i].StakingAmount > nodes[j].StakingAmount
+		}
+		return bytes.Compare(nodes[i].RewardAddr.Bytes(), nodes[j].RewardAddr.Bytes()) < 0
+	})
+	if n < len(nodes) {
+		nodes = nodes[:n]
+	}
+	return nodes
+}
+
+// StakeToReachRank returns the additional staking amount required for the node of the given reward address
+// to be ranked at `targetRank` (1-based) or higher when sorted by staking amount in descending order.
+// A tie is broken against the given node, so the node has to stake more than the node currently at the rank.
+// The returned amount is negative if the node is already ranked higher than required.
+func (c *ConsolidatedStakingInfo) StakeToReachRank(rewardAddr common.Address, targetRank int) (int64, error) {
+	sorted := c.SortedByStake()
+	if targetRank < 1 || targetRank > len(sorted) {
+		return 0, ErrRankOutOfRange
+	}
+
+	var (
+		found  bool
+		amount uint64
+		others = make([]uint64, 0, len(sorted))
+	)
+	for _, node := range sorted {
+		if node.RewardAddr == rewardAddr {
+			found, amount = true, node.StakingAmount
+			continue
+		}
+		others = append(others, node.StakingAmount)
+	}
+	if !found {
+		return 0, ErrAddrNotInStakingInfo
+	}
+
+	// The lowest rank needs no stake. Otherwise, the node has to outstake the other node at the rank.
+	required := int64(0)
+	if targetRank <= len(others) {
+		required = int64(others[targetRank-1]) + 1
+	}
+	return required - int64(amount), nil
+}
+
+// DecentralizationReport returns the decentralization metrics of the consolidated nodes at once.
+// Only amounts greater or equal to `minStake` are included in the stake-weighted metrics.
+// If there is no eligible stake, Gini is DefaultGiniCoefficient and the other stake-weighted metrics are zero.
+func (c *ConsolidatedStakingInfo) DecentralizationReport(minStake uint64) DecentralizationReport {
+	report := DecentralizationReport{
+		Gini:      DefaultGiniCoefficient,
+		NodeCount: len(c.nodes),
+	}
+
+	var (
+		amounts      float64Slice
+		total        float64
+		sumOfSquares float64
+	)
+	for _, node := range c.nodes {
+		if node.StakingAmount >= minStake {
+			amount := float64(node.StakingAmount)
+			amounts = append(amounts, amount)
+			total += amount
+			sumOfSquares += amount * amount
+		}
+	}
+	report.EligibleNodeCount = len(amounts)
+	if total == 0 {
+		return report
+	}
+	report.Gini = CalcGiniCoefficient(amounts) // sorts amounts in ascending order
+	report.EffectiveStakers = total * total / sumOfSquares
+
+	cumulative := float64(0)
+	for i := len(amounts) - 1; i >= 0; i-- {
+		cumulative += amounts[i]
+		report.NakamotoCoefficient++
+		if cumulative*3 > total {
+			break
+		}
+	}
+	return report
 }
 
 func (c *ConsolidatedStakingInfo) String() string {
@@ -270,13 +914,75 @@ func (c *ConsolidatedStakingInfo) String() string {
 	return string(j)
 }
 
+const (
+	giniDefaultDecimals = 2  // decimal places of the Gini coefficient used by the consensus
+	giniMaxDecimals     = 15 // decimal places a float64 can hold
+)
+
 type float64Slice []float64
 
 func (p float64Slice) Len() int           { return len(p) }
 func (p float64Slice) Less(i, j int) bool { return p[i] < p[j] }
 func (p float64Slice) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
+// GiniCalculator calculates the inequality of staking amounts, which is used as the Gini coefficient of StakingInfo.
+// Calc may reorder the given amounts. It returns DefaultGiniCoefficient if there is no stake at all.
+type GiniCalculator interface {
+	Calc(amounts []float64) float64
+}
+
+// GiniCalculatorFunc is an adapter to use a function as a GiniCalculator.
+type GiniCalculatorFunc func(amounts []float64) float64
+
+func (f GiniCalculatorFunc) Calc(amounts []float64) float64 {
+	return f(amounts)
+}
+
+// DefaultGiniCalculator calculates the Gini coefficient by CalcGiniCoefficient.
+var DefaultGiniCalculator GiniCalculator = GiniCalculatorFunc(func(amounts []float64) float64 {
+	return CalcGiniCoefficient(amounts)
+})
+
+// CalcGiniCoefficient returns the Gini coefficient of the given staking amounts rounded to two decimal places.
+// It is used to adjust the staking amounts for proposer selection, so the precision must not be changed.
 func CalcGiniCoefficient(stakingAmount float64Slice) float64 {
+	return CalcGiniCoefficientWithPrecision(stakingAmount, giniDefaultDecimals)
+}
+
+// GiniAdjustedStakingAmount returns the staking amount reflecting the Gini coefficient of the staking amounts,
+// which lowers the share of large staking amounts when the weights of proposers are calculated.
+func GiniAdjustedStakingAmount(stakingAmount float64, gini float64) float64 {
+	return math.Round(math.Pow(stakingAmount, 1.0/(1+gini)))
+}
+
+// ProposerWeight returns the weight of a proposer, i.e. its staking amount out of the total staking amount
+// in percent rounded. A proposer has the weight 1 at least if the total staking amount is positive.
+func ProposerWeight(stakingAmount float64, totalStaking float64) uint64 {
+	if totalStaking <= 0 {
+		return 0
+	}
+	weight := uint64(math.Round(stakingAmount * 100 / totalStaking))
+	if weight <= 0 {
+		// A validator, who holds zero or small stake, has minimum weight, 1.
+		weight = 1
+	}
+	return weight
+}
+
+// CalcGiniCoefficientWithPrecision returns the Gini coefficient of the given staking amounts rounded to the given
+// number of decimal places, e.g. for analytics. The number of decimal places is not rejected but clamped to
+// [0, 15] with a warning, i.e. a negative number is treated as 0 and a number greater than 15 as 15,
+// since a float64 has no more than 15 significant decimal digits.
+func CalcGiniCoefficientWithPrecision(stakingAmount float64Slice, decimals int) float64 {
+	if decimals < 0 || decimals > giniMaxDecimals {
+		logger.Warn("Invalid decimal places of Gini coefficient; clamped", "decimals", decimals, "max", giniMaxDecimals)
+		if decimals < 0 {
+			decimals = 0
+		} else {
+			decimals = giniMaxDecimals
+		}
+	}
+
 	sort.Sort(stakingAmount)
 
 	// calculate gini coefficient
@@ -289,8 +995,14 @@ func CalcGiniCoefficient(stakingAmount float64Slice) float64 {
 		subSum = subSum + x
 	}
 
+	// no stake at all, e.g. no amount is given or all staking accounts are drained
+	if subSum == 0 {
+		return DefaultGiniCoefficient
+	}
+
 	result := sumOfAbsoluteDifferences / subSum / float64(len(stakingAmount))
-	result = math.Round(result*100) / 100
+	scale := math.Pow10(decimals)
+	result = math.Round(result*scale) / scale
 
 	return result
 }
@@ -16,7 +16,10 @@
 
 package reward
 
-import "sync"
+import (
+	"sort"
+	"sync"
+)
 
 const (
 	maxStakingCache = 4
@@ -25,12 +28,19 @@ const (
 type stakingInfoCache struct {
 	cells       map[uint64]*StakingInfo
 	minBlockNum uint64
+	maxSize     int
 	lock        sync.RWMutex
 }
 
 func newStakingInfoCache() *stakingInfoCache {
+	return newStakingInfoCacheWithSize(maxStakingCache)
+}
+
+// newStakingInfoCacheWithSize creates a stakingInfoCache holding at most the given number of staking info.
+func newStakingInfoCacheWithSize(size int) *stakingInfoCache {
 	stakingCache := new(stakingInfoCache)
 	stakingCache.cells = make(map[uint64]*StakingInfo)
+	stakingCache.maxSize = size
 	return stakingCache
 }
 
@@ -44,6 +54,76 @@ func (sc *stakingInfoCache) get(blockNum uint64) *StakingInfo {
 	return nil
 }
 
+func (sc *stakingInfoCache) len() int {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return len(sc.cells)
+}
+
+// dump returns all cached staking info sorted by block number.
+func (sc *stakingInfoCache) dump() []*StakingInfo {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+
+	infos := make([]*StakingInfo, 0, len(sc.cells))
+	for _, s := range sc.cells {
+		infos = append(infos, s)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].BlockNum < infos[j].BlockNum })
+	return infos
+}
+
+// getLatestBefore returns the cached staking info of the largest block number less than the given one.
+func (sc *stakingInfoCache) getLatestBefore(blockNum uint64) *StakingInfo {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+
+	var latest *StakingInfo
+	for num, s := range sc.cells {
+		if num < blockNum && (latest == nil || num > latest.BlockNum) {
+			latest = s
+		}
+	}
+	return latest
+}
+
+// remove evicts the staking info of the given block number. It returns false if it is not cached.
+func (sc *stakingInfoCache) remove(blockNum uint64) bool {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	if _, ok := sc.cells[blockNum]; !ok {
+		return false
+	}
+	delete(sc.cells, blockNum)
+
+	sc.minBlockNum = 0
+	first := true
+	for num := range sc.cells {
+		if first || num < sc.minBlockNum {
+			sc.minBlockNum, first = num, false
+		}
+	}
+	return true
+}
+
+// replace replaces the cached staking info with the updated one of the same block number, only if the cached one
+// is still old. It returns the staking info cached after the call, or updated if none is cached.
+func (sc *stakingInfoCache) replace(old, updated *StakingInfo) *StakingInfo {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	cached, ok := sc.cells[updated.BlockNum]
+	if !ok {
+		return updated
+	}
+	if cached == old {
+		sc.cells[updated.BlockNum] = updated
+		return updated
+	}
+	return cached
+}
+
 func (sc *stakingInfoCache) add(stakingInfo *StakingInfo) {
 	sc.lock.Lock()
 	defer sc.lock.Unlock()
@@ -54,7 +134,7 @@ func (sc *stakingInfoCache) add(stakingInfo *StakingInfo) {
 		return
 	}
 
-	if len(sc.cells) >= maxStakingCache {
+	if len(sc.cells) >= sc.maxSize {
 		delete(sc.cells, sc.minBlockNum)
 	}
 	sc.minBlockNum = stakingInfo.BlockNum
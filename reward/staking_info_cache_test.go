@@ -120,3 +120,37 @@ func TestStakingInfoCache_Get(t *testing.T) {
 		assert.Nil(t, testStakingInfo)
 	}
 }
+
+func TestStakingInfoCache_Remove(t *testing.T) {
+	stakingInfoCache := newStakingInfoCache()
+
+	for i := uint64(1); i <= 3; i++ {
+		stakingInfoCache.add(newEmptyStakingInfo(i))
+	}
+
+	assert.False(t, stakingInfoCache.remove(4))
+	assert.True(t, stakingInfoCache.remove(1))
+	assert.Nil(t, stakingInfoCache.get(1))
+	assert.Equal(t, uint64(2), stakingInfoCache.minBlockNum)
+}
+
+func TestStakingInfoCache_Replace(t *testing.T) {
+	stakingInfoCache := newStakingInfoCache()
+	old := newEmptyStakingInfo(1)
+	stakingInfoCache.add(old)
+
+	// replaced if the cached one is old
+	updated := old.Clone()
+	assert.True(t, updated == stakingInfoCache.replace(old, updated))
+	assert.True(t, updated == stakingInfoCache.get(1))
+
+	// not replaced if the cached one has been replaced by another
+	assert.True(t, updated == stakingInfoCache.replace(old, old.Clone()))
+	assert.True(t, updated == stakingInfoCache.get(1))
+
+	// not cached if evicted
+	stakingInfoCache.remove(1)
+	another := old.Clone()
+	assert.True(t, another == stakingInfoCache.replace(updated, another))
+	assert.Nil(t, stakingInfoCache.get(1))
+}
@@ -17,23 +17,113 @@
 package reward
 
 import (
+	"bufio"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/klaytn/klaytn/params"
+	"github.com/klaytn/klaytn/rlp"
 )
 
-var ErrStakingDBNotSet = errors.New("stakingInfoDB is not set")
+// maxStakingInfoRecordSize is the maximum size of an RLP-encoded staking info record imported by ImportStakingInfos.
+const maxStakingInfoRecordSize = 4 * 1024 * 1024
+
+var (
+	ErrStakingDBNotSet         = errors.New("stakingInfoDB is not set")
+	ErrStakingDBNotIterable    = errors.New("stakingInfoDB does not support iterating staking info")
+	errStakingInfoRecordTooBig = errors.New("staking info record is too big")
+)
 
 type stakingInfoDB interface {
 	ReadStakingInfo(blockNum uint64) ([]byte, error)
 	WriteStakingInfo(blockNum uint64, stakingInfo []byte) error
 }
 
-func getStakingInfoFromDB(blockNum uint64) (*StakingInfo, error) {
-	if stakingManager.stakingInfoDB == nil {
+// stakingInfoIterator is implemented by a stakingInfoDB which can iterate all stored staking info.
+type stakingInfoIterator interface {
+	IterateStakingInfos(fn func(blockNum uint64, stakingInfo []byte) error) error
+}
+
+// MemoryStakingInfoDB is a stakingInfoDB keeping staking info in memory.
+// It is useful for tests and ephemeral nodes which do not persist staking info.
+type MemoryStakingInfoDB struct {
+	stakingInfos map[uint64][]byte
+	lock         sync.RWMutex
+}
+
+// NewMemoryStakingInfoDB creates an empty MemoryStakingInfoDB.
+func NewMemoryStakingInfoDB() *MemoryStakingInfoDB {
+	return &MemoryStakingInfoDB{
+		stakingInfos: make(map[uint64][]byte),
+	}
+}
+
+// ReadStakingInfo returns a copy of the staking info of the given block number.
+// It returns ErrStakingInfoNotFound if the staking info is not written.
+func (db *MemoryStakingInfoDB) ReadStakingInfo(blockNum uint64) ([]byte, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+
+	stakingInfo, ok := db.stakingInfos[blockNum]
+	if !ok {
+		return nil, ErrStakingInfoNotFound
+	}
+	return append([]byte{}, stakingInfo...), nil
+}
+
+// WriteStakingInfo writes a copy of the given staking info, replacing the one of the same block number.
+func (db *MemoryStakingInfoDB) WriteStakingInfo(blockNum uint64, stakingInfo []byte) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+
+	db.stakingInfos[blockNum] = append([]byte{}, stakingInfo...)
+	return nil
+}
+
+// IterateStakingInfos calls fn with a copy of every written staking info in the order of block numbers.
+// It stops and returns the error if fn returns an error.
+func (db *MemoryStakingInfoDB) IterateStakingInfos(fn func(blockNum uint64, stakingInfo []byte) error) error {
+	db.lock.RLock()
+	blockNums := make([]uint64, 0, len(db.stakingInfos))
+	for blockNum := range db.stakingInfos {
+		blockNums = append(blockNums, blockNum)
+	}
+	db.lock.RUnlock()
+	sort.Slice(blockNums, func(i, j int) bool { return blockNums[i] < blockNums[j] })
+
+	for _, blockNum := range blockNums {
+		stakingInfo, err := db.ReadStakingInfo(blockNum)
+		if err != nil {
+			return err
+		}
+		if err := fn(blockNum, stakingInfo); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readDB returns the database serving staking info reads.
+// The read replica is preferred if it is set.
+func (sm *StakingManager) readDB() stakingInfoDB {
+	if sm.stakingInfoReadDB != nil {
+		return sm.stakingInfoReadDB
+	}
+	return sm.stakingInfoDB
+}
+
+func (sm *StakingManager) getStakingInfoFromDB(blockNum uint64) (*StakingInfo, error) {
+	db := sm.readDB()
+	if db == nil {
 		return nil, ErrStakingDBNotSet
 	}
 
-	jsonByte, err := stakingManager.stakingInfoDB.ReadStakingInfo(blockNum)
+	jsonByte, err := db.ReadStakingInfo(blockNum)
 	if err != nil {
 		return nil, err
 	}
@@ -43,12 +133,24 @@ func getStakingInfoFromDB(blockNum uint64) (*StakingInfo, error) {
 	if err != nil {
 		return nil, err
 	}
+	stakingInfo.Upgrade()
+	if err := stakingInfo.validateLengths(); err != nil {
+		return nil, err
+	}
 
 	return stakingInfo, nil
 }
 
+// AddStakingInfoToDB writes the given staking info to the database of the default StakingManager.
 func AddStakingInfoToDB(stakingInfo *StakingInfo) error {
-	if stakingManager.stakingInfoDB == nil {
+	return GetStakingManager().addStakingInfoToDB(stakingInfo)
+}
+
+func (sm *StakingManager) addStakingInfoToDB(stakingInfo *StakingInfo) error {
+	if sm == nil {
+		return ErrStakingManagerNotSet
+	}
+	if sm.stakingInfoDB == nil {
 		return ErrStakingDBNotSet
 	}
 
@@ -57,10 +159,117 @@ func AddStakingInfoToDB(stakingInfo *StakingInfo) error {
 		return err
 	}
 
-	err = stakingManager.stakingInfoDB.WriteStakingInfo(stakingInfo.BlockNum, marshaledStakingInfo)
+	err = sm.stakingInfoDB.WriteStakingInfo(stakingInfo.BlockNum, marshaledStakingInfo)
 	if err != nil {
 		return err
 	}
 
 	return nil
 }
+
+// ExportStakingInfos writes all staking info stored in the database to w in the order of block numbers,
+// so that they can be imported to another node by ImportStakingInfos instead of being recomputed from the state.
+// Each staking info is written as a record of its RLP encoding prefixed by the 4-byte big-endian length.
+func (sm *StakingManager) ExportStakingInfos(w io.Writer) error {
+	if sm == nil {
+		return ErrStakingManagerNotSet
+	}
+	db := sm.readDB()
+	if db == nil {
+		return ErrStakingDBNotSet
+	}
+	iterator, ok := db.(stakingInfoIterator)
+	if !ok {
+		return ErrStakingDBNotIterable
+	}
+
+	var blockNums []uint64
+	if err := iterator.IterateStakingInfos(func(blockNum uint64, stakingInfo []byte) error {
+		blockNums = append(blockNums, blockNum)
+		return nil
+	}); err != nil {
+		return err
+	}
+	sort.Slice(blockNums, func(i, j int) bool { return blockNums[i] < blockNums[j] })
+
+	bw := bufio.NewWriter(w)
+	for _, blockNum := range blockNums {
+		stakingInfo, err := sm.getStakingInfoFromDB(blockNum)
+		if err != nil {
+			return fmt.Errorf("failed to read staking info. blockNum: %d, err: %w", blockNum, err)
+		}
+		enc, err := rlp.EncodeToBytes(stakingInfo)
+		if err != nil {
+			return err
+		}
+
+		var size [4]byte
+		binary.BigEndian.PutUint32(size[:], uint32(len(enc)))
+		if _, err := bw.Write(size[:]); err != nil {
+			return err
+		}
+		if _, err := bw.Write(enc); err != nil {
+			return err
+		}
+	}
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	logger.Info("Exported staking info", "count", len(blockNums))
+	return nil
+}
+
+// ImportStakingInfos reads staking info records written by ExportStakingInfos from r, and writes them to the database.
+// Every record is validated first, and nothing is written if any of them is invalid. A duplicate staking address
+// is not rejected, since it is kept in the staking info computed from the AddressBook as well.
+// Staking info of an older schema is upgraded before being written.
+func (sm *StakingManager) ImportStakingInfos(r io.Reader) error {
+	if sm == nil {
+		return ErrStakingManagerNotSet
+	}
+	if sm.stakingInfoDB == nil {
+		return ErrStakingDBNotSet
+	}
+
+	var (
+		br    = bufio.NewReader(r)
+		infos []*StakingInfo
+	)
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(br, size[:]); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read the length of staking info record %d: %w", len(infos), err)
+		}
+		n := binary.BigEndian.Uint32(size[:])
+		if n > maxStakingInfoRecordSize {
+			return fmt.Errorf("%w. record: %d, size: %d", errStakingInfoRecordTooBig, len(infos), n)
+		}
+
+		enc := make([]byte, n)
+		if _, err := io.ReadFull(br, enc); err != nil {
+			return fmt.Errorf("failed to read staking info record %d: %w", len(infos), err)
+		}
+		s := new(StakingInfo)
+		if err := rlp.DecodeBytes(enc, s); err != nil {
+			return fmt.Errorf("failed to decode staking info record %d: %w", len(infos), err)
+		}
+		if !params.IsStakingUpdateInterval(s.BlockNum) {
+			return fmt.Errorf("not staking block number. blockNum: %d", s.BlockNum)
+		}
+		if err := s.validateRelaxed(); err != nil {
+			return fmt.Errorf("invalid staking info. blockNum: %d, err: %w", s.BlockNum, err)
+		}
+		infos = append(infos, s)
+	}
+
+	for _, s := range infos {
+		s.Upgrade()
+		if err := sm.addStakingInfoToDB(s); err != nil {
+			return fmt.Errorf("failed to write staking info. blockNum: %d, err: %w", s.BlockNum, err)
+		}
+	}
+	logger.Info("Imported staking info", "count", len(infos))
+	return nil
+}
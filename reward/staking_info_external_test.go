@@ -0,0 +1,58 @@
+// Copyright 2022 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package reward_test
+
+import (
+	"testing"
+
+	"github.com/klaytn/klaytn/common"
+	"github.com/klaytn/klaytn/reward"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestConsolidatedNode_External tests that a package other than reward can enumerate consolidated nodes
+// and read all of their fields, e.g. to display aggregated stake per reward address.
+func TestConsolidatedNode_External(t *testing.T) {
+	n1, n2, n3 := common.HexToAddress("0xa1"), common.HexToAddress("0xa2"), common.HexToAddress("0xa3")
+	s1, s2, s3 := common.HexToAddress("0xb1"), common.HexToAddress("0xb2"), common.HexToAddress("0xb3")
+	r1, r3 := common.HexToAddress("0xc1"), common.HexToAddress("0xc3")
+
+	stakingInfo := &reward.StakingInfo{
+		BlockNum:              86400,
+		CouncilNodeAddrs:      []common.Address{n1, n2, n3},
+		CouncilStakingAddrs:   []common.Address{s1, s2, s3},
+		CouncilRewardAddrs:    []common.Address{r1, r1, r3},
+		CouncilStakingAmounts: []uint64{10, 20, 40},
+		Gini:                  reward.DefaultGiniCoefficient,
+	}
+
+	nodes := stakingInfo.GetConsolidatedStakingInfo().GetAllNodes()
+	assert.Equal(t, []reward.ConsolidatedNode{
+		{NodeAddrs: []common.Address{n1, n2}, StakingAddrs: []common.Address{s1, s2}, RewardAddr: r1, StakingAmount: 30},
+		{NodeAddrs: []common.Address{n3}, StakingAddrs: []common.Address{s3}, RewardAddr: r3, StakingAmount: 40},
+	}, nodes)
+
+	stakeByRewardAddr := make(map[common.Address]uint64)
+	numNodesByRewardAddr := make(map[common.Address]int)
+	for _, node := range nodes {
+		assert.Equal(t, len(node.NodeAddrs), len(node.StakingAddrs))
+		stakeByRewardAddr[node.RewardAddr] += node.StakingAmount
+		numNodesByRewardAddr[node.RewardAddr] += len(node.NodeAddrs)
+	}
+	assert.Equal(t, map[common.Address]uint64{r1: 30, r3: 40}, stakeByRewardAddr)
+	assert.Equal(t, map[common.Address]int{r1: 2, r3: 1}, numNodesByRewardAddr)
+}
@@ -18,11 +18,18 @@ package reward
 
 import (
 	"encoding/json"
+	"errors"
 	"math"
+	"math/big"
 	"testing"
 
+	"github.com/klaytn/klaytn/blockchain"
+	"github.com/klaytn/klaytn/blockchain/state"
+	"github.com/klaytn/klaytn/blockchain/types"
 	"github.com/klaytn/klaytn/common"
 	"github.com/klaytn/klaytn/params"
+	"github.com/klaytn/klaytn/rlp"
+	"github.com/klaytn/klaytn/storage/database"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -72,7 +79,7 @@ func generateStakingInfoTestCases() []stakingInfoTestCase {
 		{
 			stakingInfo: newEmptyStakingInfo(0),
 			expectedConsolidated: &ConsolidatedStakingInfo{
-				nodes:     make([]consolidatedNode, 0),
+				nodes:     make([]ConsolidatedNode, 0),
 				nodeIndex: make(map[common.Address]int),
 			},
 			expectedAmounts: make(map[common.Address]uint64),
@@ -90,9 +97,10 @@ func generateStakingInfoTestCases() []stakingInfoTestCase {
 				UseGini:               true,
 				Gini:                  0.00,
 				CouncilStakingAmounts: []uint64{a1},
+				SchemaVersion:         StakingInfoSchemaVersion,
 			},
 			expectedConsolidated: &ConsolidatedStakingInfo{
-				nodes: []consolidatedNode{
+				nodes: []ConsolidatedNode{
 					{[]common.Address{n1}, []common.Address{s1}, r1, a1},
 				},
 				nodeIndex: map[common.Address]int{n1: 0},
@@ -112,9 +120,10 @@ func generateStakingInfoTestCases() []stakingInfoTestCase {
 				UseGini:               true,
 				Gini:                  0.38, // Gini(10, 20, 40, 80)
 				CouncilStakingAmounts: []uint64{a1, a2, a3, a4},
+				SchemaVersion:         StakingInfoSchemaVersion,
 			},
 			expectedConsolidated: &ConsolidatedStakingInfo{
-				nodes: []consolidatedNode{
+				nodes: []ConsolidatedNode{
 					{[]common.Address{n1}, []common.Address{s1}, r1, a1},
 					{[]common.Address{n2}, []common.Address{s2}, r2, a2},
 					{[]common.Address{n3}, []common.Address{s3}, r3, a3},
@@ -137,9 +146,10 @@ func generateStakingInfoTestCases() []stakingInfoTestCase {
 				UseGini:               true,
 				Gini:                  0.17, // Gini(50, 100)
 				CouncilStakingAmounts: []uint64{a1, a2, a3, a4},
+				SchemaVersion:         StakingInfoSchemaVersion,
 			},
 			expectedConsolidated: &ConsolidatedStakingInfo{
-				nodes: []consolidatedNode{
+				nodes: []ConsolidatedNode{
 					{[]common.Address{n1, n3}, []common.Address{s1, s3}, r1, a1 + a3}, // n1 & n3
 					{[]common.Address{n2, n4}, []common.Address{s2, s4}, r2, a2 + a4}, // n2 & n4
 				},
@@ -160,9 +170,10 @@ func generateStakingInfoTestCases() []stakingInfoTestCase {
 				UseGini:               true,
 				Gini:                  0.41,                     // Gini(20, 2)
 				CouncilStakingAmounts: []uint64{a2, aM, aL, a0}, // aL and a0 should be ignored in Gini calculation
+				SchemaVersion:         StakingInfoSchemaVersion,
 			},
 			expectedConsolidated: &ConsolidatedStakingInfo{
-				nodes: []consolidatedNode{
+				nodes: []ConsolidatedNode{
 					{[]common.Address{n1}, []common.Address{s1}, r1, a2},
 					{[]common.Address{n2}, []common.Address{s2}, r2, aM},
 					{[]common.Address{n3}, []common.Address{s3}, r3, aL},
@@ -242,6 +253,34 @@ func TestStakingInfo_GetStakingAmountByNodeId(t *testing.T) {
 	}
 }
 
+func TestStakingInfo_GetAddrsByNodeId(t *testing.T) {
+	stakingInfo := newEmptyStakingInfo(0)
+	stakingInfo.CouncilNodeAddrs = []common.Address{{0x1}, {0x2}, {0x3}}
+	stakingInfo.CouncilStakingAddrs = []common.Address{{0x11}, {0x12}, {0x13}}
+	stakingInfo.CouncilRewardAddrs = []common.Address{{0x21}, {0x22}, {0x21}} // 0x1 and 0x3 share a reward address
+
+	testCases := []struct {
+		address     common.Address
+		rewardAddr  common.Address
+		stakingAddr common.Address
+		err         error
+	}{
+		{common.Address{0x1}, common.Address{0x21}, common.Address{0x11}, nil},
+		{common.Address{0x2}, common.Address{0x22}, common.Address{0x12}, nil},
+		{common.Address{0x3}, common.Address{0x21}, common.Address{0x13}, nil},
+		{common.Address{0x4}, common.Address{}, common.Address{}, ErrAddrNotInStakingInfo},
+	}
+	for _, tc := range testCases {
+		rewardAddr, err := stakingInfo.GetRewardAddrByNodeId(tc.address)
+		assert.Equal(t, tc.rewardAddr, rewardAddr)
+		assert.Equal(t, tc.err, err)
+
+		stakingAddr, err := stakingInfo.GetStakingAddrByNodeId(tc.address)
+		assert.Equal(t, tc.stakingAddr, stakingAddr)
+		assert.Equal(t, tc.err, err)
+	}
+}
+
 func TestStakingInfo_String(t *testing.T) {
 	// No information loss in String() -> Unmarshal() round trip
 	for _, testcase := range stakingInfoTestCases {
@@ -265,6 +304,8 @@ func TestCalcGiniCoefficient(t *testing.T) {
 		{[]float64{1, 1, 1}, 0.0},
 		{[]float64{0, 8, 0, 0, 0}, 0.8},
 		{[]float64{5, 4, 3, 2, 1}, 0.27},
+		{[]float64{0, 0, 0}, DefaultGiniCoefficient},
+		{[]float64{}, DefaultGiniCoefficient},
 	}
 
 	for i := 0; i < len(testCase); i++ {
@@ -273,6 +314,22 @@ func TestCalcGiniCoefficient(t *testing.T) {
 	}
 }
 
+func TestCalcGiniCoefficientWithPrecision(t *testing.T) {
+	amounts := []float64{5, 4, 3, 2, 1} // Gini = 0.2666...
+
+	assert.Equal(t, 0.27, CalcGiniCoefficientWithPrecision(amounts, 2))
+	assert.Equal(t, 0.2667, CalcGiniCoefficientWithPrecision(amounts, 4))
+	assert.Equal(t, 0.0, CalcGiniCoefficientWithPrecision(amounts, 0))
+	assert.Equal(t, CalcGiniCoefficient(amounts), CalcGiniCoefficientWithPrecision(amounts, 2))
+
+	// decimals out of range are clamped to the boundaries, 0 and 15
+	assert.Equal(t, CalcGiniCoefficientWithPrecision(amounts, 0), CalcGiniCoefficientWithPrecision(amounts, -1))
+	assert.Equal(t, CalcGiniCoefficientWithPrecision(amounts, 0), CalcGiniCoefficientWithPrecision(amounts, -100))
+	assert.Equal(t, CalcGiniCoefficientWithPrecision(amounts, 15), CalcGiniCoefficientWithPrecision(amounts, 16))
+	assert.Equal(t, CalcGiniCoefficientWithPrecision(amounts, 15), CalcGiniCoefficientWithPrecision(amounts, 20))
+	assert.NotEqual(t, CalcGiniCoefficientWithPrecision(amounts, 14), CalcGiniCoefficientWithPrecision(amounts, 15))
+}
+
 func TestGiniReflectToExpectedCCO(t *testing.T) {
 	testCase := []struct {
 		ccoToken        []float64
@@ -368,6 +425,75 @@ func TestStakingInfoJSON(t *testing.T) {
 	}
 }
 
+func TestStakingInfo_Filter(t *testing.T) {
+	src := stakingInfoTestCases[3].stakingInfo // n1 & n3 share r1, n2 & n4 share r2
+	n := src.CouncilNodeAddrs
+	unknown := common.HexToAddress("0x1")
+
+	filtered := src.Filter([]common.Address{n[3], n[0], unknown})
+
+	// entries keep the original order and stay aligned
+	assert.Equal(t, []common.Address{n[0], n[3]}, filtered.CouncilNodeAddrs)
+	assert.Equal(t, []common.Address{src.CouncilStakingAddrs[0], src.CouncilStakingAddrs[3]}, filtered.CouncilStakingAddrs)
+	assert.Equal(t, []common.Address{src.CouncilRewardAddrs[0], src.CouncilRewardAddrs[3]}, filtered.CouncilRewardAddrs)
+	assert.Equal(t, []uint64{src.CouncilStakingAmounts[0], src.CouncilStakingAmounts[3]}, filtered.CouncilStakingAmounts)
+	assert.Nil(t, filtered.Validate())
+
+	assert.Equal(t, src.BlockNum, filtered.BlockNum)
+	assert.Equal(t, src.KIRAddr, filtered.KIRAddr)
+	assert.Equal(t, src.PoCAddr, filtered.PoCAddr)
+	assert.Equal(t, src.UseGini, filtered.UseGini)
+	assert.Equal(t, DefaultGiniCoefficient, filtered.Gini)
+
+	// the source is not modified
+	assert.Equal(t, 4, len(src.CouncilNodeAddrs))
+
+	// no matching node
+	empty := src.Filter(nil)
+	assert.Equal(t, 0, len(empty.CouncilNodeAddrs))
+	assert.Nil(t, empty.Validate())
+}
+
+func TestStakingInfo_Upgrade(t *testing.T) {
+	// A legacy (version 0) record has neither SchemaVersion nor trustworthy Gini
+	legacyJSON := `{"BlockNum":86400,"CouncilNodeAddrs":null,"CouncilStakingAddrs":null,"CouncilRewardAddrs":null,` +
+		`"KIRAddr":"0x136807b12327a8aff9831f09617da1b9d398cda2","PoCAddr":"0x46ba8f7538cd0749e572b2631f9fb4ce3653afb8",` +
+		`"UseGini":true,"Gini":0.5,"CouncilStakingAmounts":null}`
+
+	legacy := new(StakingInfo)
+	require.Nil(t, json.Unmarshal([]byte(legacyJSON), legacy))
+	assert.Equal(t, uint64(0), legacy.SchemaVersion)
+
+	legacy.Upgrade()
+	assert.Equal(t, StakingInfoSchemaVersion, legacy.SchemaVersion)
+	assert.Equal(t, DefaultGiniCoefficient, legacy.Gini)
+	assert.Equal(t, []common.Address{}, legacy.CouncilNodeAddrs)
+	assert.Equal(t, []common.Address{}, legacy.CouncilStakingAddrs)
+	assert.Equal(t, []common.Address{}, legacy.CouncilRewardAddrs)
+	assert.Equal(t, []uint64{}, legacy.CouncilStakingAmounts)
+	assert.Equal(t, uint64(86400), legacy.BlockNum)
+	assert.True(t, legacy.UseGini)
+
+	// The upgraded record survives a round trip, and upgrading it again changes nothing
+	for _, src := range []*StakingInfo{legacy, stakingInfoTestCases[2].stakingInfo} {
+		b, err := json.Marshal(src)
+		require.Nil(t, err)
+
+		dst := new(StakingInfo)
+		require.Nil(t, json.Unmarshal(b, dst))
+		dst.Upgrade()
+		assert.Equal(t, src, dst)
+
+		b, err = rlp.EncodeToBytes(src)
+		require.Nil(t, err)
+
+		dst = new(StakingInfo)
+		require.Nil(t, rlp.DecodeBytes(b, dst))
+		dst.Upgrade()
+		assert.Equal(t, src, dst)
+	}
+}
+
 func TestConsolidatedStakingInfo(t *testing.T) {
 	for _, testcase := range stakingInfoTestCases {
 		expected := testcase.expectedConsolidated
@@ -376,6 +502,9 @@ func TestConsolidatedStakingInfo(t *testing.T) {
 		// Test ConsolidatedStakingInfo
 		assert.Equal(t, expected.nodes, c.nodes)
 		assert.Equal(t, expected.nodeIndex, c.nodeIndex)
+		for _, node := range expected.nodes {
+			assert.Equal(t, node, *c.GetConsolidatedNodeByRewardAddr(node.RewardAddr))
+		}
 
 		// Test CalcGiniCoefficient()
 		expectedGini := testcase.stakingInfo.Gini
@@ -390,3 +519,597 @@ func TestConsolidatedStakingInfo(t *testing.T) {
 		}
 	}
 }
+
+func TestStakingInfo_StakingAmountUnits(t *testing.T) {
+	stakingInfo := stakingInfoTestCases[2].stakingInfo // 10M, 20M, 40M and 80M KLAY
+
+	assert.Equal(t, big.NewInt(10000000), stakingInfo.StakingAmountKLAY(0))
+	assert.Equal(t, new(big.Int).Mul(big.NewInt(80000000), big.NewInt(params.KLAY/params.Ston)), stakingInfo.StakingAmountSton(3))
+	assert.Equal(t, new(big.Int).Mul(big.NewInt(20000000), big.NewInt(params.KLAY)), stakingInfo.StakingAmountPeb(1))
+	assert.Equal(t, "40000000000000000000000000", stakingInfo.StakingAmountPeb(2).String())
+
+	// maxStakingLimit in peb overflows uint64
+	limited := &StakingInfo{CouncilStakingAmounts: []uint64{maxStakingLimit}}
+	assert.Equal(t, "100000000000000000000000000000", limited.StakingAmountPeb(0).String())
+
+	// out of range
+	assert.Nil(t, stakingInfo.StakingAmountKLAY(-1))
+	assert.Nil(t, stakingInfo.StakingAmountSton(4))
+	assert.Nil(t, stakingInfoTestCases[0].stakingInfo.StakingAmountPeb(0))
+}
+
+// stateTestBlockChain is a blockChain serving the given state for every block.
+type stateTestBlockChain struct {
+	*blockchain.BlockChain
+	statedb *state.StateDB
+}
+
+func (bc *stateTestBlockChain) GetBlockByNumber(number uint64) *types.Block {
+	return types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number)})
+}
+
+func (bc *stateTestBlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
+	return bc.statedb, nil
+}
+
+func (bc *stateTestBlockChain) Config() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+func TestNewStakingInfo_StakingLimit(t *testing.T) {
+	statedb, err := state.New(common.Hash{}, state.NewDatabase(database.NewMemoryDBManager()), nil)
+	require.NoError(t, err)
+
+	var (
+		n1, n2 = common.HexToAddress("0x1"), common.HexToAddress("0x2")
+		s1, s2 = common.HexToAddress("0x11"), common.HexToAddress("0x12")
+		r1, r2 = common.HexToAddress("0x21"), common.HexToAddress("0x22")
+	)
+	klay := new(big.Int).SetUint64(params.KLAY)
+	statedb.AddBalance(s1, new(big.Int).Mul(new(big.Int).SetUint64(3*maxStakingLimit), klay)) // above the default limit
+	statedb.AddBalance(s2, new(big.Int).Mul(big.NewInt(10000000), klay))
+
+	bc := &stateTestBlockChain{newTestBlockChain(), statedb}
+	testCases := []struct {
+		stakingLimit uint64
+		expected     []uint64
+	}{
+		{maxStakingLimit, []uint64{maxStakingLimit, 10000000}},
+		{2 * maxStakingLimit, []uint64{2 * maxStakingLimit, 10000000}},
+		{4 * maxStakingLimit, []uint64{3 * maxStakingLimit, 10000000}},
+		{0, []uint64{3 * maxStakingLimit, 10000000}}, // no cap
+		{5000000, []uint64{5000000, 5000000}},
+	}
+
+	for _, tc := range testCases {
+		stakingInfo, err := newStakingInfo(bc, newDefaultTestGovernance(), 86400,
+			[]common.Address{n1, n2}, []common.Address{s1, s2}, []common.Address{r1, r2}, common.Address{}, common.Address{}, tc.stakingLimit)
+		require.NoError(t, err)
+		assert.Equal(t, tc.expected, stakingInfo.CouncilStakingAmounts, "stakingLimit: %d", tc.stakingLimit)
+	}
+
+	// the limit of a StakingManager defaults to maxStakingLimit
+	sm := &StakingManager{addressBookConnector: newAddressBookConnector(bc, newDefaultTestGovernance())}
+	assert.Equal(t, maxStakingLimit, sm.addressBookConnector.stakingLimit)
+	sm.SetMaxStakingLimit(0)
+	assert.Equal(t, uint64(0), sm.addressBookConnector.stakingLimit)
+}
+
+func TestStakingInfo_CloneForBlock(t *testing.T) {
+	src := stakingInfoTestCases[2].stakingInfo.Clone()
+	orig := src.Clone()
+
+	clone := src.CloneForBlock(src.BlockNum + 86400)
+	assert.Equal(t, src.BlockNum+86400, clone.BlockNum)
+	assert.Equal(t, DefaultGiniCoefficient, clone.Gini)
+	assert.Equal(t, src.CouncilNodeAddrs, clone.CouncilNodeAddrs)
+	assert.Equal(t, src.CouncilStakingAddrs, clone.CouncilStakingAddrs)
+	assert.Equal(t, src.CouncilRewardAddrs, clone.CouncilRewardAddrs)
+	assert.Equal(t, src.CouncilStakingAmounts, clone.CouncilStakingAmounts)
+	assert.Equal(t, src.KIRAddr, clone.KIRAddr)
+	assert.Equal(t, src.PoCAddr, clone.PoCAddr)
+
+	// modifying the clone does not affect the original
+	clone.CouncilNodeAddrs[0] = common.Address{0xff}
+	clone.CouncilStakingAddrs[0] = common.Address{0xff}
+	clone.CouncilRewardAddrs[0] = common.Address{0xff}
+	clone.CouncilStakingAmounts[0] = 1
+	assert.Equal(t, orig, src)
+}
+
+func TestConsolidatedStakingInfo_SortCanonical(t *testing.T) {
+	src := stakingInfoTestCases[3].stakingInfo // n1 & n3 share r1, n2 & n4 share r2
+	n, s, r, a := src.CouncilNodeAddrs, src.CouncilStakingAddrs, src.CouncilRewardAddrs, src.CouncilStakingAmounts
+
+	// the same council in a different order
+	reordered := &StakingInfo{
+		CouncilNodeAddrs:      []common.Address{n[3], n[2], n[1], n[0]},
+		CouncilStakingAddrs:   []common.Address{s[3], s[2], s[1], s[0]},
+		CouncilRewardAddrs:    []common.Address{r[3], r[2], r[1], r[0]},
+		CouncilStakingAmounts: []uint64{a[3], a[2], a[1], a[0]},
+	}
+
+	c1 := src.GetConsolidatedStakingInfo()
+	c2 := reordered.GetConsolidatedStakingInfo()
+	assert.NotEqual(t, c1.GetAllNodes(), c2.GetAllNodes())
+
+	c1.SortCanonical()
+	c2.SortCanonical()
+	assert.Equal(t, c1.GetAllNodes(), c2.GetAllNodes())
+	assert.Equal(t, c1.nodeIndex, c2.nodeIndex)
+
+	// nodeIndex and rewardIndex are consistent with the sorted nodes
+	for i, nodeAddr := range n {
+		node := c1.GetConsolidatedNode(nodeAddr)
+		require.NotNil(t, node)
+		assert.Contains(t, node.NodeAddrs, nodeAddr)
+		assert.Equal(t, node, c1.GetConsolidatedNodeByRewardAddr(r[i]))
+	}
+}
+
+func TestConsolidatedStakingInfo_GetConsolidatedNodeByRewardAddr(t *testing.T) {
+	src := stakingInfoTestCases[3].stakingInfo // n1 & n3 share r1, n2 & n4 share r2
+	r, a := src.CouncilRewardAddrs, src.CouncilStakingAmounts
+	c := src.GetConsolidatedStakingInfo()
+
+	node := c.GetConsolidatedNodeByRewardAddr(r[0])
+	require.NotNil(t, node)
+	assert.Equal(t, a[0]+a[2], node.StakingAmount)
+	assert.Equal(t, []common.Address{src.CouncilNodeAddrs[0], src.CouncilNodeAddrs[2]}, node.NodeAddrs)
+
+	node = c.GetConsolidatedNodeByRewardAddr(r[1])
+	require.NotNil(t, node)
+	assert.Equal(t, a[1]+a[3], node.StakingAmount)
+
+	// not a reward address
+	assert.Nil(t, c.GetConsolidatedNodeByRewardAddr(src.CouncilNodeAddrs[0]))
+}
+
+func TestStakingInfo_ConsolidationRatio(t *testing.T) {
+	assert.Equal(t, 1.0, stakingInfoTestCases[0].stakingInfo.ConsolidationRatio()) // empty
+	assert.Equal(t, 1.0, stakingInfoTestCases[1].stakingInfo.ConsolidationRatio())
+	assert.Equal(t, 1.0, stakingInfoTestCases[2].stakingInfo.ConsolidationRatio())
+	assert.Equal(t, 0.5, stakingInfoTestCases[3].stakingInfo.ConsolidationRatio()) // 4 nodes share 2 reward addresses
+}
+
+func TestConsolidatedStakingInfo_SaturatedAmount(t *testing.T) {
+	assert.Equal(t, uint64(3), addStakingAmounts(1, 2))
+	assert.Equal(t, uint64(math.MaxUint64), addStakingAmounts(math.MaxUint64-1, 1))
+	assert.Equal(t, uint64(math.MaxUint64), addStakingAmounts(math.MaxUint64, math.MaxUint64))
+
+	// three nodes share a reward address; a naive sum of their amounts wraps around
+	n := []common.Address{{0x11}, {0x12}, {0x13}}
+	s := []common.Address{{0x21}, {0x22}, {0x23}}
+	r := []common.Address{{0x31}, {0x31}, {0x31}}
+	amounts := []uint64{math.MaxUint64 / 2, math.MaxUint64 / 2, 10}
+	stakingInfo := &StakingInfo{CouncilNodeAddrs: n, CouncilStakingAddrs: s, CouncilRewardAddrs: r, CouncilStakingAmounts: amounts}
+
+	c := stakingInfo.GetConsolidatedStakingInfo()
+	require.Equal(t, 1, len(c.GetAllNodes()))
+	assert.Equal(t, uint64(math.MaxUint64), c.GetAllNodes()[0].StakingAmount)
+}
+
+func TestStakingInfo_TotalStaking(t *testing.T) {
+	// empty council
+	empty := stakingInfoTestCases[0].stakingInfo
+	assert.Equal(t, big.NewInt(0), empty.TotalStaking())
+	assert.Equal(t, uint64(0), empty.GetConsolidatedStakingInfo().TotalStaking())
+	assert.Equal(t, uint64(0), empty.GetConsolidatedStakingInfo().MedianStaking())
+
+	// 10M, 20M, 40M and 80M
+	stakingInfo := stakingInfoTestCases[2].stakingInfo
+	assert.Equal(t, big.NewInt(150000000), stakingInfo.TotalStaking())
+	assert.Equal(t, uint64(150000000), stakingInfo.GetConsolidatedStakingInfo().TotalStaking())
+	assert.Equal(t, uint64(30000000), stakingInfo.GetConsolidatedStakingInfo().MedianStaking())
+
+	// consolidated per reward address: 50M and 100M
+	shared := stakingInfoTestCases[3].stakingInfo
+	assert.Equal(t, uint64(75000000), shared.GetConsolidatedStakingInfo().MedianStaking())
+
+	// odd number of consolidated nodes
+	odd := stakingInfo.Filter(stakingInfo.CouncilNodeAddrs[:3])
+	assert.Equal(t, uint64(20000000), odd.GetConsolidatedStakingInfo().MedianStaking())
+
+	// the sum exceeds the range of uint64
+	n := []common.Address{{0x11}, {0x12}, {0x13}}
+	s := []common.Address{{0x21}, {0x22}, {0x23}}
+	r := []common.Address{{0x31}, {0x32}, {0x33}}
+	amounts := []uint64{math.MaxUint64, math.MaxUint64, 1}
+	large := &StakingInfo{CouncilNodeAddrs: n, CouncilStakingAddrs: s, CouncilRewardAddrs: r, CouncilStakingAmounts: amounts}
+
+	expected := new(big.Int).SetUint64(math.MaxUint64)
+	expected.Mul(expected, big.NewInt(2)).Add(expected, big.NewInt(1))
+	assert.Equal(t, expected, large.TotalStaking())
+	assert.Equal(t, uint64(math.MaxUint64), large.GetConsolidatedStakingInfo().TotalStaking())
+	assert.Equal(t, uint64(math.MaxUint64), large.GetConsolidatedStakingInfo().MedianStaking())
+
+	// the median of large amounts does not overflow
+	large.CouncilStakingAmounts = []uint64{math.MaxUint64, math.MaxUint64}
+	large.CouncilNodeAddrs, large.CouncilStakingAddrs, large.CouncilRewardAddrs = n[:2], s[:2], r[:2]
+	assert.Equal(t, uint64(math.MaxUint64), large.GetConsolidatedStakingInfo().MedianStaking())
+}
+
+func TestStakingInfo_Equal(t *testing.T) {
+	src := stakingInfoTestCases[2].stakingInfo
+	assert.True(t, src.Equal(src.Clone()))
+	assert.False(t, src.Equal(nil))
+
+	// a difference of Gini within the epsilon
+	other := src.Clone()
+	other.Gini += giniEqualityEpsilon / 2
+	assert.True(t, src.Equal(other))
+
+	// real differences
+	modifiers := []func(s *StakingInfo){
+		func(s *StakingInfo) { s.BlockNum++ },
+		func(s *StakingInfo) { s.Gini += 0.01 },
+		func(s *StakingInfo) { s.UseGini = !s.UseGini },
+		func(s *StakingInfo) { s.KIRAddr = common.Address{} },
+		func(s *StakingInfo) { s.CouncilStakingAmounts[0]++ },
+		func(s *StakingInfo) { s.CouncilRewardAddrs[0] = s.CouncilRewardAddrs[1] },
+		func(s *StakingInfo) { councilSorter{s}.Swap(0, 1) },
+		func(s *StakingInfo) { s.CouncilNodeAddrs = s.CouncilNodeAddrs[:3] },
+	}
+	for i, modify := range modifiers {
+		other := src.Clone()
+		modify(other)
+		assert.False(t, src.Equal(other), "modifier %d", i)
+	}
+}
+
+func TestStakingInfo_Validate(t *testing.T) {
+	for _, testcase := range stakingInfoTestCases {
+		assert.Nil(t, testcase.stakingInfo.Validate())
+	}
+
+	src := stakingInfoTestCases[2].stakingInfo
+	n, s, r := src.CouncilNodeAddrs, src.CouncilStakingAddrs, src.CouncilRewardAddrs
+	amounts := src.CouncilStakingAmounts
+
+	testcases := []struct {
+		stakingInfo *StakingInfo
+		expected    error
+	}{
+		{&StakingInfo{CouncilNodeAddrs: n, CouncilStakingAddrs: s[:3], CouncilRewardAddrs: r, CouncilStakingAmounts: amounts}, ErrCouncilLengthMismatch},
+		{&StakingInfo{CouncilNodeAddrs: []common.Address{n[0], n[1], n[2], n[0]}, CouncilStakingAddrs: s, CouncilRewardAddrs: r, CouncilStakingAmounts: amounts}, ErrDuplicateNodeAddr},
+		{&StakingInfo{CouncilNodeAddrs: n, CouncilStakingAddrs: []common.Address{s[0], s[1], s[1], s[3]}, CouncilRewardAddrs: r, CouncilStakingAmounts: amounts}, ErrDuplicateStakingAddr},
+	}
+	for _, tc := range testcases {
+		err := tc.stakingInfo.Validate()
+		assert.True(t, errors.Is(err, tc.expected), "err: %v", err)
+	}
+}
+
+func TestStakingInfo_DecodeRLPLengthMismatch(t *testing.T) {
+	src := stakingInfoTestCases[2].stakingInfo
+
+	// a valid encoding is decoded
+	b, err := rlp.EncodeToBytes(src)
+	require.Nil(t, err)
+	require.Nil(t, rlp.DecodeBytes(b, new(StakingInfo)))
+
+	// an encoding having less staking amounts than nodes is rejected
+	b, err = rlp.EncodeToBytes(&stakingInfoRLP{
+		src.BlockNum, src.CouncilNodeAddrs, src.CouncilStakingAddrs, src.CouncilRewardAddrs, src.KIRAddr, src.PoCAddr,
+		src.UseGini, math.Float64bits(src.Gini), src.CouncilStakingAmounts[:2], src.SchemaVersion, nil,
+	})
+	require.Nil(t, err)
+
+	err = rlp.DecodeBytes(b, new(StakingInfo))
+	assert.True(t, errors.Is(err, ErrCouncilLengthMismatch), "err: %v", err)
+}
+
+func TestStakingInfo_RLPSchemaCompatibility(t *testing.T) {
+	src := stakingInfoTestCases[2].stakingInfo.Clone()
+	src.Gini = 0.25
+
+	// a blob of the legacy schema (version 0) is decoded with the missing fields defaulted
+	type legacyStakingInfoRLP struct {
+		BlockNum              uint64
+		CouncilNodeAddrs      []common.Address
+		CouncilStakingAddrs   []common.Address
+		CouncilRewardAddrs    []common.Address
+		KIRAddr               common.Address
+		PoCAddr               common.Address
+		UseGini               bool
+		Gini                  uint64
+		CouncilStakingAmounts []uint64
+	}
+	b, err := rlp.EncodeToBytes(&legacyStakingInfoRLP{
+		src.BlockNum, src.CouncilNodeAddrs, src.CouncilStakingAddrs, src.CouncilRewardAddrs, src.KIRAddr, src.PoCAddr,
+		src.UseGini, math.Float64bits(src.Gini), src.CouncilStakingAmounts,
+	})
+	require.Nil(t, err)
+
+	legacy := new(StakingInfo)
+	require.Nil(t, rlp.DecodeBytes(b, legacy))
+	assert.Equal(t, uint64(0), legacy.SchemaVersion)
+	expected := src.Clone()
+	expected.SchemaVersion = 0
+	assert.True(t, expected.Equal(legacy))
+	assert.Equal(t, src.Gini, legacy.Gini)
+
+	// a blob of the current schema is round-tripped
+	b, err = rlp.EncodeToBytes(src)
+	require.Nil(t, err)
+
+	decoded := new(StakingInfo)
+	require.Nil(t, rlp.DecodeBytes(b, decoded))
+	assert.Equal(t, src, decoded)
+
+	reencoded, err := rlp.EncodeToBytes(decoded)
+	require.Nil(t, err)
+	assert.Equal(t, b, reencoded)
+
+	// a blob of a newer schema, having fields appended, is decoded with the known fields
+	type futureStakingInfoRLP struct {
+		BlockNum              uint64
+		CouncilNodeAddrs      []common.Address
+		CouncilStakingAddrs   []common.Address
+		CouncilRewardAddrs    []common.Address
+		KIRAddr               common.Address
+		PoCAddr               common.Address
+		UseGini               bool
+		Gini                  uint64
+		CouncilStakingAmounts []uint64
+		SchemaVersion         uint64         `rlp:"optional"`
+		NewAddr               common.Address `rlp:"optional"`
+		NewAmount             uint64         `rlp:"optional"`
+	}
+	b, err = rlp.EncodeToBytes(&futureStakingInfoRLP{
+		src.BlockNum, src.CouncilNodeAddrs, src.CouncilStakingAddrs, src.CouncilRewardAddrs, src.KIRAddr, src.PoCAddr,
+		src.UseGini, math.Float64bits(src.Gini), src.CouncilStakingAmounts, StakingInfoSchemaVersion + 1,
+		common.HexToAddress("0x1"), 100,
+	})
+	require.Nil(t, err)
+
+	future := new(StakingInfo)
+	require.Nil(t, rlp.DecodeBytes(b, future))
+	assert.Equal(t, StakingInfoSchemaVersion+1, future.SchemaVersion)
+	expected = src.Clone()
+	expected.SchemaVersion = StakingInfoSchemaVersion + 1
+	assert.True(t, expected.Equal(future))
+}
+
+func TestConsolidatedStakingInfo_NodeAddrsByRewardAddr(t *testing.T) {
+	stakingInfo := stakingInfoTestCases[3].stakingInfo // n1 & n3 share r1, n2 & n4 share r2
+	n, r := stakingInfo.CouncilNodeAddrs, stakingInfo.CouncilRewardAddrs
+
+	m := stakingInfo.GetConsolidatedStakingInfo().NodeAddrsByRewardAddr()
+	assert.Equal(t, map[common.Address][]common.Address{
+		r[0]: {n[0], n[2]},
+		r[1]: {n[1], n[3]},
+	}, m)
+
+	// one node per reward address
+	stakingInfo = stakingInfoTestCases[2].stakingInfo
+	m = stakingInfo.GetConsolidatedStakingInfo().NodeAddrsByRewardAddr()
+	assert.Equal(t, 4, len(m))
+	for i, rewardAddr := range stakingInfo.CouncilRewardAddrs {
+		assert.Equal(t, []common.Address{stakingInfo.CouncilNodeAddrs[i]}, m[rewardAddr])
+	}
+}
+
+func TestConsolidatedStakingInfo_DecentralizationReport(t *testing.T) {
+	equal := stakingInfoTestCases[2].stakingInfo.Clone()
+	equal.CouncilStakingAmounts = []uint64{10000000, 10000000, 10000000, 10000000}
+
+	testcases := []struct {
+		stakingInfo *StakingInfo
+		minStake    uint64
+		expected    DecentralizationReport
+	}{
+		// Gini(10, 20, 40, 80)
+		{stakingInfoTestCases[2].stakingInfo, 2000000, DecentralizationReport{0.38, 1, 22500.0 / 8500.0, 4, 4}},
+		// Gini(50, 100)
+		{stakingInfoTestCases[3].stakingInfo, 2000000, DecentralizationReport{0.17, 1, 22500.0 / 12500.0, 2, 2}},
+		// Gini(20, 2); two nodes below the minimum are excluded
+		{stakingInfoTestCases[4].stakingInfo, 2000000, DecentralizationReport{0.41, 1, 484.0 / 404.0, 4, 2}},
+		// equal stakes need two nodes to hold more than 1/3
+		{equal, 2000000, DecentralizationReport{0, 2, 4, 4, 4}},
+		// no eligible node
+		{stakingInfoTestCases[2].stakingInfo, 100000000, DecentralizationReport{DefaultGiniCoefficient, 0, 0, 4, 0}},
+		// empty
+		{stakingInfoTestCases[0].stakingInfo, 2000000, DecentralizationReport{DefaultGiniCoefficient, 0, 0, 0, 0}},
+	}
+	for i, tc := range testcases {
+		report := tc.stakingInfo.GetConsolidatedStakingInfo().DecentralizationReport(tc.minStake)
+		assert.Equal(t, tc.expected.Gini, report.Gini, "testcase %d", i)
+		assert.Equal(t, tc.expected.NakamotoCoefficient, report.NakamotoCoefficient, "testcase %d", i)
+		assert.InDelta(t, tc.expected.EffectiveStakers, report.EffectiveStakers, 1e-9, "testcase %d", i)
+		assert.Equal(t, tc.expected.NodeCount, report.NodeCount, "testcase %d", i)
+		assert.Equal(t, tc.expected.EligibleNodeCount, report.EligibleNodeCount, "testcase %d", i)
+
+		// consistent with the individual metric
+		assert.Equal(t, tc.stakingInfo.GetConsolidatedStakingInfo().CalcGiniCoefficientMinStake(tc.minStake), report.Gini, "testcase %d", i)
+	}
+
+	b, err := json.Marshal(DecentralizationReport{0.38, 1, 2.5, 4, 3})
+	require.Nil(t, err)
+	assert.Equal(t, `{"gini":0.38,"nakamotoCoefficient":1,"effectiveStakers":2.5,"nodeCount":4,"eligibleNodeCount":3}`, string(b))
+}
+
+func TestConsolidatedStakingInfo_LorenzPoints(t *testing.T) {
+	var (
+		n1 = common.HexToAddress("0x8aD8F547fa00f58A8c4fb3B671Ee5f1A75bA028a")
+		n2 = common.HexToAddress("0xB2AAda7943919e82143324296987f6091F3FDC9e")
+		r1 = common.HexToAddress("0x241c793A9AD555f52f6C3a83afe6178408796ab2")
+		r2 = common.HexToAddress("0x79b427Fb77077A9716E08D049B0e8f36Abfc8E2E")
+	)
+	stakingInfo := newEmptyStakingInfo(0)
+	stakingInfo.CouncilNodeAddrs = []common.Address{n1, n2}
+	stakingInfo.CouncilStakingAddrs = []common.Address{n1, n2}
+	stakingInfo.CouncilRewardAddrs = []common.Address{r1, r2}
+	stakingInfo.CouncilStakingAmounts = []uint64{30000000, 10000000}
+
+	c := stakingInfo.GetConsolidatedStakingInfo()
+	assert.Equal(t, [][2]float64{{0, 0}, {0.5, 0.25}, {1, 1}}, c.LorenzPoints(0))
+
+	// only one eligible node is a perfectly equal distribution
+	assert.Equal(t, [][2]float64{{0, 0}, {1, 1}}, c.LorenzPoints(20000000))
+
+	// no eligible node
+	assert.Nil(t, c.LorenzPoints(40000000))
+}
+
+func TestConsolidatedStakingInfo_LorenzCurve(t *testing.T) {
+	// a perfectly equal distribution is the 45-degree line
+	c := stakingInfoTestCases[2].stakingInfo.GetConsolidatedStakingInfo()
+	for i := range c.nodes {
+		c.nodes[i].StakingAmount = 5000000
+	}
+	population, stake := c.LorenzCurve(0)
+	assert.Equal(t, []float64{0, 0.25, 0.5, 0.75, 1}, population)
+	assert.Equal(t, population, stake)
+
+	// the area corresponds to the Gini coefficient: 10M, 20M, 40M and 80M
+	c = stakingInfoTestCases[2].stakingInfo.GetConsolidatedStakingInfo()
+	population, stake = c.LorenzCurve(0)
+	require.Equal(t, len(population), len(stake))
+	area := 0.0
+	for i := 1; i < len(population); i++ {
+		area += (population[i] - population[i-1]) * (stake[i] + stake[i-1]) / 2
+	}
+	assert.Equal(t, c.CalcGiniCoefficientMinStake(0), math.Round((1-2*area)*100)/100)
+
+	// no eligible node
+	population, stake = c.LorenzCurve(100000000)
+	assert.Nil(t, population)
+	assert.Nil(t, stake)
+}
+
+func TestConsolidatedStakingInfo_StakeToReachRank(t *testing.T) {
+	src := stakingInfoTestCases[2].stakingInfo
+	stakingInfo := &StakingInfo{
+		BlockNum:              src.BlockNum,
+		CouncilNodeAddrs:      src.CouncilNodeAddrs,
+		CouncilStakingAddrs:   src.CouncilStakingAddrs,
+		CouncilRewardAddrs:    src.CouncilRewardAddrs,
+		CouncilStakingAmounts: []uint64{40000000, 20000000, 20000000, 10000000}, // r2 and r3 are tied
+	}
+	r := stakingInfo.CouncilRewardAddrs
+	c := stakingInfo.GetConsolidatedStakingInfo()
+
+	// ties keep the original order
+	var sorted []common.Address
+	for _, node := range c.SortedByStake() {
+		sorted = append(sorted, node.RewardAddr)
+	}
+	assert.Equal(t, []common.Address{r[0], r[1], r[2], r[3]}, sorted)
+
+	testcases := []struct {
+		rewardAddr common.Address
+		targetRank int
+		expected   int64
+	}{
+		{r[0], 1, -19999999}, // already above r2 and r3
+		{r[1], 1, 20000001},
+		{r[1], 2, 1}, // a tie is not enough
+		{r[2], 2, 1},
+		{r[1], 3, -9999999},
+		{r[3], 3, 10000001},
+		{r[3], 4, -10000000}, // the lowest rank needs no stake
+	}
+	for _, tc := range testcases {
+		amount, err := c.StakeToReachRank(tc.rewardAddr, tc.targetRank)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, amount)
+	}
+
+	_, err := c.StakeToReachRank(common.HexToAddress("0x1"), 1)
+	assert.Equal(t, ErrAddrNotInStakingInfo, err)
+
+	_, err = c.StakeToReachRank(r[0], 0)
+	assert.Equal(t, ErrRankOutOfRange, err)
+
+	_, err = c.StakeToReachRank(r[0], 5)
+	assert.Equal(t, ErrRankOutOfRange, err)
+}
+
+func TestConsolidatedStakingInfo_EligibleNodes(t *testing.T) {
+	for _, testcase := range stakingInfoTestCases {
+		c := testcase.stakingInfo.GetConsolidatedStakingInfo()
+		for _, minStake := range []uint64{0, 5000000, 10000000, math.MaxUint64} {
+			expected := 0
+			for _, node := range c.GetAllNodes() {
+				if node.StakingAmount >= minStake {
+					expected++
+				}
+			}
+			assert.Equal(t, expected, c.CountAboveMinStake(minStake))
+
+			eligible := c.EligibleNodes(minStake)
+			assert.Len(t, eligible, expected)
+			for _, node := range eligible {
+				assert.GreaterOrEqual(t, node.StakingAmount, minStake)
+			}
+		}
+	}
+
+	// 0x21 is eligible by the sum of its nodes, although none of its nodes is
+	stakingInfo := newEmptyStakingInfo(0)
+	stakingInfo.CouncilNodeAddrs = []common.Address{{0x1}, {0x2}, {0x3}}
+	stakingInfo.CouncilStakingAddrs = []common.Address{{0x11}, {0x12}, {0x13}}
+	stakingInfo.CouncilRewardAddrs = []common.Address{{0x21}, {0x21}, {0x23}}
+	stakingInfo.CouncilStakingAmounts = []uint64{3000000, 3000000, 4000000}
+	c := stakingInfo.GetConsolidatedStakingInfo()
+	assert.Equal(t, 1, c.CountAboveMinStake(5000000))
+	assert.Equal(t, common.Address{0x21}, c.EligibleNodes(5000000)[0].RewardAddr)
+}
+
+func TestConsolidatedStakingInfo_TopN(t *testing.T) {
+	stakingInfo := newEmptyStakingInfo(0)
+	stakingInfo.CouncilNodeAddrs = []common.Address{{0x1}, {0x2}, {0x3}, {0x4}, {0x5}}
+	stakingInfo.CouncilStakingAddrs = []common.Address{{0x11}, {0x12}, {0x13}, {0x14}, {0x15}}
+	stakingInfo.CouncilRewardAddrs = []common.Address{{0x24}, {0x22}, {0x23}, {0x24}, {0x21}}
+	stakingInfo.CouncilStakingAmounts = []uint64{100, 500, 300, 200, 300} // 0x24 has 300 in total
+	c := stakingInfo.GetConsolidatedStakingInfo()
+
+	rewardAddrs := func(nodes []ConsolidatedNode) []common.Address {
+		addrs := make([]common.Address, len(nodes))
+		for i, node := range nodes {
+			addrs[i] = node.RewardAddr
+		}
+		return addrs
+	}
+
+	// ties of 300 are ordered by reward address
+	assert.Equal(t, []common.Address{{0x22}, {0x21}, {0x23}, {0x24}}, rewardAddrs(c.TopN(4)))
+	assert.Equal(t, []common.Address{{0x22}, {0x21}}, rewardAddrs(c.TopN(2)))
+	assert.Equal(t, uint64(300), c.TopN(4)[3].StakingAmount)
+
+	// n larger than the number of the nodes
+	assert.Len(t, c.TopN(10), 4)
+	assert.Empty(t, c.TopN(0))
+
+	// the nodes of the staking info are not reordered
+	assert.Equal(t, common.Address{0x24}, c.GetAllNodes()[0].RewardAddr)
+}
+
+func TestStakingInfo_CanonicalContentKey(t *testing.T) {
+	// the ordinary 4-entry info and its copy with another block number and shuffled council
+	src := stakingInfoTestCases[2].stakingInfo
+	dst := &StakingInfo{
+		BlockNum:              5 * 86400,
+		CouncilNodeAddrs:      []common.Address{src.CouncilNodeAddrs[2], src.CouncilNodeAddrs[0], src.CouncilNodeAddrs[3], src.CouncilNodeAddrs[1]},
+		CouncilStakingAddrs:   []common.Address{src.CouncilStakingAddrs[2], src.CouncilStakingAddrs[0], src.CouncilStakingAddrs[3], src.CouncilStakingAddrs[1]},
+		CouncilRewardAddrs:    []common.Address{src.CouncilRewardAddrs[2], src.CouncilRewardAddrs[0], src.CouncilRewardAddrs[3], src.CouncilRewardAddrs[1]},
+		KIRAddr:               src.KIRAddr,
+		PoCAddr:               src.PoCAddr,
+		UseGini:               src.UseGini,
+		Gini:                  DefaultGiniCoefficient,
+		CouncilStakingAmounts: []uint64{src.CouncilStakingAmounts[2], src.CouncilStakingAmounts[0], src.CouncilStakingAmounts[3], src.CouncilStakingAmounts[1]},
+	}
+	srcOrder := append([]common.Address{}, src.CouncilNodeAddrs...)
+
+	key := src.CanonicalContentKey()
+	assert.NotEmpty(t, key)
+	assert.Equal(t, key, dst.CanonicalContentKey())
+
+	// the receiver is not reordered
+	assert.Equal(t, srcOrder, src.CouncilNodeAddrs)
+
+	// a different stake yields a different key
+	dst.CouncilStakingAmounts[0]++
+	assert.NotEqual(t, key, dst.CanonicalContentKey())
+}
@@ -17,9 +17,15 @@
 package reward
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"math/big"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/klaytn/klaytn/blockchain"
 	"github.com/klaytn/klaytn/blockchain/state"
 	"github.com/klaytn/klaytn/blockchain/types"
@@ -30,11 +36,67 @@ import (
 
 const (
 	chainHeadChanSize = 100
+
+	// maximum number of memoized eligible nodes per (staking block number, minimum staking amount)
+	maxEligibleCache = 16
+
+	// DefaultMaxStaleIntervals is the default number of staking intervals a requested
+	// staking info can be ahead of the latest refreshed one before it is reported as stale.
+	DefaultMaxStaleIntervals = 2
+
+	// prefetch of staking info on chain heads
+	defaultPrefetchWorkers   = 2
+	defaultPrefetchIntervals = 1
+	prefetchQueueSize        = 16
+
+	// maximum number of staking intervals served by a GetStakingInfoInRange call
+	maxStakingInfoRangeIntervals = 1024
+
+	// retry backoff of the minimum staking lookup filling a missing Gini coefficient
+	giniRetryBaseDelay = time.Second
+	giniRetryMaxDelay  = 5 * time.Minute
+)
+
+// Sources of staking info reported by CheckStakingInfoStoredDetailed
+const (
+	StakingInfoSourceDB        = "db"        // already stored in DB
+	StakingInfoSourceRecompute = "recompute" // recomputed from the AddressBook contract and stored
+	StakingInfoSourceFailed    = "failed"    // neither stored nor recomputed
 )
 
+// StakingUnavailablePolicy decides what is served when staking info cannot be resolved while producing a block.
+// It is consulted only by GetStakingInfoWithTimeout, so that proposer selection, reward distribution on
+// verification and the other lookups never use the staking info served by the policy.
+//
+// The policy is applied on a failure local to a node, e.g. a pruned state, so a block produced with the staking
+// info served by the policy can be rejected by validators resolving the actual one.
+// Policies other than StakingUnavailableFail are meant for private or test chains.
+type StakingUnavailablePolicy string
+
+const (
+	// StakingUnavailableFail serves nothing, so that the caller fails. It is the default.
+	StakingUnavailableFail StakingUnavailablePolicy = "fail"
+	// StakingUnavailableUsePrevious serves a copy of the cached staking info of the most recent previous interval,
+	// whose block number is the requested staking block number.
+	StakingUnavailableUsePrevious StakingUnavailablePolicy = "usePrevious"
+	// StakingUnavailableEmpty serves an empty staking info, i.e. an empty council.
+	StakingUnavailableEmpty StakingUnavailablePolicy = "empty"
+)
+
+// ParseStakingUnavailablePolicy returns the StakingUnavailablePolicy of the given name.
+func ParseStakingUnavailablePolicy(name string) (StakingUnavailablePolicy, error) {
+	switch policy := StakingUnavailablePolicy(name); policy {
+	case StakingUnavailableFail, StakingUnavailableUsePrevious, StakingUnavailableEmpty:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid staking unavailable policy: %q (fail, usePrevious or empty)", name)
+	}
+}
+
 // blockChain is an interface for blockchain.Blockchain used in reward package.
 type blockChain interface {
 	SubscribeChainHeadEvent(ch chan<- blockchain.ChainHeadEvent) event.Subscription
+	SubscribeChainSideEvent(ch chan<- blockchain.ChainSideEvent) event.Subscription
 	GetBlockByNumber(number uint64) *types.Block
 	StateAt(root common.Hash) (*state.StateDB, error)
 	Config() *params.ChainConfig
@@ -43,72 +105,314 @@ type blockChain interface {
 }
 
 type StakingManager struct {
+	// counters of the sources serving staking info lookups, accessed atomically.
+	// They are placed first to be 64-bit aligned.
+	cacheHits  uint64
+	dbHits     uint64
+	recomputes uint64
+
 	addressBookConnector *addressBookConnector
 	stakingInfoCache     *stakingInfoCache
 	stakingInfoDB        stakingInfoDB
+	stakingInfoReadDB    stakingInfoDB // optional read replica of stakingInfoDB
 	governanceHelper     governanceHelper
 	blockchain           blockChain
 	chainHeadChan        chan blockchain.ChainHeadEvent
+	subscribed           int32 // 1 if subscribed to chain head events, accessed atomically
 	chainHeadSub         event.Subscription
+	chainSideSub         event.Subscription // blocks removed from the canonical chain by reorgs
+	cancelChainHead      context.CancelFunc // stops the chain head handler
+	chainHeadDone        chan struct{}      // closed when the chain head handler exits
+
+	// staleness tracking of the staking info refreshed by the chain head handler
+	refreshLock        sync.RWMutex
+	refreshed          bool   // true if the chain head handler has refreshed staking info at least once
+	lastRefreshedBlock uint64 // staking block number most recently refreshed by the chain head handler
+	maxStaleIntervals  uint64 // DefaultMaxStaleIntervals is used if zero
+
+	unavailablePolicy StakingUnavailablePolicy // StakingUnavailableFail is used if empty
+
+	safeCopy bool // if true, copies of the cached staking info are served
+
+	checkConsistency bool // if true, cache hits are compared with the database. See StakingManagerConfig.CheckConsistency.
+
+	giniCalculator GiniCalculator // DefaultGiniCalculator is used if nil
+
+	// staking block numbers whose Gini coefficient cannot be filled, since the minimum staking lookup failed
+	giniUnavailableLock sync.Mutex
+	giniUnavailable     map[uint64]*giniUnavailableMarker
+
+	// recomputation of staking info bounded by recomputeTimeout
+	recomputeTimeout time.Duration // no limit if zero
+	recomputeLock    sync.Mutex
+	recomputing      map[uint64]*stakingInfoRecompute // in-flight recomputations by staking block number
+
+	// OnStakingInfoRefreshed is called with a copy of the staking info of the next update interval,
+	// when it is refreshed by the chain head handler for the first time. It is called on a prefetch
+	// worker, so it should return quickly. It is not called if nil.
+	OnStakingInfoRefreshed func(stakingBlockNumber uint64, info *StakingInfo)
+
+	// prefetch of staking info on chain heads. See StakingManagerConfig.PrefetchWorkers and PrefetchIntervals.
+	prefetchWorkers   int    // staking info is fetched on the chain head handler if zero
+	prefetchIntervals uint64 // defaultPrefetchIntervals is used if zero
+	prefetchCh        chan stakingInfoPrefetch
+	prefetchLock      sync.Mutex
+	prefetching       map[uint64]bool // staking block numbers queued or being fetched
+	prefetchWG        sync.WaitGroup
+
+	// memoized eligible nodes by eligibleCacheKey
+	eligibleCache *lru.Cache
+
+	// hashes of the canonical blocks whose states the staking info is computed from, by staking block number.
+	// They tell a chain side event of a reorg from one of a block imported off the canonical chain.
+	stakingBlockHashes *lru.Cache
+
+	// staking interval change notification. The fields below are accessed only by the chain head handler.
+	stakingIntervalFeed  event.Feed
+	lastStakingBlockNum  uint64 // staking block number of the latest chain head
+	stakingBlockNumKnown bool   // true if lastStakingBlockNum is set
 }
 
 var (
-	// variables for sole StakingManager
-	once           sync.Once
-	stakingManager *StakingManager
+	// default StakingManager served by the package-level functions
+	stakingManagerLock sync.RWMutex
+	stakingManager     *StakingManager
 
 	// errors for staking manager
 	ErrStakingManagerNotSet = errors.New("staking manager is not set")
 	ErrChainHeadChanNotSet  = errors.New("chain head channel is not set")
+	ErrStakingInfoNotFound  = errors.New("staking info is not found")
+	ErrStakingStatePruned   = errors.New("state of the staking block is not available")
+	ErrStakingInfoTimeout   = errors.New("timed out recomputing staking info")
+	ErrNotStakingInterval   = errors.New("not a staking block number")
+
+	// recomputeStakingInfo recomputes staking info from the state. It is replaced in tests.
+	recomputeStakingInfo = (*StakingManager).updateStakingInfo
+
+	// giniRetryNow returns the current time deciding the retry of the minimum staking lookup. It is replaced in tests.
+	giniRetryNow = time.Now
 )
 
-// NewStakingManager creates and returns StakingManager.
-//
-// On the first call, a StakingManager is created with given parameters.
-// From next calls, the existing StakingManager is returned. (Parameters
-// from the next calls will not affect.)
+// giniUnavailableMarker is the retry state of the minimum staking lookup of a staking block.
+type giniUnavailableMarker struct {
+	failures int       // number of consecutive failures
+	retryAt  time.Time // the lookup is skipped until then
+}
+
+// StakingManagerConfig is the configuration of a StakingManager.
+type StakingManagerConfig struct {
+	// StakingCacheSize is the maximum number of staking info in the cache. The default is used if zero.
+	// A larger cache avoids recomputing staking info on nodes querying staking info of many intervals.
+	StakingCacheSize int
+
+	// CheckConsistency enables comparing the staking info served from the cache with the one stored in the database
+	// on every cache hit, to debug divergence between them. It costs a database read per cache hit.
+	CheckConsistency bool
+
+	// PrefetchWorkers is the number of goroutines fetching staking info on chain heads, so that a slow state read
+	// does not stall the chain head handler. The default is used if zero.
+	PrefetchWorkers int
+
+	// PrefetchIntervals is the number of staking intervals warmed up on a chain head, from the one used by
+	// the next update interval going back. For example, 2 also warms up the one used by the current interval,
+	// which is missing in the cache after a restart. Staking info beyond the chain head cannot be computed,
+	// so the next update interval is the furthest one warmed up. The default is used if zero.
+	PrefetchIntervals int
+}
+
+// DefaultStakingManagerConfig is the default configuration of a StakingManager.
+var DefaultStakingManagerConfig = StakingManagerConfig{
+	StakingCacheSize:  maxStakingCache,
+	PrefetchWorkers:   defaultPrefetchWorkers,
+	PrefetchIntervals: defaultPrefetchIntervals,
+}
+
+// Validate fills the default values of unset fields, and returns an error if the configuration is invalid.
+func (c *StakingManagerConfig) Validate() error {
+	if c.StakingCacheSize == 0 {
+		c.StakingCacheSize = DefaultStakingManagerConfig.StakingCacheSize
+	}
+	if c.StakingCacheSize < 1 {
+		return fmt.Errorf("invalid staking cache size: %d (should be at least 1)", c.StakingCacheSize)
+	}
+	if c.PrefetchWorkers == 0 {
+		c.PrefetchWorkers = DefaultStakingManagerConfig.PrefetchWorkers
+	}
+	if c.PrefetchWorkers < 1 {
+		return fmt.Errorf("invalid staking prefetch workers: %d (should be at least 1)", c.PrefetchWorkers)
+	}
+	if c.PrefetchIntervals == 0 {
+		c.PrefetchIntervals = DefaultStakingManagerConfig.PrefetchIntervals
+	}
+	if c.PrefetchIntervals < 1 {
+		return fmt.Errorf("invalid staking prefetch intervals: %d (should be at least 1)", c.PrefetchIntervals)
+	}
+	return nil
+}
+
+// NewStakingManager creates and returns a new StakingManager with DefaultStakingManagerConfig.
+// See NewStakingManagerWithConfig.
 func NewStakingManager(bc blockChain, gh governanceHelper, db stakingInfoDB) *StakingManager {
-	if bc != nil && gh != nil {
-		// this is only called once
-		once.Do(func() {
-			stakingManager = &StakingManager{
-				addressBookConnector: newAddressBookConnector(bc, gh),
-				stakingInfoCache:     newStakingInfoCache(),
-				stakingInfoDB:        db,
-				governanceHelper:     gh,
-				blockchain:           bc,
-				chainHeadChan:        make(chan blockchain.ChainHeadEvent, chainHeadChanSize),
-			}
+	sm, err := NewStakingManagerWithConfig(bc, gh, db, DefaultStakingManagerConfig)
+	if err != nil {
+		logger.Error("unable to set StakingManager", "err", err)
+		return nil
+	}
+	return sm
+}
 
-			// Before migration, staking information of current and before should be stored in DB.
-			//
-			// Staking information from block of StakingUpdateInterval ahead is needed to create a block.
-			// If there is no staking info in either cache, db or state trie, the node cannot make a block.
-			// The information in state trie is deleted after state trie migration.
-			blockchain.RegisterMigrationPrerequisites(func(blockNum uint64) error {
-				if err := CheckStakingInfoStored(blockNum); err != nil {
+// NewStakingManagerWithConfig creates and returns a new StakingManager with its own cache, for the given blockchain
+// and database. Every call creates an independent StakingManager, so that managers of different chains can coexist
+// in a process.
+//
+// The first StakingManager created becomes the default one, which is served by the package-level functions
+// such as GetStakingInfo and GetStakingManager.
+func NewStakingManagerWithConfig(bc blockChain, gh governanceHelper, db stakingInfoDB, config StakingManagerConfig) (*StakingManager, error) {
+	if bc == nil || gh == nil {
+		return nil, errors.New("blockchain and governance helper are required")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	sm := &StakingManager{
+		addressBookConnector: newAddressBookConnector(bc, gh),
+		stakingInfoCache:     newStakingInfoCacheWithSize(config.StakingCacheSize),
+		stakingInfoDB:        db,
+		governanceHelper:     gh,
+		blockchain:           bc,
+		chainHeadChan:        make(chan blockchain.ChainHeadEvent, chainHeadChanSize),
+		checkConsistency:     config.CheckConsistency,
+		prefetchWorkers:      config.PrefetchWorkers,
+		prefetchIntervals:    uint64(config.PrefetchIntervals),
+	}
+	sm.eligibleCache, _ = lru.New(maxEligibleCache)
+	sm.stakingBlockHashes, _ = lru.New(config.StakingCacheSize)
+
+	stakingManagerLock.Lock()
+	defer stakingManagerLock.Unlock()
+	if stakingManager == nil {
+		stakingManager = sm
+
+		// Before migration, staking information of current and before should be stored in DB.
+		//
+		// Staking information from block of StakingUpdateInterval ahead is needed to create a block.
+		// If there is no staking info in either cache, db or state trie, the node cannot make a block.
+		// The information in state trie is deleted after state trie migration.
+		// Since the prerequisites are not bound to a blockchain, only those of the default manager are registered.
+		blockchain.RegisterMigrationPrerequisites(func(blockNum uint64) error {
+			for _, num := range []uint64{blockNum, blockNum + params.StakingUpdateInterval()} {
+				source, err := sm.CheckStakingInfoStoredDetailed(num)
+				if err != nil {
+					logger.Error("Staking info is not stored for migration", "blockNum", num,
+						"staking block number", params.CalcStakingBlockNumber(num), "source", source, "err", err)
 					return err
 				}
-				return CheckStakingInfoStored(blockNum + params.StakingUpdateInterval())
-			})
+				logger.Info("Staking info is stored for migration", "blockNum", num,
+					"staking block number", params.CalcStakingBlockNumber(num), "source", source)
+			}
+			return nil
 		})
-	} else {
-		logger.Error("unable to set StakingManager", "blockchain", bc, "governanceHelper", gh)
 	}
-
-	return stakingManager
+	return sm, nil
 }
 
+// GetStakingManager returns the default StakingManager, i.e. the first one created by NewStakingManager.
 func GetStakingManager() *StakingManager {
+	stakingManagerLock.RLock()
+	defer stakingManagerLock.RUnlock()
 	return stakingManager
 }
 
+// SetStakingInfoReadDB sets a read-only database which serves staking info queries
+// instead of the primary stakingInfoDB. Writes always go to the primary database.
+// Setting nil makes reads fall back to the primary database.
+func (sm *StakingManager) SetStakingInfoReadDB(db stakingInfoDB) {
+	sm.stakingInfoReadDB = db
+}
+
+// GetStakingInfo returns a stakingInfo on the staking block of the given block number from the default StakingManager.
+// See StakingManager.GetStakingInfo.
+func GetStakingInfo(blockNum uint64) *StakingInfo {
+	return GetStakingManager().GetStakingInfo(blockNum)
+}
+
+// GetStakingInfoOnStakingBlock returns a corresponding StakingInfo for a staking block number from the default
+// StakingManager. See StakingManager.GetStakingInfoOnStakingBlock.
+func GetStakingInfoOnStakingBlock(stakingBlockNumber uint64) *StakingInfo {
+	return GetStakingManager().GetStakingInfoOnStakingBlock(stakingBlockNumber)
+}
+
+// GetStakingInfoErr returns a stakingInfo on the staking block of the given block number from the default
+// StakingManager, or the reason why it is not available. See StakingManager.GetStakingInfoErr.
+func GetStakingInfoErr(blockNum uint64) (*StakingInfo, error) {
+	return GetStakingManager().GetStakingInfoErr(blockNum)
+}
+
+// GetStakingInfoOnStakingBlockErr returns a corresponding StakingInfo for a staking block number from the default
+// StakingManager, or the reason why it is not available. See StakingManager.GetStakingInfoOnStakingBlockErr.
+func GetStakingInfoOnStakingBlockErr(stakingBlockNumber uint64) (*StakingInfo, error) {
+	return GetStakingManager().GetStakingInfoOnStakingBlockErr(stakingBlockNumber)
+}
+
+// GetStakingInfoWithTimeout returns a stakingInfo on the staking block of the given block number from the default
+// StakingManager. See StakingManager.GetStakingInfoWithTimeout.
+func GetStakingInfoWithTimeout(blockNum uint64) (*StakingInfo, error) {
+	return GetStakingManager().GetStakingInfoWithTimeout(blockNum)
+}
+
+// CheckStakingInfoStored makes sure the given staking info is stored in cache and DB of the default StakingManager.
+func CheckStakingInfoStored(blockNum uint64) error {
+	return GetStakingManager().CheckStakingInfoStored(blockNum)
+}
+
+// CheckStakingInfoStoredDetailed is like CheckStakingInfoStored, but it also returns where the staking info came from.
+// See StakingManager.CheckStakingInfoStoredDetailed.
+func CheckStakingInfoStoredDetailed(blockNum uint64) (string, error) {
+	return GetStakingManager().CheckStakingInfoStoredDetailed(blockNum)
+}
+
+// SetSafeCopy sets whether GetStakingInfo and the other getters of StakingManager serve copies of the cached
+// staking info. Copies can be modified by callers without corrupting the cache, at the cost of allocations.
+func (sm *StakingManager) SetSafeCopy(enabled bool) {
+	sm.safeCopy = enabled
+}
+
+// serve returns the given staking info, or its copy if safe copy is enabled.
+func (sm *StakingManager) serve(stakingInfo *StakingInfo) *StakingInfo {
+	if stakingInfo == nil || sm == nil || !sm.safeCopy {
+		return stakingInfo
+	}
+	return stakingInfo.Clone()
+}
+
 // GetStakingInfo returns a stakingInfo on the staking block of the given block number.
 // Note that staking block is the block on which the associated staking information is stored and used during an interval.
-func GetStakingInfo(blockNum uint64) *StakingInfo {
+func (sm *StakingManager) GetStakingInfo(blockNum uint64) *StakingInfo {
+	return sm.serve(sm.getStakingInfo(blockNum))
+}
+
+// GetStakingInfoErr returns a stakingInfo on the staking block of the given block number like GetStakingInfo,
+// but it returns the reason why the staking info is not available instead of nil.
+// See GetStakingInfoOnStakingBlockErr for the errors.
+func (sm *StakingManager) GetStakingInfoErr(blockNum uint64) (*StakingInfo, error) {
+	stakingInfo, err := sm.getStakingInfoErr(blockNum)
+	return sm.serve(stakingInfo), err
+}
+
+// getStakingInfo is GetStakingInfo serving the cached staking info itself.
+func (sm *StakingManager) getStakingInfo(blockNum uint64) *StakingInfo {
+	stakingInfo, _ := sm.getStakingInfoErr(blockNum)
+	return stakingInfo
+}
+
+func (sm *StakingManager) getStakingInfoErr(blockNum uint64) (*StakingInfo, error) {
 	stakingBlockNumber := params.CalcStakingBlockNumber(blockNum)
 	logger.Debug("Staking information is requested", "blockNum", blockNum, "staking block number", stakingBlockNumber)
-	return GetStakingInfoOnStakingBlock(stakingBlockNumber)
+	if sm != nil {
+		sm.checkStaleness(stakingBlockNumber)
+	}
+	return sm.getStakingInfoOnStakingBlockErr(stakingBlockNumber)
 }
 
 // GetStakingInfoOnStakingBlock returns a corresponding StakingInfo for a staking block number.
@@ -117,78 +421,666 @@ func GetStakingInfo(blockNum uint64) *StakingInfo {
 // Fixup for Gini coefficients:
 // Klaytn core stores Gini: -1 in its database.
 // We ensure GetStakingInfoOnStakingBlock() to always return meaningful Gini.
-//   If cache hit                               -> fillMissingGini -> modifies cached in-memory object
-//   If db hit                                  -> fillMissingGini -> write to cache
-//   If read contract -> write to db (gini: -1) -> fillMissingGini -> write to cache
-func GetStakingInfoOnStakingBlock(stakingBlockNumber uint64) *StakingInfo {
-	if stakingManager == nil {
+//   If cache hit                          -> fillMissingGini on a copy -> replaces cached in-memory object
+//   If db hit                             -> fillMissingGini -> write back to db if filled -> write to cache
+//   If read contract -> fillMissingGini -> write to db                                    -> write to cache
+func (sm *StakingManager) GetStakingInfoOnStakingBlock(stakingBlockNumber uint64) *StakingInfo {
+	return sm.serve(sm.getStakingInfoOnStakingBlock(stakingBlockNumber))
+}
+
+// GetStakingInfoOnStakingBlockErr returns a corresponding StakingInfo for a staking block number like
+// GetStakingInfoOnStakingBlock, but it returns the reason why the staking info is not available instead of nil:
+// ErrStakingManagerNotSet if the manager is not set, ErrNotStakingInterval if the given number is not on
+// the staking block, or the error recomputing the staking info.
+func (sm *StakingManager) GetStakingInfoOnStakingBlockErr(stakingBlockNumber uint64) (*StakingInfo, error) {
+	stakingInfo, err := sm.getStakingInfoOnStakingBlockErr(stakingBlockNumber)
+	return sm.serve(stakingInfo), err
+}
+
+// getStakingInfoOnStakingBlock is GetStakingInfoOnStakingBlock serving the cached staking info itself.
+func (sm *StakingManager) getStakingInfoOnStakingBlock(stakingBlockNumber uint64) *StakingInfo {
+	stakingInfo, _ := sm.getStakingInfoOnStakingBlockErr(stakingBlockNumber)
+	return stakingInfo
+}
+
+func (sm *StakingManager) getStakingInfoOnStakingBlockErr(stakingBlockNumber uint64) (*StakingInfo, error) {
+	if sm == nil {
 		logger.Error("unable to GetStakingInfo", "err", ErrStakingManagerNotSet)
-		return nil
+		return nil, ErrStakingManagerNotSet
 	}
 
 	// shortcut if given block is not on staking update interval
 	if !params.IsStakingUpdateInterval(stakingBlockNumber) {
-		return nil
+		return nil, ErrNotStakingInterval
+	}
+
+	if stakingInfo := sm.lookupStakingInfo(stakingBlockNumber); stakingInfo != nil {
+		return stakingInfo, nil
 	}
 
+	// Calculate staking info from block header and updates it to cache and db
+	sm.countRecompute()
+	calcStakingInfo, err := recomputeStakingInfo(sm, stakingBlockNumber)
+	if calcStakingInfo == nil {
+		logger.Error("failed to update stakingInfo", "staking block number", stakingBlockNumber, "err", err)
+		if err == nil {
+			err = ErrStakingInfoNotFound
+		}
+		return nil, err
+	}
+
+	logger.Debug("Get stakingInfo from header.", "staking block number", stakingBlockNumber, "stakingInfo", calcStakingInfo)
+	return calcStakingInfo, nil
+}
+
+// GetStakingInfoWithTimeout returns a stakingInfo on the staking block of the given block number like GetStakingInfo,
+// but it waits for the recomputation of staking info at most the recompute timeout of StakingManager.
+// It is used on the critical path of block production, where a slow state read should not delay sealing.
+//
+// If the staking info cannot be resolved, the staking info served by StakingUnavailablePolicy is returned.
+// The policy is consulted only here, i.e. only while producing a block.
+//
+// If the recomputation is not finished in time, ErrStakingInfoTimeout is returned regardless of
+// StakingUnavailablePolicy, so that the proposer skips the round. Serving other staking info here would make
+// the proposer distribute rewards to addresses different from the ones validators recompute without timeout.
+// The recomputation goes on in background and its result is cached, so that a following call will hit the cache.
+func (sm *StakingManager) GetStakingInfoWithTimeout(blockNum uint64) (*StakingInfo, error) {
+	if sm == nil {
+		return nil, ErrStakingManagerNotSet
+	}
+
+	stakingBlockNumber := params.CalcStakingBlockNumber(blockNum)
+	sm.checkStaleness(stakingBlockNumber)
+
+	if sm.recomputeTimeout == 0 {
+		stakingInfo, err := sm.getStakingInfoOnStakingBlockErr(stakingBlockNumber)
+		if stakingInfo == nil {
+			stakingInfo = sm.stakingInfoOnUnavailable(stakingBlockNumber)
+		}
+		if stakingInfo == nil {
+			if err == nil {
+				err = ErrStakingInfoNotFound
+			}
+			return nil, err
+		}
+		return sm.serve(stakingInfo), nil
+	}
+
+	if stakingInfo := sm.lookupStakingInfo(stakingBlockNumber); stakingInfo != nil {
+		return sm.serve(stakingInfo), nil
+	}
+
+	r := sm.startRecompute(stakingBlockNumber)
+	timer := time.NewTimer(sm.recomputeTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-r.done:
+		if r.stakingInfo == nil {
+			logger.Error("failed to update stakingInfo", "staking block number", stakingBlockNumber, "err", r.err)
+			if stakingInfo := sm.stakingInfoOnUnavailable(stakingBlockNumber); stakingInfo != nil {
+				return sm.serve(stakingInfo), nil
+			}
+			return nil, r.err
+		}
+		return sm.serve(r.stakingInfo), nil
+	case <-timer.C:
+		logger.Warn("Timed out recomputing staking info; it continues in background",
+			"staking block number", stakingBlockNumber, "timeout", sm.recomputeTimeout)
+		return nil, ErrStakingInfoTimeout
+	}
+}
+
+// stakingInfoRecompute is an in-flight recomputation of staking info.
+// The result fields are set before done is closed.
+type stakingInfoRecompute struct {
+	done        chan struct{}
+	stakingInfo *StakingInfo
+	err         error
+}
+
+// startRecompute starts recomputing the staking info of the given staking block number in background,
+// or returns the in-flight recomputation of it if any.
+func (sm *StakingManager) startRecompute(stakingBlockNumber uint64) *stakingInfoRecompute {
+	sm.recomputeLock.Lock()
+	defer sm.recomputeLock.Unlock()
+
+	if r, ok := sm.recomputing[stakingBlockNumber]; ok {
+		return r
+	}
+	if sm.recomputing == nil {
+		sm.recomputing = make(map[uint64]*stakingInfoRecompute)
+	}
+
+	r := &stakingInfoRecompute{done: make(chan struct{})}
+	sm.recomputing[stakingBlockNumber] = r
+	sm.countRecompute()
+	go func() {
+		r.stakingInfo, r.err = recomputeStakingInfo(sm, stakingBlockNumber)
+
+		sm.recomputeLock.Lock()
+		delete(sm.recomputing, stakingBlockNumber)
+		sm.recomputeLock.Unlock()
+		close(r.done)
+	}()
+	return r
+}
+
+// SetMaxStakingLimit sets the cap of a staking amount in KLAY of the staking info computed from now on.
+// Zero means no cap. The default is 100,000,000,000 KLAY.
+// Since the staking amounts decide proposer selection, every node of a network must use the same cap.
+// The staking info already cached or stored is not recomputed.
+func (sm *StakingManager) SetMaxStakingLimit(limit uint64) {
+	sm.addressBookConnector.stakingLimit = limit
+}
+
+// SetRecomputeTimeout sets the maximum time GetStakingInfoWithTimeout waits for the recomputation of staking info.
+// Zero means no limit.
+func (sm *StakingManager) SetRecomputeTimeout(timeout time.Duration) {
+	sm.recomputeTimeout = timeout
+}
+
+// lookupStakingInfo returns the staking info of the given staking block number from cache or DB.
+// It returns nil if it is in neither of them.
+func (sm *StakingManager) lookupStakingInfo(stakingBlockNumber uint64) *StakingInfo {
 	// Get staking info from cache
-	if cachedStakingInfo := stakingManager.stakingInfoCache.get(stakingBlockNumber); cachedStakingInfo != nil {
+	if cachedStakingInfo := sm.stakingInfoCache.get(stakingBlockNumber); cachedStakingInfo != nil {
 		logger.Debug("StakingInfoCache hit.", "staking block number", stakingBlockNumber, "stakingInfo", cachedStakingInfo)
-		// Fill in Gini coeff if not set. Modifies the cached object.
-		if err := fillMissingGiniCoefficient(cachedStakingInfo, stakingBlockNumber); err != nil {
-			logger.Warn("Cannot fill in gini coefficient", "staking block number", stakingBlockNumber, "err", err)
+		atomic.AddUint64(&sm.cacheHits, 1)
+		stakingInfoCacheHitCounter.Inc(1)
+		// Fill in Gini coeff if not set. The cached object is replaced with a filled copy,
+		// since it can be read concurrently by the other callers.
+		if cachedStakingInfo.UseGini && cachedStakingInfo.Gini < 0 {
+			filled := cachedStakingInfo.Clone()
+			if err := sm.fillMissingGiniCoefficient(filled, stakingBlockNumber); err != nil {
+				logger.Warn("Cannot fill in gini coefficient", "staking block number", stakingBlockNumber, "err", err)
+			} else if filled.Gini >= 0 {
+				cachedStakingInfo = sm.stakingInfoCache.replace(cachedStakingInfo, filled)
+			}
+		}
+		if sm.checkConsistency {
+			sm.checkCacheConsistency(cachedStakingInfo)
 		}
 		return cachedStakingInfo
 	}
 
 	// Get staking info from DB
-	if storedStakingInfo, err := getStakingInfoFromDB(stakingBlockNumber); storedStakingInfo != nil && err == nil {
+	if storedStakingInfo, err := sm.getStakingInfoFromDB(stakingBlockNumber); storedStakingInfo != nil && err == nil {
 		logger.Debug("StakingInfoDB hit.", "staking block number", stakingBlockNumber, "stakingInfo", storedStakingInfo)
+		atomic.AddUint64(&sm.dbHits, 1)
+		stakingInfoDBHitCounter.Inc(1)
 		// Fill in Gini coeff before adding to cache.
-		if err := fillMissingGiniCoefficient(storedStakingInfo, stakingBlockNumber); err != nil {
+		giniMissing := storedStakingInfo.UseGini && storedStakingInfo.Gini < 0
+		if err := sm.fillMissingGiniCoefficient(storedStakingInfo, stakingBlockNumber); err != nil {
 			logger.Warn("Cannot fill in gini coefficient", "staking block number", stakingBlockNumber, "err", err)
 		}
-		stakingManager.stakingInfoCache.add(storedStakingInfo)
+		// Write the filled Gini coeff back, not to compute it again on the next DB hit.
+		// Gini -1 is not written back, since it can mean that there is no eligible node.
+		if giniMissing && storedStakingInfo.Gini >= 0 {
+			if err := sm.addStakingInfoToDB(storedStakingInfo); err != nil {
+				logger.Warn("Cannot write back gini coefficient", "staking block number", stakingBlockNumber, "err", err)
+			}
+		}
+		sm.stakingInfoCache.add(storedStakingInfo)
 		return storedStakingInfo
 	} else {
 		logger.Debug("failed to get stakingInfo from DB", "err", err, "staking block number", stakingBlockNumber)
 	}
+	return nil
+}
 
-	// Calculate staking info from block header and updates it to cache and db
-	calcStakingInfo, err := updateStakingInfo(stakingBlockNumber)
-	if calcStakingInfo == nil {
-		logger.Error("failed to update stakingInfo", "staking block number", stakingBlockNumber, "err", err)
+// checkCacheConsistency compares the cached staking info with the one stored in the database,
+// and reports whether they are equal. A staking info missing in the database is not regarded as inconsistent.
+func (sm *StakingManager) checkCacheConsistency(cached *StakingInfo) bool {
+	stored, err := sm.getStakingInfoFromDB(cached.BlockNum)
+	if err != nil {
+		return true
+	}
+	if !cached.Equal(stored) {
+		logger.Error("Cached staking info differs from the stored one", "staking block number", cached.BlockNum,
+			"cached", cached, "stored", stored)
+		stakingInfoInconsistentCounter.Inc(1)
+		return false
+	}
+	return true
+}
+
+func (sm *StakingManager) countRecompute() {
+	atomic.AddUint64(&sm.recomputes, 1)
+	stakingInfoRecomputeCounter.Inc(1)
+}
+
+// StakingInfoStats is the statistics of the sources serving staking info lookups of a StakingManager.
+type StakingInfoStats struct {
+	CacheHits  uint64 // number of lookups served from the cache
+	DBHits     uint64 // number of lookups served from the database
+	Recomputes uint64 // number of recomputations from the AddressBook contract
+	CacheLen   int    // number of staking info in the cache
+}
+
+// StakingInfoStats returns the statistics of the sources serving staking info lookups.
+// The counters are also reported as metrics under reward/stakinginfo.
+func (sm *StakingManager) StakingInfoStats() StakingInfoStats {
+	return StakingInfoStats{
+		CacheHits:  atomic.LoadUint64(&sm.cacheHits),
+		DBHits:     atomic.LoadUint64(&sm.dbHits),
+		Recomputes: atomic.LoadUint64(&sm.recomputes),
+		CacheLen:   sm.stakingInfoCache.len(),
+	}
+}
+
+// DumpCache returns copies of the cached staking info sorted by block number.
+// It is used to warm up the cache of another StakingManager with LoadCache, e.g. of a standby node.
+func (sm *StakingManager) DumpCache() []*StakingInfo {
+	cached := sm.stakingInfoCache.dump()
+	infos := make([]*StakingInfo, len(cached))
+	for i, s := range cached {
+		infos[i] = s.Clone()
+	}
+	return infos
+}
+
+// LoadCache adds the given staking info, e.g. dumped by DumpCache of another StakingManager, to the cache.
+// Every staking info is validated first, and nothing is added if any of them is invalid. A duplicate staking address
+// is not rejected, since it is kept in the staking info computed from the AddressBook as well.
+// Staking info of an older schema is upgraded, and missing Gini coefficients are filled before being added.
+func (sm *StakingManager) LoadCache(infos []*StakingInfo) error {
+	for _, s := range infos {
+		if s == nil {
+			return errors.New("nil staking info")
+		}
+		if !params.IsStakingUpdateInterval(s.BlockNum) {
+			return fmt.Errorf("not staking block number. blockNum: %d", s.BlockNum)
+		}
+		if err := s.validateRelaxed(); err != nil {
+			return fmt.Errorf("invalid staking info. blockNum: %d, err: %w", s.BlockNum, err)
+		}
+	}
+
+	for _, s := range infos {
+		s = s.Clone()
+		s.Upgrade()
+		if err := sm.fillMissingGiniCoefficient(s, s.BlockNum); err != nil {
+			logger.Warn("Cannot fill in gini coefficient", "staking block number", s.BlockNum, "err", err)
+		}
+		sm.stakingInfoCache.add(s)
+	}
+	logger.Info("Loaded staking info to the cache", "count", len(infos))
+	return nil
+}
+
+// SetStakingUnavailablePolicy sets the policy deciding what is served when staking info cannot be resolved.
+// See StakingUnavailablePolicy for the consensus-safety implications.
+func (sm *StakingManager) SetStakingUnavailablePolicy(policy StakingUnavailablePolicy) {
+	sm.unavailablePolicy = policy
+	if policy != "" && policy != StakingUnavailableFail {
+		logger.Warn("Staking info unavailable policy is set. Do not use it on a public network", "policy", policy)
+	}
+}
+
+// stakingInfoOnUnavailable returns the staking info served by the policy when the staking info of
+// the given staking block number cannot be resolved. The returned staking info is not cached.
+func (sm *StakingManager) stakingInfoOnUnavailable(stakingBlockNumber uint64) *StakingInfo {
+	switch sm.unavailablePolicy {
+	case StakingUnavailableUsePrevious:
+		previous := sm.stakingInfoCache.getLatestBefore(stakingBlockNumber)
+		if previous == nil {
+			logger.Error("no previous staking info to use", "staking block number", stakingBlockNumber)
+			return nil
+		}
+		logger.Warn("Staking info is unavailable. Using the staking info of a previous interval",
+			"staking block number", stakingBlockNumber, "previous staking block number", previous.BlockNum)
+		return previous.CloneForBlock(stakingBlockNumber)
+	case StakingUnavailableEmpty:
+		logger.Warn("Staking info is unavailable. Using an empty staking info", "staking block number", stakingBlockNumber)
+		return newEmptyStakingInfo(stakingBlockNumber)
+	default:
 		return nil
 	}
+}
 
-	logger.Debug("Get stakingInfo from header.", "staking block number", stakingBlockNumber, "stakingInfo", calcStakingInfo)
-	return calcStakingInfo
+// EligibleProposers returns the node addresses of the council nodes eligible to propose the given block.
+// Note that it returns node addresses, not reward addresses, since proposers are identified by node addresses.
+//
+// Under the WeightedRandom policy, a node is eligible if the total staking amount of its reward address
+// is greater than or equal to the minimum staking amount. If no node is eligible, all nodes are eligible
+// as the validator set does. Under the other policies, every council node is eligible.
+// The addresses are returned in the order of StakingInfo.CouncilNodeAddrs.
+func (sm *StakingManager) EligibleProposers(blockNum uint64) ([]common.Address, error) {
+	if sm == nil {
+		return nil, ErrStakingManagerNotSet
+	}
+
+	stakingInfo := sm.getStakingInfo(blockNum)
+	if stakingInfo == nil {
+		return nil, ErrStakingInfoNotFound
+	}
+
+	allNodes := make([]common.Address, len(stakingInfo.CouncilNodeAddrs))
+	copy(allNodes, stakingInfo.CouncilNodeAddrs)
+
+	if sm.governanceHelper.ProposerPolicy() != params.WeightedRandom {
+		return allNodes, nil
+	}
+
+	minStaking, err := sm.governanceHelper.GetMinimumStakingAtNumber(blockNum)
+	if err != nil {
+		return nil, err
+	}
+
+	c := stakingInfo.GetConsolidatedStakingInfo()
+	eligibles := make([]common.Address, 0, len(allNodes))
+	for _, nodeAddr := range stakingInfo.CouncilNodeAddrs {
+		if node := c.GetConsolidatedNode(nodeAddr); node != nil && node.StakingAmount >= minStaking {
+			eligibles = append(eligibles, nodeAddr)
+		}
+	}
+	if len(eligibles) == 0 {
+		return allNodes, nil
+	}
+	return eligibles, nil
 }
 
-// updateStakingInfo updates staking info in cache and db created from given block number.
-func updateStakingInfo(blockNum uint64) (*StakingInfo, error) {
-	if stakingManager == nil {
+// CouncilFaultTolerance returns the number of the council nodes eligible to propose the given block (see
+// EligibleProposers), and the maximum number of faulty nodes the council can tolerate under BFT, i.e.
+// floor((n-1)/3). A council of less than 4 nodes cannot tolerate any faulty node.
+func (sm *StakingManager) CouncilFaultTolerance(blockNum uint64) (n int, maxFaulty int, err error) {
+	eligibles, err := sm.EligibleProposers(blockNum)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	n = len(eligibles)
+	if n == 0 {
+		return 0, 0, nil
+	}
+	return n, (n - 1) / 3, nil
+}
+
+// RewardSplitPreview returns how the given block reward of the given block would be split among
+// the reward addresses of the council, the KIR address and the PoC address. It is for analytics only.
+//
+// The block reward is split into CN reward, PoC incentive and KIR incentive in the same way as the
+// block reward distribution. Since the whole CN reward goes to the proposer of a block, the CN reward
+// of a reward address is its expected reward, i.e. the CN reward weighted by its chance to propose.
+// Under the WeightedRandom policy, the chance is proportional to the weight of an eligible reward address
+// calculated as the weighted council does: its staking amount, adjusted by the Gini coefficient of the eligible
+// staking amounts if the staking info uses Gini, out of the total in percent rounded.
+// Under the other policies, it is proportional to the number of its council nodes.
+// As the distribution does, PoC and KIR incentives go to the proposer if the PoC and KIR addresses are empty.
+func (sm *StakingManager) RewardSplitPreview(blockNum uint64, totalReward *big.Int) (map[common.Address]*big.Int, error) {
+	if sm == nil {
 		return nil, ErrStakingManagerNotSet
 	}
 
-	stakingInfo, err := stakingManager.addressBookConnector.getStakingInfoFromAddressBook(blockNum)
+	stakingInfo := sm.getStakingInfo(blockNum)
+	if stakingInfo == nil {
+		return nil, ErrStakingInfoNotFound
+	}
+
+	rewardConfig, err := newRewardConfigCache(sm.governanceHelper).get(blockNum)
 	if err != nil {
 		return nil, err
 	}
 
-	// Add to DB before setting Gini; DB will contain {Gini: -1}
-	if err := AddStakingInfoToDB(stakingInfo); err != nil {
-		logger.Debug("failed to write staking info to db", "err", err, "stakingInfo", stakingInfo)
-		return stakingInfo, err
+	weights, err := sm.proposerWeights(stakingInfo, blockNum)
+	if err != nil {
+		return nil, err
+	}
+
+	split := make(map[common.Address]*big.Int)
+	addTo := func(addr common.Address, amount *big.Int) {
+		if prev, ok := split[addr]; ok {
+			split[addr] = new(big.Int).Add(prev, amount)
+		} else {
+			split[addr] = new(big.Int).Set(amount)
+		}
+	}
+
+	cnReward, pocIncentive, kirIncentive := splitBlockReward(totalReward, rewardConfig)
+	proposerReward := new(big.Int).Set(cnReward)
+	if common.EmptyAddress(stakingInfo.PoCAddr) {
+		proposerReward.Add(proposerReward, pocIncentive)
+	} else {
+		addTo(stakingInfo.PoCAddr, pocIncentive)
+	}
+	if common.EmptyAddress(stakingInfo.KIRAddr) {
+		proposerReward.Add(proposerReward, kirIncentive)
+	} else {
+		addTo(stakingInfo.KIRAddr, kirIncentive)
+	}
+
+	totalWeight := new(big.Int)
+	for _, w := range weights {
+		totalWeight.Add(totalWeight, new(big.Int).SetUint64(w.weight))
+	}
+	if totalWeight.Sign() == 0 {
+		// no proposer to be rewarded
+		return split, nil
 	}
 
-	// Fill in Gini coeff before adding to cache
-	if err := fillMissingGiniCoefficient(stakingInfo, blockNum); err != nil {
+	// the remainder of the division is added to the first reward address
+	remaining := new(big.Int).Set(proposerReward)
+	for _, w := range weights {
+		share := new(big.Int).Mul(proposerReward, new(big.Int).SetUint64(w.weight))
+		share.Div(share, totalWeight)
+		remaining.Sub(remaining, share)
+		addTo(w.rewardAddr, share)
+	}
+	addTo(weights[0].rewardAddr, remaining)
+
+	return split, nil
+}
+
+type proposerWeight struct {
+	rewardAddr common.Address
+	weight     uint64
+}
+
+// proposerWeights returns the relative chance of each reward address to propose a block, in the order of
+// StakingInfo.CouncilRewardAddrs. See RewardSplitPreview for the details.
+func (sm *StakingManager) proposerWeights(stakingInfo *StakingInfo, blockNum uint64) ([]proposerWeight, error) {
+	nodes := stakingInfo.GetConsolidatedStakingInfo().GetAllNodes()
+	weights := make([]proposerWeight, 0, len(nodes))
+
+	if sm.governanceHelper.ProposerPolicy() == params.WeightedRandom {
+		minStaking, err := sm.governanceHelper.GetMinimumStakingAtNumber(blockNum)
+		if err != nil {
+			return nil, err
+		}
+		var eligible []ConsolidatedNode
+		for _, node := range nodes {
+			if node.StakingAmount >= minStaking {
+				eligible = append(eligible, node)
+			}
+		}
+		if len(eligible) == 0 {
+			// all nodes become validators if no one is eligible
+			eligible = nodes
+		}
+
+		amounts := make([]float64, len(eligible))
+		for i, node := range eligible {
+			amounts[i] = float64(node.StakingAmount)
+		}
+		if stakingInfo.UseGini {
+			// CalcGiniCoefficient sorts the given amounts
+			gini := CalcGiniCoefficient(append(float64Slice{}, amounts...))
+			for i := range amounts {
+				amounts[i] = GiniAdjustedStakingAmount(amounts[i], gini)
+			}
+		}
+		total := float64(0)
+		for _, amount := range amounts {
+			total += amount
+		}
+		if total > 0 {
+			for i, node := range eligible {
+				weights = append(weights, proposerWeight{node.RewardAddr, ProposerWeight(amounts[i], total)})
+			}
+			return weights, nil
+		}
+	}
+
+	for _, node := range nodes {
+		weights = append(weights, proposerWeight{node.RewardAddr, uint64(len(node.NodeAddrs))})
+	}
+	return weights, nil
+}
+
+// SetMaxStaleIntervals sets the number of staking intervals a requested staking info can be
+// ahead of the latest refreshed one before a warning is logged. Zero means DefaultMaxStaleIntervals.
+func (sm *StakingManager) SetMaxStaleIntervals(n uint64) {
+	sm.refreshLock.Lock()
+	defer sm.refreshLock.Unlock()
+	sm.maxStaleIntervals = n
+}
+
+// markRefreshed records the staking block number refreshed by the chain head handler.
+// It returns true if the given staking block number is refreshed for the first time.
+func (sm *StakingManager) markRefreshed(stakingBlockNumber uint64) bool {
+	sm.refreshLock.Lock()
+	defer sm.refreshLock.Unlock()
+	isNew := !sm.refreshed || stakingBlockNumber > sm.lastRefreshedBlock
+	if isNew {
+		sm.lastRefreshedBlock = stakingBlockNumber
+	}
+	sm.refreshed = true
+	return isNew
+}
+
+// checkStaleness logs a warning and increases staleStakingInfoCounter if the requested
+// staking block is too far ahead of the latest refreshed one. It means the chain head
+// handler has stalled, and the staking cache is not warmed up anymore.
+func (sm *StakingManager) checkStaleness(stakingBlockNumber uint64) {
+	sm.refreshLock.RLock()
+	refreshed, lastRefreshed, maxStale := sm.refreshed, sm.lastRefreshedBlock, sm.maxStaleIntervals
+	sm.refreshLock.RUnlock()
+
+	if !refreshed || stakingBlockNumber <= lastRefreshed {
+		return
+	}
+	if maxStale == 0 {
+		maxStale = DefaultMaxStaleIntervals
+	}
+	if lag := (stakingBlockNumber - lastRefreshed) / params.StakingUpdateInterval(); lag > maxStale {
+		staleStakingInfoCounter.Inc(1)
+		logger.Warn("Staking info has not been refreshed for a while. Chain head handler may have stalled",
+			"staking block number", stakingBlockNumber, "last refreshed", lastRefreshed, "lag intervals", lag)
+	}
+}
+
+// HistoricalStakingInfo recomputes the staking info of the given staking block number from the state at the block.
+// Unlike GetStakingInfoOnStakingBlock, it always reads the AddressBook contract and neither reads nor writes
+// the cache and the database, so that it shows what a node would have seen at the block.
+// It returns ErrStakingStatePruned if the state at the block is not available.
+func (sm *StakingManager) HistoricalStakingInfo(stakingBlockNumber uint64) (*StakingInfo, error) {
+	if sm == nil {
+		return nil, ErrStakingManagerNotSet
+	}
+	if !params.IsStakingUpdateInterval(stakingBlockNumber) {
+		return nil, fmt.Errorf("not staking block number. blockNum: %d", stakingBlockNumber)
+	}
+
+	block := sm.blockchain.GetBlockByNumber(stakingBlockNumber)
+	if block == nil {
+		return nil, fmt.Errorf("failed to get the block by the given number. blockNum: %d", stakingBlockNumber)
+	}
+	if _, err := sm.blockchain.StateAt(block.Root()); err != nil {
+		return nil, fmt.Errorf("%w. blockNum: %d, root err: %v", ErrStakingStatePruned, stakingBlockNumber, err)
+	}
+
+	stakingInfo, err := sm.addressBookConnector.getStakingInfoFromAddressBook(stakingBlockNumber)
+	if errors.Is(err, ErrAddressBookNotDeployed) {
+		stakingInfo, err = newEmptyStakingInfo(stakingBlockNumber), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := sm.fillMissingGiniCoefficient(stakingInfo, stakingBlockNumber); err != nil {
+		logger.Warn("Cannot fill in gini coefficient", "staking block number", stakingBlockNumber, "err", err)
+	}
+	return stakingInfo, nil
+}
+
+// GetStakingInfoInRange returns the staking info of the staking blocks between the given block numbers (inclusive)
+// in ascending order. Block numbers which are not on the staking update interval are skipped.
+// Like GetStakingInfoOnStakingBlock, the cached staking info is reused, and a staking info missing in both of
+// the cache and the database is recomputed. It fails if any of the staking info cannot be resolved, or if
+// the range covers more than maxStakingInfoRangeIntervals staking blocks.
+//
+// The staking info is looked up one by one, not in a batch: the cache is in memory, and the database has
+// no multi-key read, so that a batch would make the same reads by keys.
+func (sm *StakingManager) GetStakingInfoInRange(fromStakingBlock, toStakingBlock uint64) ([]*StakingInfo, error) {
+	if sm == nil {
+		return nil, ErrStakingManagerNotSet
+	}
+	if fromStakingBlock > toStakingBlock {
+		return nil, fmt.Errorf("invalid range. from: %d, to: %d", fromStakingBlock, toStakingBlock)
+	}
+
+	interval := params.StakingUpdateInterval()
+	first := (fromStakingBlock + interval - 1) / interval * interval
+	if first < fromStakingBlock {
+		// overflowed
+		return nil, fmt.Errorf("no staking block at or after %d", fromStakingBlock)
+	}
+	if first <= toStakingBlock {
+		if n := (toStakingBlock-first)/interval + 1; n > maxStakingInfoRangeIntervals {
+			return nil, fmt.Errorf("too many staking blocks in range. from: %d, to: %d, staking blocks: %d, max: %d",
+				fromStakingBlock, toStakingBlock, n, maxStakingInfoRangeIntervals)
+		}
+	}
+
+	var infos []*StakingInfo
+	for num := first; num <= toStakingBlock; num += interval {
+		stakingInfo := sm.GetStakingInfoOnStakingBlock(num)
+		if stakingInfo == nil {
+			return nil, fmt.Errorf("%w. staking block number: %d", ErrStakingInfoNotFound, num)
+		}
+		infos = append(infos, stakingInfo)
+
+		if num+interval < num {
+			break
+		}
+	}
+	return infos, nil
+}
+
+// updateStakingInfo updates staking info in cache and db created from given block number.
+func (sm *StakingManager) updateStakingInfo(blockNum uint64) (*StakingInfo, error) {
+	if sm == nil {
+		return nil, ErrStakingManagerNotSet
+	}
+
+	if sm.stakingBlockHashes != nil && params.IsStakingUpdateInterval(blockNum) {
+		if block := sm.blockchain.GetBlockByNumber(blockNum); block != nil {
+			sm.setStakingBlockHash(blockNum, block.Hash())
+		}
+	}
+
+	stakingInfo, err := sm.addressBookConnector.getStakingInfoFromAddressBook(blockNum)
+	if errors.Is(err, ErrAddressBookNotDeployed) {
+		// An empty council is stored, since it does not change once the block is finalized.
+		logger.Info("The addressBook is not yet deployed. Use empty stakingInfo", "blockNum", blockNum)
+		stakingInfo, err = newEmptyStakingInfo(blockNum), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in Gini coeff before adding to DB and cache, not to compute it again on a DB hit
+	if err := sm.fillMissingGiniCoefficient(stakingInfo, blockNum); err != nil {
 		logger.Warn("Cannot fill in gini coefficient", "blockNum", blockNum, "err", err)
 	}
 
+	if err := sm.addStakingInfoToDB(stakingInfo); err != nil {
+		logger.Debug("failed to write staking info to db", "err", err, "stakingInfo", stakingInfo)
+		return stakingInfo, err
+	}
+
 	// Add to cache after setting Gini
-	stakingManager.stakingInfoCache.add(stakingInfo)
+	sm.stakingInfoCache.add(stakingInfo)
 
 	logger.Info("Add a new stakingInfo to stakingInfoCache and stakingInfoDB", "blockNum", blockNum)
 	logger.Debug("Added stakingInfo", "stakingInfo", stakingInfo)
@@ -196,25 +1088,35 @@ func updateStakingInfo(blockNum uint64) (*StakingInfo, error) {
 }
 
 // CheckStakingInfoStored makes sure the given staking info is stored in cache and DB
-func CheckStakingInfoStored(blockNum uint64) error {
-	if stakingManager == nil {
-		return ErrStakingManagerNotSet
+func (sm *StakingManager) CheckStakingInfoStored(blockNum uint64) error {
+	_, err := sm.CheckStakingInfoStoredDetailed(blockNum)
+	return err
+}
+
+// CheckStakingInfoStoredDetailed is like CheckStakingInfoStored, but it also returns where the staking info
+// came from: StakingInfoSourceDB if it was already stored in DB, StakingInfoSourceRecompute if it was recomputed
+// and stored, or StakingInfoSourceFailed with the error.
+func (sm *StakingManager) CheckStakingInfoStoredDetailed(blockNum uint64) (source string, err error) {
+	if sm == nil {
+		return StakingInfoSourceFailed, ErrStakingManagerNotSet
 	}
 
 	stakingBlockNumber := params.CalcStakingBlockNumber(blockNum)
 
 	// skip checking if staking info is stored in DB
-	if _, err := getStakingInfoFromDB(stakingBlockNumber); err == nil {
-		return nil
+	if _, err := sm.getStakingInfoFromDB(stakingBlockNumber); err == nil {
+		return StakingInfoSourceDB, nil
 	}
 
 	// update staking info in DB and cache from address book
-	_, err := updateStakingInfo(stakingBlockNumber)
-	return err
+	if _, err := recomputeStakingInfo(sm, stakingBlockNumber); err != nil {
+		return StakingInfoSourceFailed, err
+	}
+	return StakingInfoSourceRecompute, nil
 }
 
 // Fill in StakingInfo.Gini value if not set.
-func fillMissingGiniCoefficient(stakingInfo *StakingInfo, number uint64) error {
+func (sm *StakingManager) fillMissingGiniCoefficient(stakingInfo *StakingInfo, number uint64) error {
 	if !stakingInfo.UseGini {
 		return nil
 	}
@@ -227,43 +1129,247 @@ func fillMissingGiniCoefficient(stakingInfo *StakingInfo, number uint64) error {
 	// - Gini was calculated but there was no eligible node, so Gini = -1.
 	// For the second case, in theory we won't have to recalculalte Gini,
 	// but there is no way to distinguish both. So we just recalculate.
-	minStaking, err := stakingManager.governanceHelper.GetMinimumStakingAtNumber(number)
+	minStaking, ok, err := sm.minimumStakingForGini(number)
 	if err != nil {
 		return err
 	}
+	if !ok {
+		logger.Debug("Skipped filling in missing Gini until the retry backoff elapses", "number", number)
+		return nil
+	}
 
 	c := stakingInfo.GetConsolidatedStakingInfo()
 	if c == nil {
 		return errors.New("Cannot create ConsolidatedStakingInfo")
 	}
 
-	stakingInfo.Gini = c.CalcGiniCoefficientMinStake(minStaking)
+	stakingInfo.Gini = c.CalcGiniCoefficientMinStakeWith(minStaking, sm.getGiniCalculator())
 	logger.Debug("Calculated missing Gini for stored StakingInfo", "number", number, "gini", stakingInfo.Gini)
 	return nil
 }
 
-// StakingManagerSubscribe setups a channel to listen chain head event and starts a goroutine to update staking cache.
-func StakingManagerSubscribe() {
-	if stakingManager == nil {
-		logger.Warn("unable to subscribe; this can slow down node", "err", ErrStakingManagerNotSet)
-		return
+// minimumStakingForGini returns the minimum staking amount at the given staking block number to fill in a missing
+// Gini coefficient. Once the lookup fails, it is skipped until the retry backoff of the staking block elapses,
+// so that a failing governance lookup is not repeated on every cache or DB hit. The backoff doubles on every
+// failure up to giniRetryMaxDelay. It returns false if the lookup is skipped.
+func (sm *StakingManager) minimumStakingForGini(number uint64) (uint64, bool, error) {
+	now := giniRetryNow()
+
+	sm.giniUnavailableLock.Lock()
+	if marker := sm.giniUnavailable[number]; marker != nil && now.Before(marker.retryAt) {
+		sm.giniUnavailableLock.Unlock()
+		return 0, false, nil
+	}
+	sm.giniUnavailableLock.Unlock()
+
+	minStaking, err := sm.governanceHelper.GetMinimumStakingAtNumber(number)
+
+	sm.giniUnavailableLock.Lock()
+	defer sm.giniUnavailableLock.Unlock()
+
+	if err == nil {
+		delete(sm.giniUnavailable, number)
+		return minStaking, true, nil
+	}
+
+	if sm.giniUnavailable == nil {
+		sm.giniUnavailable = make(map[uint64]*giniUnavailableMarker)
+	}
+	marker := sm.giniUnavailable[number]
+	if marker == nil {
+		marker = &giniUnavailableMarker{}
+		sm.giniUnavailable[number] = marker
 	}
+	marker.failures++
 
-	stakingManager.chainHeadSub = stakingManager.blockchain.SubscribeChainHeadEvent(stakingManager.chainHeadChan)
+	delay := giniRetryMaxDelay
+	if shift := marker.failures - 1; shift < 32 && giniRetryBaseDelay<<uint(shift) < giniRetryMaxDelay {
+		delay = giniRetryBaseDelay << uint(shift)
+	}
+	marker.retryAt = now.Add(delay)
+	return 0, false, fmt.Errorf("%w (failures: %d, retry in %v)", err, marker.failures, delay)
+}
 
-	go handleChainHeadEvent()
+// SetGiniCalculator sets the GiniCalculator filling missing Gini coefficients of staking info and calculating
+// Gini coefficients of eligible nodes. Setting nil restores DefaultGiniCalculator.
+// Since the Gini coefficient adjusts the staking amounts for proposer selection, every node of a network must use
+// the same calculator, and an alternative one is only for experiments on a private network.
+// Gini coefficients already filled in the cached or stored staking info are not recalculated.
+func (sm *StakingManager) SetGiniCalculator(calc GiniCalculator) {
+	sm.giniCalculator = calc
+	if sm.eligibleCache != nil {
+		sm.eligibleCache.Purge()
+	}
 }
 
-func handleChainHeadEvent() {
-	if stakingManager == nil {
-		logger.Warn("unable to start chain head event", "err", ErrStakingManagerNotSet)
+func (sm *StakingManager) getGiniCalculator() GiniCalculator {
+	if sm.giniCalculator == nil {
+		return DefaultGiniCalculator
+	}
+	return sm.giniCalculator
+}
+
+// EligibleStakeTotal returns the sum of the staking amounts of the consolidated nodes eligible at the given
+// staking block number, i.e. whose staking amount is greater than or equal to the minimum staking amount.
+// It is the denominator of voting power and quorum calculations.
+func (sm *StakingManager) EligibleStakeTotal(stakingBlockNumber uint64) (uint64, error) {
+	if sm == nil {
+		return 0, ErrStakingManagerNotSet
+	}
+
+	stakingInfo := sm.getStakingInfoOnStakingBlock(stakingBlockNumber)
+	if stakingInfo == nil {
+		return 0, ErrStakingInfoNotFound
+	}
+
+	minStaking, err := sm.governanceHelper.GetMinimumStakingAtNumber(stakingBlockNumber)
+	if err != nil {
+		return 0, err
+	}
+
+	total := uint64(0)
+	for _, node := range sm.eligibleNodes(stakingInfo, minStaking).nodes {
+		total = addStakingAmounts(total, node.StakingAmount)
+	}
+	return total, nil
+}
+
+type eligibleCacheKey struct {
+	stakingBlockNumber uint64
+	minStake           uint64
+}
+
+// eligibleNodeSet is the consolidated nodes of a staking info eligible for a minimum staking amount.
+type eligibleNodeSet struct {
+	stakingInfo *StakingInfo       // the staking info the set is computed from
+	nodes       []ConsolidatedNode // consolidated nodes whose staking amount >= minStake
+	gini        float64            // Gini coefficient of the staking amounts of the nodes
+}
+
+// EligibleNodes returns the consolidated nodes of the given staking block number whose staking amount is
+// greater than or equal to the given minimum staking amount, and the Gini coefficient of their staking amounts.
+// The result is memoized per staking block number and minimum staking amount.
+func (sm *StakingManager) EligibleNodes(stakingBlockNumber uint64, minStake uint64) ([]ConsolidatedNode, float64, error) {
+	if sm == nil {
+		return nil, DefaultGiniCoefficient, ErrStakingManagerNotSet
+	}
+
+	stakingInfo := sm.getStakingInfoOnStakingBlock(stakingBlockNumber)
+	if stakingInfo == nil {
+		return nil, DefaultGiniCoefficient, ErrStakingInfoNotFound
+	}
+
+	set := sm.eligibleNodes(stakingInfo, minStake)
+	nodes := make([]ConsolidatedNode, len(set.nodes))
+	copy(nodes, set.nodes)
+	return nodes, set.gini, nil
+}
+
+// eligibleNodes returns the memoized eligible node set of the given staking info and minimum staking amount,
+// computing it if not memoized. A memoized set is discarded if it is computed from another staking info of
+// the same block number, e.g. after the staking info is evicted from the cache and fetched again.
+func (sm *StakingManager) eligibleNodes(stakingInfo *StakingInfo, minStake uint64) *eligibleNodeSet {
+	key := eligibleCacheKey{stakingInfo.BlockNum, minStake}
+	if sm.eligibleCache != nil {
+		if cached, ok := sm.eligibleCache.Get(key); ok && cached.(*eligibleNodeSet).stakingInfo == stakingInfo {
+			return cached.(*eligibleNodeSet)
+		}
+	}
+
+	c := stakingInfo.GetConsolidatedStakingInfo()
+	set := &eligibleNodeSet{
+		stakingInfo: stakingInfo,
+		nodes:       c.EligibleNodes(minStake),
+		gini:        c.CalcGiniCoefficientMinStakeWith(minStake, sm.getGiniCalculator()),
+	}
+
+	if sm.eligibleCache != nil {
+		sm.eligibleCache.Add(key, set)
+	}
+	return set
+}
+
+// NextStakingChangeBlock returns the first block after the given block number whose staking info is different
+// from that of the given block, i.e. the first block whose staking block number (see params.CalcStakingBlockNumber)
+// is different. The staking info changes at the block right after an interval boundary, from 2*interval+1 on.
+// The staking update interval in effect at the time of the call is used.
+func (sm *StakingManager) NextStakingChangeBlock(blockNum uint64) uint64 {
+	interval := params.StakingUpdateInterval()
+	if blockNum < 2*interval+1 {
+		return 2*interval + 1
+	}
+	return ((blockNum-1)/interval+1)*interval + 1
+}
+
+// SubscribeStakingIntervalChange registers a subscription of the staking block number of a new staking interval.
+// A staking block number is delivered only when a chain head crosses a staking interval boundary.
+// Since the chain head handler waits for the delivery, the given channel should be buffered.
+func (sm *StakingManager) SubscribeStakingIntervalChange(ch chan<- uint64) event.Subscription {
+	return sm.stakingIntervalFeed.Subscribe(ch)
+}
+
+// notifyStakingIntervalChange sends the staking block number of the given chain head to the subscribers
+// if the staking block number differs from that of the previous chain head.
+func (sm *StakingManager) notifyStakingIntervalChange(headNum uint64) {
+	stakingBlockNum := params.CalcStakingBlockNumber(headNum)
+	changed := sm.stakingBlockNumKnown && sm.lastStakingBlockNum != stakingBlockNum
+	sm.lastStakingBlockNum, sm.stakingBlockNumKnown = stakingBlockNum, true
+
+	if changed {
+		logger.Debug("Staking interval changed", "blockNum", headNum, "staking block number", stakingBlockNum)
+		sm.stakingIntervalFeed.Send(stakingBlockNum)
+	}
+}
+
+// StakingManagerSubscribe starts updating the staking cache of the default StakingManager on chain head events.
+func StakingManagerSubscribe() {
+	GetStakingManager().Subscribe()
+}
+
+// StakingManagerSubscribeWithContext is like StakingManagerSubscribe, but the update stops when the given context is done.
+func StakingManagerSubscribeWithContext(ctx context.Context) {
+	GetStakingManager().SubscribeWithContext(ctx)
+}
+
+// StakingManagerUnsubscribe stops updating the staking cache of the default StakingManager.
+func StakingManagerUnsubscribe() {
+	GetStakingManager().Unsubscribe()
+}
+
+// Subscribe setups a channel to listen chain head event and starts a goroutine to update staking cache.
+func (sm *StakingManager) Subscribe() {
+	sm.SubscribeWithContext(context.Background())
+}
+
+// SubscribeWithContext is like Subscribe, but the goroutine also exits when the given context is done.
+// Unsubscribe cancels the context and waits for the goroutine to exit.
+// It is a no-op if already subscribed, until Unsubscribe is called.
+func (sm *StakingManager) SubscribeWithContext(ctx context.Context) {
+	if sm == nil {
+		logger.Warn("unable to subscribe; this can slow down node", "err", ErrStakingManagerNotSet)
 		return
-	} else if stakingManager.chainHeadSub == nil {
-		logger.Info("unable to start chain head event", "err", ErrChainHeadChanNotSet)
+	}
+	if !atomic.CompareAndSwapInt32(&sm.subscribed, 0, 1) {
+		logger.Warn("StakingManager is already subscribed to chain head events")
 		return
 	}
 
-	defer StakingManagerUnsubscribe()
+	ctx, cancel := context.WithCancel(ctx)
+	chainSideChan := make(chan blockchain.ChainSideEvent, chainHeadChanSize)
+	sm.chainHeadSub = sm.blockchain.SubscribeChainHeadEvent(sm.chainHeadChan)
+	sm.chainSideSub = sm.blockchain.SubscribeChainSideEvent(chainSideChan)
+	sm.cancelChainHead = cancel
+	sm.chainHeadDone = make(chan struct{})
+
+	sm.startPrefetchWorkers(ctx)
+	go sm.handleChainHeadEvent(ctx, sm.chainHeadSub, sm.chainSideSub, chainSideChan, sm.chainHeadDone)
+}
+
+func (sm *StakingManager) handleChainHeadEvent(ctx context.Context, headSub, sideSub event.Subscription,
+	chainSideChan chan blockchain.ChainSideEvent, done chan struct{}) {
+	defer close(done)
+	defer headSub.Unsubscribe()
+	defer sideSub.Unsubscribe()
 
 	logger.Info("Start listening chain head event to update stakingInfoCache.")
 
@@ -271,36 +1377,210 @@ func handleChainHeadEvent() {
 		// A real event arrived, process interesting content
 		select {
 		// Handle ChainHeadEvent
-		case ev := <-stakingManager.chainHeadChan:
-			if stakingManager.governanceHelper.ProposerPolicy() == params.WeightedRandom {
-				// check and update if staking info is not valid before for the next update interval blocks
-				stakingInfo := GetStakingInfo(ev.Block.NumberU64() + params.StakingUpdateInterval())
-				if stakingInfo == nil {
-					logger.Error("unable to fetch staking info", "blockNum", ev.Block.NumberU64())
-				}
-			}
-		case <-stakingManager.chainHeadSub.Err():
+		case ev := <-sm.chainHeadChan:
+			sm.handleChainHead(ev.Block.NumberU64())
+		// Handle ChainSideEvent, sent for each block removed from the canonical chain by a reorg
+		// and for each block imported off the canonical chain
+		case ev := <-chainSideChan:
+			sm.handleChainSide(ev.Block)
+		case <-headSub.Err():
+			return
+		case <-sideSub.Err():
+			return
+		case <-ctx.Done():
 			return
 		}
 	}
 }
 
-// StakingManagerUnsubscribe can unsubscribe a subscription on chain head event.
-func StakingManagerUnsubscribe() {
-	if stakingManager == nil {
+// handleChainSide invalidates the staking info computed from the state of a block removed from the canonical chain.
+// Since a side block is also sent when a block is imported off the canonical chain, the staking info of a staking
+// block is invalidated only if the canonical block differs from the one the staking info is computed from.
+// Then the staking info is evicted from the cache and recomputed from the state of the canonical block by the
+// prefetch workers, which also overwrites the staking info in the database.
+func (sm *StakingManager) handleChainSide(block *types.Block) {
+	blockNum := block.NumberU64()
+	if !params.IsStakingUpdateInterval(blockNum) {
+		return
+	}
+	canonical := sm.blockchain.GetBlockByNumber(blockNum)
+	if canonical == nil || canonical.Hash() == block.Hash() {
+		return
+	}
+	if hash, ok := sm.stakingBlockHash(blockNum); ok && hash == canonical.Hash() {
+		// the staking info is computed from the canonical block
+		return
+	}
+	if !sm.stakingInfoCache.remove(blockNum) {
+		if _, err := sm.getStakingInfoFromDB(blockNum); err != nil {
+			// neither cached nor stored; it will be computed from the canonical state on demand
+			return
+		}
+	}
+
+	logger.Info("Staking block is reorganized. Recomputing staking info", "staking block number", blockNum)
+	sm.setStakingBlockHash(blockNum, canonical.Hash())
+	sm.schedulePrefetch(stakingInfoPrefetch{stakingBlockNumber: blockNum, recompute: true})
+}
+
+// stakingBlockHash returns the hash of the block the staking info of the given staking block number is computed from.
+func (sm *StakingManager) stakingBlockHash(stakingBlockNumber uint64) (common.Hash, bool) {
+	if sm.stakingBlockHashes == nil {
+		return common.Hash{}, false
+	}
+	hash, ok := sm.stakingBlockHashes.Get(stakingBlockNumber)
+	if !ok {
+		return common.Hash{}, false
+	}
+	return hash.(common.Hash), true
+}
+
+// setStakingBlockHash records the hash of the block the staking info of the given staking block number is computed from.
+// It should be recorded before the computation, so that a reorg during the computation is not missed.
+func (sm *StakingManager) setStakingBlockHash(stakingBlockNumber uint64, hash common.Hash) {
+	if sm.stakingBlockHashes != nil {
+		sm.stakingBlockHashes.Add(stakingBlockNumber, hash)
+	}
+}
+
+// handleChainHead refreshes the staking info of the next update interval on a new chain head,
+// and warms up the staking info of the preceding intervals up to prefetchIntervals.
+func (sm *StakingManager) handleChainHead(headNum uint64) {
+	sm.notifyStakingIntervalChange(headNum)
+	if sm.governanceHelper.ProposerPolicy() != params.WeightedRandom {
+		return
+	}
+
+	intervals := sm.prefetchIntervals
+	if intervals == 0 {
+		intervals = defaultPrefetchIntervals
+	}
+
+	// check and update if staking info is not valid before for the next update interval blocks
+	interval := params.StakingUpdateInterval()
+	next := params.CalcStakingBlockNumber(headNum + interval)
+	for i := uint64(0); i < intervals && i*interval <= next; i++ {
+		sm.schedulePrefetch(stakingInfoPrefetch{stakingBlockNumber: next - i*interval, refresh: i == 0})
+	}
+}
+
+// stakingInfoPrefetch is a request to fetch the staking info of a staking block number in background.
+type stakingInfoPrefetch struct {
+	stakingBlockNumber uint64
+	refresh            bool // true if it is the staking info of the next update interval
+	recompute          bool // true if the staking info should be recomputed, ignoring the cache and the database
+}
+
+// startPrefetchWorkers starts the prefetch workers, which exit when the given context is done.
+// Staking info is fetched on the chain head handler if no worker is configured.
+func (sm *StakingManager) startPrefetchWorkers(ctx context.Context) {
+	if sm.prefetchWorkers <= 0 {
+		return
+	}
+
+	sm.prefetchLock.Lock()
+	sm.prefetchCh = make(chan stakingInfoPrefetch, prefetchQueueSize)
+	sm.prefetching = make(map[uint64]bool)
+	sm.prefetchLock.Unlock()
+
+	for i := 0; i < sm.prefetchWorkers; i++ {
+		sm.prefetchWG.Add(1)
+		go sm.runPrefetchWorker(ctx, sm.prefetchCh)
+	}
+}
+
+func (sm *StakingManager) runPrefetchWorker(ctx context.Context, prefetchCh <-chan stakingInfoPrefetch) {
+	defer sm.prefetchWG.Done()
+
+	for {
+		select {
+		case req := <-prefetchCh:
+			sm.prefetch(req)
+
+			sm.prefetchLock.Lock()
+			delete(sm.prefetching, req.stakingBlockNumber)
+			sm.prefetchLock.Unlock()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// schedulePrefetch queues the given request to the prefetch workers, unless the same staking block number
+// is already queued or being fetched. If the queue is full, the request is dropped and retried on a next chain head.
+// A recomputation is always queued, and done here if the queue is full since no chain head retries it.
+func (sm *StakingManager) schedulePrefetch(req stakingInfoPrefetch) {
+	queued := false
+	sm.prefetchLock.Lock()
+	prefetchCh := sm.prefetchCh
+	if prefetchCh != nil && (req.recompute || !sm.prefetching[req.stakingBlockNumber]) {
+		select {
+		case prefetchCh <- req:
+			sm.prefetching[req.stakingBlockNumber] = true
+			queued = true
+		default:
+			logger.Debug("Staking info prefetch queue is full", "staking block number", req.stakingBlockNumber)
+		}
+	}
+	sm.prefetchLock.Unlock()
+
+	if prefetchCh == nil || (req.recompute && !queued) {
+		sm.prefetch(req)
+	}
+}
+
+// prefetch fetches the staking info of the requested staking block number, which is added to the cache.
+func (sm *StakingManager) prefetch(req stakingInfoPrefetch) {
+	if req.recompute {
+		sm.countRecompute()
+		if _, err := recomputeStakingInfo(sm, req.stakingBlockNumber); err != nil {
+			logger.Error("failed to recompute staking info of a reorganized staking block",
+				"staking block number", req.stakingBlockNumber, "err", err)
+		}
+		return
+	}
+	stakingInfo := sm.getStakingInfoOnStakingBlock(req.stakingBlockNumber)
+	if stakingInfo == nil {
+		logger.Error("unable to fetch staking info", "staking block number", req.stakingBlockNumber)
+		return
+	}
+	if req.refresh && sm.markRefreshed(stakingInfo.BlockNum) && sm.OnStakingInfoRefreshed != nil {
+		sm.OnStakingInfoRefreshed(stakingInfo.BlockNum, stakingInfo.Clone())
+	}
+}
+
+// Unsubscribe can unsubscribe a subscription on chain head event.
+// It waits for the goroutines handling chain head events and prefetching staking info to exit.
+// It is a no-op if not subscribed.
+func (sm *StakingManager) Unsubscribe() {
+	if sm == nil {
 		logger.Warn("unable to start chain head event", "err", ErrStakingManagerNotSet)
 		return
-	} else if stakingManager.chainHeadSub == nil {
+	} else if sm.chainHeadSub == nil {
 		logger.Info("unable to start chain head event", "err", ErrChainHeadChanNotSet)
 		return
 	}
+	if !atomic.CompareAndSwapInt32(&sm.subscribed, 1, 0) {
+		logger.Debug("StakingManager is already unsubscribed from chain head events")
+		return
+	}
 
-	stakingManager.chainHeadSub.Unsubscribe()
+	if sm.cancelChainHead != nil {
+		sm.cancelChainHead()
+	}
+	sm.chainHeadSub.Unsubscribe()
+	if sm.chainSideSub != nil {
+		sm.chainSideSub.Unsubscribe()
+	}
+	if sm.chainHeadDone != nil {
+		<-sm.chainHeadDone
+	}
+	sm.prefetchWG.Wait()
 }
 
 // TODO-Klaytn-Reward the following methods are used for testing purpose, it needs to be moved into test files.
-// Unlike NewStakingManager(), SetTestStakingManager*() do not trigger once.Do().
-// This way you can avoid irreversible side effects during tests.
+// Unlike NewStakingManager(), SetTestStakingManager*() replace the default StakingManager
+// without registering migration prerequisites. This way you can avoid irreversible side effects during tests.
 
 // SetTestStakingManagerWithChain sets a full-featured staking manager with blockchain, database and cache.
 // Note that this method is used only for testing purpose.
@@ -336,5 +1616,7 @@ func SetTestStakingManagerWithStakingInfoCache(testInfo *StakingInfo) {
 // SetTestStakingManager sets the staking manager for testing purpose.
 // Note that this method is used only for testing purpose.
 func SetTestStakingManager(sm *StakingManager) {
+	stakingManagerLock.Lock()
+	defer stakingManagerLock.Unlock()
 	stakingManager = sm
 }
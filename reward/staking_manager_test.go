@@ -17,12 +17,30 @@
 package reward
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"runtime"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/klaytn/klaytn/blockchain"
+	"github.com/klaytn/klaytn/blockchain/state"
+	"github.com/klaytn/klaytn/blockchain/types"
+	"github.com/klaytn/klaytn/common"
+	"github.com/klaytn/klaytn/event"
 	"github.com/klaytn/klaytn/log"
+	"github.com/klaytn/klaytn/params"
+	"github.com/klaytn/klaytn/rlp"
 	"github.com/klaytn/klaytn/storage/database"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type stakingManagerTestCase struct {
@@ -65,7 +83,7 @@ func generateStakingManagerTestCases() []stakingManagerTestCase {
 
 func resetStakingManagerForTest() {
 	GetStakingManager().stakingInfoCache = newStakingInfoCache()
-	GetStakingManager().stakingInfoDB = database.NewMemoryDBManager()
+	GetStakingManager().stakingInfoDB = NewMemoryStakingInfoDB()
 }
 
 func TestStakingManager_NewStakingManager(t *testing.T) {
@@ -73,7 +91,7 @@ func TestStakingManager_NewStakingManager(t *testing.T) {
 	assert.Nil(t, GetStakingManager())
 	assert.Nil(t, GetStakingInfo(123))
 
-	st, err := updateStakingInfo(456)
+	st, err := GetStakingManager().updateStakingInfo(456)
 	assert.Nil(t, st)
 	assert.EqualError(t, err, ErrStakingManagerNotSet.Error())
 
@@ -84,6 +102,60 @@ func TestStakingManager_NewStakingManager(t *testing.T) {
 	stGet := GetStakingManager()
 	assert.NotNil(t, stNew)
 	assert.Equal(t, stGet, stNew)
+
+	// another manager does not replace the default one
+	stOther := NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), nil)
+	assert.NotNil(t, stOther)
+	assert.True(t, stOther != stNew)
+	assert.Equal(t, stNew, GetStakingManager())
+}
+
+// TestStakingManager_DefaultConcurrent tests that the default StakingManager is read and written safely
+// by concurrent goroutines. Run with -race.
+func TestStakingManager_DefaultConcurrent(t *testing.T) {
+	old := GetStakingManager()
+	defer SetTestStakingManager(old)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetTestStakingManager(&StakingManager{stakingInfoCache: newStakingInfoCache()})
+		}()
+		go func() {
+			defer wg.Done()
+			GetStakingManager()
+		}()
+	}
+	wg.Wait()
+}
+
+// TestStakingManager_MultipleInstances tests that StakingManagers of different chains have their own cache and DB.
+func TestStakingManager_MultipleInstances(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	db1, db2 := database.NewMemoryDBManager(), database.NewMemoryDBManager()
+	sm1 := NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), db1)
+	sm2 := NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), db2)
+
+	info1, info2 := stakingInfoTestCases[1].stakingInfo, stakingInfoTestCases[2].stakingInfo
+	stakingBlockNum := params.StakingUpdateInterval() * 3
+
+	// the same staking block with different staking info
+	copy1, copy2 := *info1, *info2
+	copy1.BlockNum, copy2.BlockNum = stakingBlockNum, stakingBlockNum
+	assert.NoError(t, sm1.addStakingInfoToDB(&copy1))
+	assert.NoError(t, sm2.addStakingInfoToDB(&copy2))
+
+	// each is served from its own DB and then from its own cache
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, copy1.CouncilNodeAddrs, sm1.GetStakingInfoOnStakingBlock(stakingBlockNum).CouncilNodeAddrs)
+		assert.Equal(t, copy2.CouncilNodeAddrs, sm2.GetStakingInfoOnStakingBlock(stakingBlockNum).CouncilNodeAddrs)
+	}
+	assert.NotNil(t, sm1.stakingInfoCache.get(stakingBlockNum))
+	assert.NotNil(t, sm2.stakingInfoCache.get(stakingBlockNum))
+	assert.True(t, sm1.stakingInfoCache != sm2.stakingInfoCache)
 }
 
 // Check that appropriate StakingInfo is returned given various blockNum argument.
@@ -121,6 +193,169 @@ func TestStakingManager_GetFromDB(t *testing.T) {
 	checkGetStakingInfo(t)
 }
 
+// Check that StakingInfo is round-tripped through the memory DB
+func TestStakingManager_MemoryStakingInfoDB(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	SetTestStakingManagerWithDB(NewMemoryStakingInfoDB())
+
+	stakingInfo := stakingInfoTestCases[2].stakingInfo.Clone()
+	stakingInfo.BlockNum = params.StakingUpdateInterval()
+	_, err := GetStakingManager().getStakingInfoFromDB(stakingInfo.BlockNum)
+	assert.True(t, errors.Is(err, ErrStakingInfoNotFound), "err: %v", err)
+
+	assert.NoError(t, AddStakingInfoToDB(stakingInfo))
+	stored, err := GetStakingManager().getStakingInfoFromDB(stakingInfo.BlockNum)
+	assert.NoError(t, err)
+	assert.Equal(t, stakingInfo, stored)
+
+	// the stored staking info is not affected by the written one
+	stakingInfo.CouncilStakingAmounts[0]++
+	stored, err = GetStakingManager().getStakingInfoFromDB(stakingInfo.BlockNum)
+	assert.NoError(t, err)
+	assert.NotEqual(t, stakingInfo.CouncilStakingAmounts[0], stored.CouncilStakingAmounts[0])
+}
+
+// Check that staking info exported from a StakingManager is imported to another one
+func TestStakingManager_ExportImportStakingInfos(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	src := NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), NewMemoryStakingInfoDB())
+	for _, testdata := range stakingManagerTestData {
+		require.NoError(t, src.addStakingInfoToDB(testdata))
+	}
+
+	var exported bytes.Buffer
+	require.NoError(t, src.ExportStakingInfos(&exported))
+
+	dst := NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), NewMemoryStakingInfoDB())
+	require.NoError(t, dst.ImportStakingInfos(bytes.NewReader(exported.Bytes())))
+	for _, testdata := range stakingManagerTestData {
+		assert.Equal(t, src.GetStakingInfoOnStakingBlock(testdata.BlockNum), dst.GetStakingInfoOnStakingBlock(testdata.BlockNum))
+	}
+
+	// a duplicate staking address, which is kept in the staking info computed from the AddressBook, is imported
+	duplicate := stakingInfoTestCases[2].stakingInfo.CloneForBlock(5 * params.StakingUpdateInterval())
+	duplicate.CouncilStakingAddrs[1] = duplicate.CouncilStakingAddrs[0]
+	enc, err := rlp.EncodeToBytes(duplicate)
+	require.NoError(t, err)
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(enc)))
+	records := append(append([]byte{}, exported.Bytes()...), size[:]...)
+	records = append(records, enc...)
+
+	dst = NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), NewMemoryStakingInfoDB())
+	require.NoError(t, dst.ImportStakingInfos(bytes.NewReader(records)))
+	assert.Equal(t, duplicate.CouncilStakingAddrs, dst.GetStakingInfoOnStakingBlock(duplicate.BlockNum).CouncilStakingAddrs)
+
+	// nothing is imported if any record is invalid
+	invalid := stakingInfoTestCases[2].stakingInfo.CloneForBlock(5 * params.StakingUpdateInterval())
+	invalid.CouncilNodeAddrs[1] = invalid.CouncilNodeAddrs[0]
+	enc, err = rlp.EncodeToBytes(invalid)
+	require.NoError(t, err)
+	binary.BigEndian.PutUint32(size[:], uint32(len(enc)))
+	records = append(append([]byte{}, exported.Bytes()...), size[:]...)
+	records = append(records, enc...)
+
+	db := NewMemoryStakingInfoDB()
+	dst = NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), db)
+	err = dst.ImportStakingInfos(bytes.NewReader(records))
+	assert.True(t, errors.Is(err, ErrDuplicateNodeAddr), "err: %v", err)
+	_, err = db.ReadStakingInfo(stakingManagerTestData[0].BlockNum)
+	assert.Equal(t, ErrStakingInfoNotFound, err)
+
+	// a truncated record is rejected
+	err = dst.ImportStakingInfos(bytes.NewReader(exported.Bytes()[:exported.Len()-1]))
+	assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "err: %v", err)
+
+	// the database should be iterable
+	err = NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), &nonIterableStakingInfoDB{}).ExportStakingInfos(&exported)
+	assert.Equal(t, ErrStakingDBNotIterable, err)
+}
+
+// nonIterableStakingInfoDB is a stakingInfoDB which cannot iterate staking info.
+type nonIterableStakingInfoDB struct {
+	stakingInfoDB
+}
+
+// Check that GetStakingInfoErr tells why staking info is not available
+func TestStakingManager_GetStakingInfoErr(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	oldStakingManager := GetStakingManager()
+	SetTestStakingManager(nil)
+	_, err := GetStakingInfoErr(1)
+	assert.Equal(t, ErrStakingManagerNotSet, err)
+
+	SetTestStakingManager(oldStakingManager)
+	resetStakingManagerForTest()
+	errRecompute := errors.New("state read failure")
+	oldRecompute := recomputeStakingInfo
+	defer func() { recomputeStakingInfo = oldRecompute }()
+	recomputeStakingInfo = func(sm *StakingManager, stakingBlockNumber uint64) (*StakingInfo, error) {
+		return nil, errRecompute
+	}
+
+	// no staking info is expected on a block which is not a staking block
+	stakingInfo, err := GetStakingInfoOnStakingBlockErr(params.StakingUpdateInterval() + 1)
+	assert.Nil(t, stakingInfo)
+	assert.Equal(t, ErrNotStakingInterval, err)
+
+	// staking info is expected, but failed to be recomputed
+	stakingInfo, err = GetStakingInfoOnStakingBlockErr(params.StakingUpdateInterval())
+	assert.Nil(t, stakingInfo)
+	assert.Equal(t, errRecompute, err)
+	stakingInfo, err = GetStakingInfoErr(3*params.StakingUpdateInterval() + 1)
+	assert.Nil(t, stakingInfo)
+	assert.Equal(t, errRecompute, err)
+	assert.Nil(t, GetStakingInfo(3*params.StakingUpdateInterval()+1))
+
+	// staking info is served without error
+	for _, testdata := range stakingManagerTestData {
+		GetStakingManager().stakingInfoCache.add(testdata)
+	}
+	stakingInfo, err = GetStakingInfoOnStakingBlockErr(stakingManagerTestData[1].BlockNum)
+	assert.NoError(t, err)
+	assert.Equal(t, stakingManagerTestData[1], stakingInfo)
+}
+
+// Check that CheckStakingInfoStoredDetailed reports where the staking info came from
+func TestStakingManager_CheckStakingInfoStoredDetailed(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	interval := params.StakingUpdateInterval()
+	errRecompute := errors.New("state read failure")
+	oldRecompute := recomputeStakingInfo
+	defer func() { recomputeStakingInfo = oldRecompute }()
+	recomputeStakingInfo = func(sm *StakingManager, stakingBlockNumber uint64) (*StakingInfo, error) {
+		if stakingBlockNumber != 3*interval {
+			return nil, errRecompute
+		}
+		stakingInfo := stakingInfoTestCases[2].stakingInfo.CloneForBlock(stakingBlockNumber)
+		return stakingInfo, sm.addStakingInfoToDB(stakingInfo)
+	}
+
+	// already stored in DB
+	assert.NoError(t, AddStakingInfoToDB(stakingInfoTestCases[2].stakingInfo.CloneForBlock(2*interval)))
+	source, err := CheckStakingInfoStoredDetailed(3*interval + 1)
+	assert.NoError(t, err)
+	assert.Equal(t, StakingInfoSourceDB, source)
+
+	// recomputed, and then stored in DB
+	source, err = CheckStakingInfoStoredDetailed(4*interval + 1)
+	assert.NoError(t, err)
+	assert.Equal(t, StakingInfoSourceRecompute, source)
+	source, err = CheckStakingInfoStoredDetailed(4*interval + 1)
+	assert.NoError(t, err)
+	assert.Equal(t, StakingInfoSourceDB, source)
+
+	// failed to recompute
+	source, err = CheckStakingInfoStoredDetailed(5*interval + 1)
+	assert.Equal(t, errRecompute, err)
+	assert.Equal(t, StakingInfoSourceFailed, source)
+	assert.Equal(t, errRecompute, CheckStakingInfoStored(5*interval+1))
+}
+
 // Even if Gini was -1 in the cache, GetStakingInfo returns valid Gini
 func TestStakingManager_FillGiniFromCache(t *testing.T) {
 	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
@@ -152,3 +387,1221 @@ func TestStakingManager_FillGiniFromDB(t *testing.T) {
 
 	checkGetStakingInfo(t)
 }
+
+// Check that missing Gini is filled by the injected calculator
+func TestStakingManager_GiniCalculator(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	defer sm.SetGiniCalculator(nil)
+
+	var calculated [][]float64
+	sm.SetGiniCalculator(GiniCalculatorFunc(func(amounts []float64) float64 {
+		calculated = append(calculated, amounts)
+		return 0.42
+	}))
+
+	stakingInfo := stakingInfoTestCases[2].stakingInfo.CloneForBlock(params.StakingUpdateInterval())
+	stakingInfo.UseGini = true
+	sm.stakingInfoCache.add(stakingInfo)
+
+	assert.Equal(t, 0.42, GetStakingInfoOnStakingBlock(stakingInfo.BlockNum).Gini)
+	require.Equal(t, 1, len(calculated))
+	assert.Equal(t, len(stakingInfo.CouncilStakingAmounts), len(calculated[0]))
+
+	// Gini of the eligible nodes is calculated by the injected calculator, too
+	_, gini, err := sm.EligibleNodes(stakingInfo.BlockNum, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0.42, gini)
+
+	// the default calculator is restored
+	sm.SetGiniCalculator(nil)
+	_, gini, err = sm.EligibleNodes(stakingInfo.BlockNum, 0)
+	require.NoError(t, err)
+	assert.Equal(t, stakingInfo.GetConsolidatedStakingInfo().CalcGiniCoefficientMinStake(0), gini)
+}
+
+// failingMinStakingGovernance is a testGovernance whose minimum staking lookup fails while err is set.
+type failingMinStakingGovernance struct {
+	*testGovernance
+	err   error
+	calls int
+}
+
+func (g *failingMinStakingGovernance) GetMinimumStakingAtNumber(num uint64) (uint64, error) {
+	g.calls++
+	if g.err != nil {
+		return 0, g.err
+	}
+	return g.testGovernance.GetMinimumStakingAtNumber(num)
+}
+
+// Check that a failing minimum staking lookup is not retried on every access, but after the retry backoff.
+func TestStakingManager_GiniUnavailableBackoff(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	gov := &failingMinStakingGovernance{testGovernance: newDefaultTestGovernance(), err: errors.New("governance error")}
+	oldGov := sm.governanceHelper
+	sm.governanceHelper = gov
+	defer func() { sm.governanceHelper = oldGov }()
+
+	now := time.Now()
+	oldNow := giniRetryNow
+	giniRetryNow = func() time.Time { return now }
+	defer func() { giniRetryNow = oldNow }()
+
+	stakingInfo := stakingInfoTestCases[2].stakingInfo.CloneForBlock(params.StakingUpdateInterval())
+	stakingInfo.UseGini = true
+	sm.stakingInfoCache.add(stakingInfo)
+
+	getGini := func(times int) float64 {
+		var gini float64
+		for i := 0; i < times; i++ {
+			gini = GetStakingInfoOnStakingBlock(stakingInfo.BlockNum).Gini
+		}
+		return gini
+	}
+
+	// looked up once, and skipped until the backoff elapses
+	assert.Equal(t, DefaultGiniCoefficient, getGini(100))
+	assert.Equal(t, 1, gov.calls)
+
+	// the backoff doubles on every failure
+	now = now.Add(giniRetryBaseDelay)
+	getGini(100)
+	assert.Equal(t, 2, gov.calls)
+	now = now.Add(giniRetryBaseDelay)
+	getGini(100)
+	assert.Equal(t, 2, gov.calls)
+	now = now.Add(giniRetryBaseDelay)
+	getGini(100)
+	assert.Equal(t, 3, gov.calls)
+
+	// filled once the lookup succeeds, and not looked up any more
+	gov.err = nil
+	now = now.Add(giniRetryMaxDelay)
+	expected := stakingInfo.GetConsolidatedStakingInfo().CalcGiniCoefficientMinStake(params.DefaultMinimumStake.Uint64())
+	assert.Equal(t, expected, getGini(100))
+	assert.Equal(t, 4, gov.calls)
+	assert.Equal(t, 0, len(sm.giniUnavailable))
+}
+
+// Check that staking info is served and cached concurrently without data races. Run with -race.
+func TestStakingManager_ConcurrentCacheAccess(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	interval := params.StakingUpdateInterval()
+
+	oldRecompute := recomputeStakingInfo
+	defer func() { recomputeStakingInfo = oldRecompute }()
+	recomputeStakingInfo = func(sm *StakingManager, stakingBlockNumber uint64) (*StakingInfo, error) {
+		stakingInfo := stakingInfoTestCases[2].stakingInfo.CloneForBlock(stakingBlockNumber)
+		if err := sm.fillMissingGiniCoefficient(stakingInfo, stakingBlockNumber); err != nil {
+			return nil, err
+		}
+		sm.stakingInfoCache.add(stakingInfo)
+		return stakingInfo, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				// more staking blocks than the cache size, whose Gini is filled on cache hits
+				num := uint64((i+j)%(2*maxStakingCache)+1) * interval
+				if j%3 == 0 {
+					sm.stakingInfoCache.add(stakingInfoTestCases[2].stakingInfo.CloneForBlock(num))
+				}
+				stakingInfo := sm.GetStakingInfoOnStakingBlock(num)
+				if assert.NotNil(t, stakingInfo) {
+					assert.Equal(t, num, stakingInfo.BlockNum)
+					assert.Equal(t, stakingInfoTestCases[2].stakingInfo.Gini, stakingInfo.Gini)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// Once Gini is filled on a DB hit, it is written back to the DB and not computed again
+func TestStakingManager_PersistFilledGini(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	db := database.NewMemoryDBManager()
+	sm := NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), db)
+
+	testdata := stakingInfoTestCases[2].stakingInfo.CloneForBlock(params.StakingUpdateInterval())
+	testdata.Gini = -1 // as stored by Klaytn core
+	require.NoError(t, sm.addStakingInfoToDB(testdata))
+
+	// the first DB hit fills Gini and writes it back
+	stakingInfo := sm.GetStakingInfoOnStakingBlock(testdata.BlockNum)
+	require.NotNil(t, stakingInfo)
+	assert.True(t, stakingInfo.Gini >= 0)
+
+	stored, err := sm.getStakingInfoFromDB(testdata.BlockNum)
+	require.NoError(t, err)
+	assert.Equal(t, stakingInfo.Gini, stored.Gini)
+
+	// the second DB hit does not compute Gini; it would panic without a governance helper
+	other := &StakingManager{stakingInfoCache: newStakingInfoCache(), stakingInfoDB: db}
+	stakingInfo = other.GetStakingInfoOnStakingBlock(testdata.BlockNum)
+	require.NotNil(t, stakingInfo)
+	assert.Equal(t, stored.Gini, stakingInfo.Gini)
+
+	// Gini of no eligible node is not written back
+	noEligible := stakingInfoTestCases[2].stakingInfo.CloneForBlock(2 * params.StakingUpdateInterval())
+	noEligible.CouncilStakingAmounts = []uint64{0, 0, 0, 0}
+	noEligible.Gini = -1
+	require.NoError(t, sm.addStakingInfoToDB(noEligible))
+	assert.NotNil(t, sm.GetStakingInfoOnStakingBlock(noEligible.BlockNum))
+
+	stored, err = sm.getStakingInfoFromDB(noEligible.BlockNum)
+	require.NoError(t, err)
+	assert.Equal(t, float64(-1), stored.Gini)
+}
+
+// Check that cache hits differing from the DB are detected if the consistency check is enabled
+func TestStakingManager_CheckConsistency(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	db := database.NewMemoryDBManager()
+	config := DefaultStakingManagerConfig
+	config.CheckConsistency = true
+	sm, err := NewStakingManagerWithConfig(newTestBlockChain(), newDefaultTestGovernance(), db, config)
+	require.NoError(t, err)
+
+	testdata := stakingInfoTestCases[2].stakingInfo.CloneForBlock(params.StakingUpdateInterval())
+	testdata.Gini = 0.38
+	require.NoError(t, sm.addStakingInfoToDB(testdata))
+
+	// the first lookup is served from the DB, and the second one from the cache
+	require.NotNil(t, sm.GetStakingInfoOnStakingBlock(testdata.BlockNum))
+	cached := sm.stakingInfoCache.get(testdata.BlockNum)
+	require.NotNil(t, cached)
+	assert.True(t, sm.checkCacheConsistency(cached))
+
+	before := stakingInfoInconsistentCounter.Count()
+	diverged := testdata.Clone()
+	diverged.CouncilStakingAmounts[0]++
+	require.NoError(t, sm.addStakingInfoToDB(diverged))
+	assert.False(t, sm.checkCacheConsistency(cached))
+
+	require.NotNil(t, sm.GetStakingInfoOnStakingBlock(testdata.BlockNum))
+	assert.Equal(t, before+2, stakingInfoInconsistentCounter.Count())
+}
+
+// Check that reads are served from the read replica while writes go to the primary DB
+func TestStakingManager_ReadReplicaDB(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	primary := GetStakingManager().stakingInfoDB
+	replica := database.NewMemoryDBManager()
+	GetStakingManager().SetStakingInfoReadDB(replica)
+	defer GetStakingManager().SetStakingInfoReadDB(nil)
+
+	primaryInfo := stakingManagerTestData[2]
+	replicaInfo := stakingManagerTestData[3]
+
+	// Writes go to the primary DB only
+	assert.NoError(t, AddStakingInfoToDB(primaryInfo))
+	_, err := replica.ReadStakingInfo(primaryInfo.BlockNum)
+	assert.Error(t, err)
+	_, err = primary.ReadStakingInfo(primaryInfo.BlockNum)
+	assert.NoError(t, err)
+
+	// Reads hit the replica
+	_, err = GetStakingManager().getStakingInfoFromDB(primaryInfo.BlockNum)
+	assert.Error(t, err)
+
+	replicaJson, err := json.Marshal(replicaInfo)
+	assert.NoError(t, err)
+	assert.NoError(t, replica.WriteStakingInfo(replicaInfo.BlockNum, replicaJson))
+	info, err := GetStakingManager().getStakingInfoFromDB(replicaInfo.BlockNum)
+	assert.NoError(t, err)
+	assert.Equal(t, replicaInfo, info)
+
+	// Reads fall back to the primary when the replica is unset
+	GetStakingManager().SetStakingInfoReadDB(nil)
+	info, err = GetStakingManager().getStakingInfoFromDB(primaryInfo.BlockNum)
+	assert.NoError(t, err)
+	assert.Equal(t, primaryInfo, info)
+}
+
+// Check that a stalled refresh is reported when the requested staking info is far ahead of the latest refresh
+func TestStakingManager_StaleRefresh(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	for _, testdata := range stakingManagerTestData {
+		sm.stakingInfoCache.add(testdata)
+	}
+	sm.SetMaxStaleIntervals(1)
+	defer func() {
+		sm.SetMaxStaleIntervals(0)
+		sm.refreshed, sm.lastRefreshedBlock = false, 0
+	}()
+
+	// nothing is reported before the first refresh
+	before := staleStakingInfoCounter.Count()
+	GetStakingInfo(400000)
+	assert.Equal(t, before, staleStakingInfoCounter.Count())
+
+	// the refresh stalled at staking block 0
+	sm.markRefreshed(0)
+
+	// staking block 86400 is one interval ahead, which is tolerated
+	GetStakingInfo(200000)
+	assert.Equal(t, before, staleStakingInfoCounter.Count())
+
+	// staking block 259200 is three intervals ahead
+	GetStakingInfo(400000)
+	assert.Equal(t, before+1, staleStakingInfoCounter.Count())
+
+	// refreshing resolves the staleness
+	sm.markRefreshed(259200)
+	GetStakingInfo(400000)
+	assert.Equal(t, before+1, staleStakingInfoCounter.Count())
+}
+
+func TestStakingManager_EligibleProposers(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	oldHelper := sm.governanceHelper
+	defer func() { sm.governanceHelper = oldHelper }()
+
+	// n1: above minstaking, n2: exactly minstaking, n3 and n4: less than minstaking
+	stakingInfo := stakingInfoTestCases[4].stakingInfo
+	n := stakingInfo.CouncilNodeAddrs
+
+	// every node is less than minstaking
+	belowMinStaking := &StakingInfo{
+		BlockNum:              5 * 86400,
+		CouncilNodeAddrs:      stakingInfo.CouncilNodeAddrs,
+		CouncilStakingAddrs:   stakingInfo.CouncilStakingAddrs,
+		CouncilRewardAddrs:    stakingInfo.CouncilRewardAddrs,
+		KIRAddr:               stakingInfo.KIRAddr,
+		PoCAddr:               stakingInfo.PoCAddr,
+		CouncilStakingAmounts: []uint64{1000000, 1000000, 0, 0},
+	}
+
+	testcases := []struct {
+		policy      uint64
+		stakingInfo *StakingInfo
+		expected    []common.Address
+	}{
+		{params.RoundRobin, stakingInfo, n},
+		{params.Sticky, stakingInfo, n},
+		{params.WeightedRandom, stakingInfo, []common.Address{n[0], n[1]}},
+		{params.RoundRobin, belowMinStaking, n},
+		{params.WeightedRandom, belowMinStaking, n},
+	}
+	for _, tc := range testcases {
+		sm.stakingInfoCache = newStakingInfoCache()
+		sm.stakingInfoCache.add(tc.stakingInfo)
+
+		gov := newDefaultTestGovernance()
+		gov.policy = tc.policy
+		sm.governanceHelper = gov
+
+		proposers, err := sm.EligibleProposers(tc.stakingInfo.BlockNum + params.StakingUpdateInterval() + 1)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, proposers, "policy: %d", tc.policy)
+	}
+}
+
+func TestStakingManager_CouncilFaultTolerance(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	oldHelper := sm.governanceHelper
+	defer func() { sm.governanceHelper = oldHelper }()
+
+	// n1: above minstaking, n2: exactly minstaking, n3 and n4: less than minstaking
+	stakingInfo := stakingInfoTestCases[4].stakingInfo
+
+	// a council of a single node
+	singleNode := &StakingInfo{
+		BlockNum:              5 * 86400,
+		CouncilNodeAddrs:      stakingInfo.CouncilNodeAddrs[:1],
+		CouncilStakingAddrs:   stakingInfo.CouncilStakingAddrs[:1],
+		CouncilRewardAddrs:    stakingInfo.CouncilRewardAddrs[:1],
+		KIRAddr:               stakingInfo.KIRAddr,
+		PoCAddr:               stakingInfo.PoCAddr,
+		CouncilStakingAmounts: stakingInfo.CouncilStakingAmounts[:1],
+	}
+
+	testcases := []struct {
+		policy            uint64
+		stakingInfo       *StakingInfo
+		expectedN         int
+		expectedMaxFaulty int
+	}{
+		{params.RoundRobin, stakingInfo, 4, 1},
+		{params.WeightedRandom, stakingInfo, 2, 0},
+		{params.RoundRobin, singleNode, 1, 0},
+		{params.WeightedRandom, singleNode, 1, 0},
+	}
+	for _, tc := range testcases {
+		sm.stakingInfoCache = newStakingInfoCache()
+		sm.stakingInfoCache.add(tc.stakingInfo)
+
+		gov := newDefaultTestGovernance()
+		gov.policy = tc.policy
+		sm.governanceHelper = gov
+
+		n, maxFaulty, err := sm.CouncilFaultTolerance(tc.stakingInfo.BlockNum + params.StakingUpdateInterval() + 1)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expectedN, n, "policy: %d", tc.policy)
+		assert.Equal(t, tc.expectedMaxFaulty, maxFaulty, "policy: %d", tc.policy)
+	}
+
+	// no staking manager
+	var nilManager *StakingManager
+	_, _, err := nilManager.CouncilFaultTolerance(1)
+	assert.Equal(t, ErrStakingManagerNotSet, err)
+}
+
+func TestStakingManager_RewardSplitPreview(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	oldHelper := sm.governanceHelper
+	defer func() { sm.governanceHelper = oldHelper }()
+
+	// r1: 20M, r2: exactly minstaking, r3 and r4: less than minstaking
+	stakingInfo := stakingInfoTestCases[4].stakingInfo
+	r, kir, poc := stakingInfo.CouncilRewardAddrs, stakingInfo.KIRAddr, stakingInfo.PoCAddr
+
+	noPoCKIR := &StakingInfo{
+		BlockNum:              stakingInfo.BlockNum,
+		CouncilNodeAddrs:      stakingInfo.CouncilNodeAddrs,
+		CouncilStakingAddrs:   stakingInfo.CouncilStakingAddrs,
+		CouncilRewardAddrs:    stakingInfo.CouncilRewardAddrs,
+		CouncilStakingAmounts: stakingInfo.CouncilStakingAmounts,
+	}
+	noGini := stakingInfo.Clone()
+	noGini.UseGini = false
+
+	// ratio 34/54/12 splits 1000 into CN 340, PoC 540 and KIR 120
+	testcases := []struct {
+		policy      uint64
+		stakingInfo *StakingInfo
+		expected    map[common.Address]int64
+	}{
+		// weights 91 and 9 (20/22 and 2/22 in percent): 340 * 91/100 = 309.4 (+1 of remainder), 340 * 9/100 = 30.6
+		{params.WeightedRandom, noGini, map[common.Address]int64{r[0]: 310, r[1]: 30, poc: 540, kir: 120}},
+		// Gini(20M, 2M) = 0.41 adjusts the amounts to 150673 and 29432, i.e. weights 84 and 16
+		// 340 * 84/100 = 285.6 (+1 of remainder), 340 * 16/100 = 54.4
+		{params.WeightedRandom, stakingInfo, map[common.Address]int64{r[0]: 286, r[1]: 54, poc: 540, kir: 120}},
+		// 340 / 4 = 85
+		{params.RoundRobin, stakingInfo, map[common.Address]int64{r[0]: 85, r[1]: 85, r[2]: 85, r[3]: 85, poc: 540, kir: 120}},
+		// 1000 * 91/100 = 910, 1000 * 9/100 = 90
+		{params.WeightedRandom, noPoCKIR, map[common.Address]int64{r[0]: 910, r[1]: 90}},
+	}
+	for _, tc := range testcases {
+		sm.stakingInfoCache = newStakingInfoCache()
+		sm.stakingInfoCache.add(tc.stakingInfo)
+
+		gov := newDefaultTestGovernance()
+		gov.policy = tc.policy
+		sm.governanceHelper = gov
+
+		totalReward := big.NewInt(1000)
+		split, err := sm.RewardSplitPreview(tc.stakingInfo.BlockNum+params.StakingUpdateInterval()+1, totalReward)
+		require.Nil(t, err)
+
+		sum := big.NewInt(0)
+		actual := make(map[common.Address]int64)
+		for addr, amount := range split {
+			actual[addr] = amount.Int64()
+			sum.Add(sum, amount)
+		}
+		assert.Equal(t, tc.expected, actual, "policy: %d", tc.policy)
+		assert.Equal(t, totalReward, sum)
+	}
+}
+
+func TestStakingManager_RecomputeTimeout(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	defer sm.SetRecomputeTimeout(0)
+
+	expected := stakingInfoTestCases[2].stakingInfo
+	blockNum := expected.BlockNum + params.StakingUpdateInterval() + 1
+
+	// recomputation with an artificially slow state read
+	recomputed := 0
+	oldRecompute := recomputeStakingInfo
+	defer func() { recomputeStakingInfo = oldRecompute }()
+	recomputeStakingInfo = func(sm *StakingManager, stakingBlockNumber uint64) (*StakingInfo, error) {
+		recomputed++
+		time.Sleep(200 * time.Millisecond)
+		sm.stakingInfoCache.add(expected)
+		return expected, nil
+	}
+
+	// times out, while the recomputation goes on
+	sm.SetRecomputeTimeout(20 * time.Millisecond)
+	start := time.Now()
+	stakingInfo, err := GetStakingInfoWithTimeout(blockNum)
+	assert.Nil(t, stakingInfo)
+	assert.Equal(t, ErrStakingInfoTimeout, err)
+	assert.True(t, time.Since(start) < 200*time.Millisecond)
+
+	// the in-flight recomputation is reused
+	_, err = GetStakingInfoWithTimeout(blockNum)
+	assert.Equal(t, ErrStakingInfoTimeout, err)
+
+	// the result of the background recomputation is cached
+	time.Sleep(300 * time.Millisecond)
+	stakingInfo, err = GetStakingInfoWithTimeout(blockNum)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, stakingInfo)
+	assert.Equal(t, 1, recomputed)
+
+	// waits for the recomputation if no timeout is set
+	resetStakingManagerForTest()
+	sm.SetRecomputeTimeout(0)
+	stakingInfo, err = GetStakingInfoWithTimeout(blockNum)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, stakingInfo)
+	assert.Equal(t, 2, recomputed)
+}
+
+// TestStakingManager_RecomputeTimeoutConsistency tests that a proposer timed out recomputing staking info
+// skips the round under any StakingUnavailablePolicy, so that it never seals a block with staking info
+// different from the one validators recompute without timeout.
+func TestStakingManager_RecomputeTimeoutConsistency(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	sm := GetStakingManager()
+	defer sm.SetRecomputeTimeout(0)
+	defer sm.SetStakingUnavailablePolicy(StakingUnavailableFail)
+
+	previous := stakingInfoTestCases[2].stakingInfo
+	blockNum := previous.BlockNum + params.StakingUpdateInterval() + 1
+	stakingBlockNumber := params.CalcStakingBlockNumber(blockNum)
+
+	// the KIR and PoC addresses are changed in the interval of the block
+	expected := previous.CloneForBlock(stakingBlockNumber)
+	expected.KIRAddr = common.HexToAddress("0x1111111111111111111111111111111111111111")
+	expected.PoCAddr = common.HexToAddress("0x2222222222222222222222222222222222222222")
+
+	oldRecompute := recomputeStakingInfo
+	defer func() { recomputeStakingInfo = oldRecompute }()
+	recomputeStakingInfo = func(sm *StakingManager, stakingBlockNumber uint64) (*StakingInfo, error) {
+		time.Sleep(200 * time.Millisecond)
+		sm.stakingInfoCache.add(expected)
+		return expected, nil
+	}
+	sm.SetRecomputeTimeout(20 * time.Millisecond)
+
+	for _, policy := range []StakingUnavailablePolicy{StakingUnavailableFail, StakingUnavailableUsePrevious, StakingUnavailableEmpty} {
+		resetStakingManagerForTest()
+		sm.stakingInfoCache.add(previous)
+		sm.SetStakingUnavailablePolicy(policy)
+
+		// the proposer times out while sealing, and skips the round
+		sealed, err := GetStakingInfoWithTimeout(blockNum)
+		assert.Nil(t, sealed, "policy: %s", policy)
+		assert.True(t, errors.Is(err, ErrStakingInfoTimeout), "policy: %s", policy)
+
+		// a validator verifies the header of the block without timeout
+		verified := GetStakingInfo(blockNum)
+		require.NotNil(t, verified, "policy: %s", policy)
+		assert.Equal(t, expected.KIRAddr, verified.KIRAddr, "policy: %s", policy)
+		assert.Equal(t, expected.PoCAddr, verified.PoCAddr, "policy: %s", policy)
+
+		// the proposer seals the block with the staking info the validator uses
+		sealed, err = GetStakingInfoWithTimeout(blockNum)
+		assert.Nil(t, err, "policy: %s", policy)
+		assert.Equal(t, verified, sealed, "policy: %s", policy)
+	}
+	time.Sleep(300 * time.Millisecond)
+}
+
+func TestStakingManager_EligibleNodes(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	sm.eligibleCache.Purge()
+
+	// n1: above minstaking, n2: exactly minstaking, n3 and n4: less than minstaking
+	stakingInfo := stakingInfoTestCases[4].stakingInfo
+	sm.stakingInfoCache.add(stakingInfo)
+	num, minStake := stakingInfo.BlockNum, uint64(2000000)
+
+	nodes, gini, err := sm.EligibleNodes(num, minStake)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(nodes))
+	assert.Equal(t, stakingInfo.CouncilRewardAddrs[0], nodes[0].RewardAddr)
+	assert.Equal(t, stakingInfo.CouncilRewardAddrs[1], nodes[1].RewardAddr)
+	assert.Equal(t, stakingInfo.Gini, gini)
+
+	// the memoized value is reused
+	key := eligibleCacheKey{num, minStake}
+	memoized, ok := sm.eligibleCache.Get(key)
+	require.True(t, ok)
+	_, _, err = sm.EligibleNodes(num, minStake)
+	require.Nil(t, err)
+	reused, _ := sm.eligibleCache.Get(key)
+	assert.True(t, memoized == reused)
+
+	// memoized per minimum staking amount
+	nodes, _, err = sm.EligibleNodes(num, 0)
+	require.Nil(t, err)
+	assert.Equal(t, 4, len(nodes))
+	assert.Equal(t, 2, sm.eligibleCache.Len())
+
+	// invalidated if the staking info is replaced
+	replaced := stakingInfo.CloneForBlock(num)
+	replaced.CouncilStakingAmounts = []uint64{20000000, 2000000, 2000000, 0}
+	sm.stakingInfoCache = newStakingInfoCache()
+	sm.stakingInfoCache.add(replaced)
+
+	nodes, _, err = sm.EligibleNodes(num, minStake)
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(nodes))
+	recomputed, _ := sm.eligibleCache.Get(key)
+	assert.True(t, memoized != recomputed)
+}
+
+func TestStakingManager_NextStakingChangeBlock(t *testing.T) {
+	sm := GetStakingManager()
+
+	oldInterval := params.StakingUpdateInterval()
+	defer params.SetStakingUpdateInterval(oldInterval)
+
+	for _, interval := range []uint64{86400, 100, 1} {
+		params.SetStakingUpdateInterval(interval)
+
+		for _, blockNum := range []uint64{0, 1, interval, 2 * interval, 2*interval + 1, 3 * interval, 3*interval + 1, 10*interval + interval/2} {
+			next := sm.NextStakingChangeBlock(blockNum)
+			assert.True(t, next > blockNum)
+
+			// the staking block number changes at next, and not before it
+			current := params.CalcStakingBlockNumber(blockNum)
+			assert.NotEqual(t, current, params.CalcStakingBlockNumber(next), "interval: %d, blockNum: %d", interval, blockNum)
+			assert.Equal(t, current, params.CalcStakingBlockNumber(next-1), "interval: %d, blockNum: %d", interval, blockNum)
+		}
+	}
+
+	// across a boundary
+	params.SetStakingUpdateInterval(100)
+	assert.Equal(t, uint64(201), sm.NextStakingChangeBlock(0))
+	assert.Equal(t, uint64(201), sm.NextStakingChangeBlock(200))
+	assert.Equal(t, uint64(301), sm.NextStakingChangeBlock(201))
+	assert.Equal(t, uint64(301), sm.NextStakingChangeBlock(300))
+
+	// after the interval changes, the new interval is used
+	params.SetStakingUpdateInterval(50)
+	assert.Equal(t, uint64(251), sm.NextStakingChangeBlock(201))
+}
+
+func TestStakingManager_EligibleStakeTotal(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	sm.stakingInfoCache.add(stakingInfoTestCases[2].stakingInfo)
+	sm.stakingInfoCache.add(stakingInfoTestCases[3].stakingInfo)
+	sm.stakingInfoCache.add(stakingInfoTestCases[4].stakingInfo)
+
+	testcases := []struct {
+		stakingBlockNumber uint64
+		expected           uint64
+	}{
+		{2 * 86400, 150000000}, // every node is eligible
+		{3 * 86400, 150000000}, // nodes with a common reward address are consolidated
+		{4 * 86400, 22000000},  // nodes below the minimum staking amount are excluded
+	}
+	for _, tc := range testcases {
+		total, err := sm.EligibleStakeTotal(tc.stakingBlockNumber)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.expected, total)
+	}
+
+	// not a staking block
+	_, err := sm.EligibleStakeTotal(86401)
+	assert.Equal(t, ErrStakingInfoNotFound, err)
+}
+
+func TestStakingManager_SubscribeStakingIntervalChange(t *testing.T) {
+	sm := &StakingManager{}
+	ch := make(chan uint64, 10)
+	sub := sm.SubscribeStakingIntervalChange(ch)
+	defer sub.Unsubscribe()
+
+	interval := params.StakingUpdateInterval()
+	testcases := []struct {
+		headNum  uint64
+		expected []uint64
+	}{
+		{3*interval + 1, nil}, // the first chain head is not a change
+		{3*interval + 2, nil}, // same interval
+		{4 * interval, nil},   // same interval
+		{4*interval + 1, []uint64{3 * interval}},
+		{4*interval + 2, nil},
+		{6*interval + 1, []uint64{5 * interval}}, // skipped intervals deliver the latest one only
+	}
+	for _, tc := range testcases {
+		sm.notifyStakingIntervalChange(tc.headNum)
+
+		var delivered []uint64
+		for len(ch) > 0 {
+			delivered = append(delivered, <-ch)
+		}
+		assert.Equal(t, tc.expected, delivered, "headNum: %d", tc.headNum)
+	}
+}
+
+func TestStakingManager_OnStakingInfoRefreshed(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	sm.refreshLock.Lock()
+	sm.refreshed, sm.lastRefreshedBlock = false, 0
+	sm.refreshLock.Unlock()
+	defer func() { sm.OnStakingInfoRefreshed = nil }()
+
+	interval := params.StakingUpdateInterval()
+	first := stakingInfoTestCases[2].stakingInfo.CloneForBlock(interval)
+	second := first.CloneForBlock(2 * interval)
+	sm.stakingInfoCache.add(first)
+	sm.stakingInfoCache.add(second)
+
+	var refreshed []uint64
+	sm.OnStakingInfoRefreshed = func(stakingBlockNumber uint64, info *StakingInfo) {
+		refreshed = append(refreshed, stakingBlockNumber)
+		assert.Equal(t, stakingBlockNumber, info.BlockNum)
+		info.CouncilStakingAmounts[0] = 0 // a copy is given
+	}
+
+	// heads from interval+1 to 2*interval refresh the staking info of interval
+	for _, headNum := range []uint64{interval + 1, interval + 2, 2 * interval, 2*interval + 1, 2*interval + 2} {
+		sm.handleChainHead(headNum)
+	}
+	assert.Equal(t, []uint64{interval, 2 * interval}, refreshed)
+	assert.Equal(t, stakingInfoTestCases[2].stakingInfo.CouncilStakingAmounts, sm.stakingInfoCache.get(interval).CouncilStakingAmounts)
+}
+
+// Check that the chain head handler keeps draining events while staking info is prefetched in background
+func TestStakingManager_PrefetchInBackground(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	sm := GetStakingManager()
+	sm.refreshLock.Lock()
+	sm.refreshed, sm.lastRefreshedBlock = false, 0
+	sm.refreshLock.Unlock()
+	oldWorkers, oldIntervals := sm.prefetchWorkers, sm.prefetchIntervals
+	sm.prefetchWorkers, sm.prefetchIntervals = 2, 2
+
+	// recomputation with an artificially slow state read
+	release := make(chan struct{})
+	oldRecompute := recomputeStakingInfo
+	recomputeStakingInfo = func(sm *StakingManager, stakingBlockNumber uint64) (*StakingInfo, error) {
+		<-release
+		stakingInfo := stakingInfoTestCases[2].stakingInfo.CloneForBlock(stakingBlockNumber)
+		sm.stakingInfoCache.add(stakingInfo)
+		return stakingInfo, nil
+	}
+
+	refreshed := make(chan uint64, 1)
+	sm.OnStakingInfoRefreshed = func(stakingBlockNumber uint64, info *StakingInfo) {
+		refreshed <- stakingBlockNumber
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sm.startPrefetchWorkers(ctx)
+	defer func() {
+		cancel()
+		sm.prefetchWG.Wait()
+		sm.prefetchCh, sm.prefetching = nil, nil
+		sm.prefetchWorkers, sm.prefetchIntervals = oldWorkers, oldIntervals
+		sm.OnStakingInfoRefreshed = nil
+		recomputeStakingInfo = oldRecompute
+	}()
+
+	// the next update interval uses 3*interval, and the current one uses 2*interval
+	interval := params.StakingUpdateInterval()
+	headNum := 3*interval + 1
+
+	// chain heads are handled while the state read is blocked
+	for i := uint64(0); i < 2*prefetchQueueSize; i++ {
+		sm.handleChainHead(headNum + i)
+	}
+	assert.Nil(t, sm.stakingInfoCache.get(3*interval))
+	assert.Equal(t, 0, len(refreshed))
+
+	close(release)
+	select {
+	case stakingBlockNumber := <-refreshed:
+		assert.Equal(t, 3*interval, stakingBlockNumber)
+	case <-time.After(time.Second):
+		t.Fatal("staking info of the next update interval is not refreshed")
+	}
+
+	// both intervals are warmed up
+	deadline := time.Now().Add(time.Second)
+	for sm.stakingInfoCache.get(2*interval) == nil && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.NotNil(t, sm.stakingInfoCache.get(2*interval))
+	assert.NotNil(t, sm.stakingInfoCache.get(3*interval))
+}
+
+// prunedTestBlockChain is a blockChain whose states are all pruned.
+type prunedTestBlockChain struct {
+	*blockchain.BlockChain
+}
+
+func (bc *prunedTestBlockChain) GetBlockByNumber(number uint64) *types.Block {
+	return types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number), Root: common.HexToHash("0x1")})
+}
+
+func (bc *prunedTestBlockChain) StateAt(root common.Hash) (*state.StateDB, error) {
+	return nil, errors.New("missing trie node")
+}
+
+func (bc *prunedTestBlockChain) Config() *params.ChainConfig {
+	return params.TestChainConfig
+}
+
+func TestStakingManager_HistoricalStakingInfo(t *testing.T) {
+	bc := &prunedTestBlockChain{newTestBlockChain()}
+	gov := newDefaultTestGovernance()
+	sm := &StakingManager{
+		addressBookConnector: newAddressBookConnector(bc, gov),
+		governanceHelper:     gov,
+		blockchain:           bc,
+	}
+
+	// not a staking block
+	_, err := sm.HistoricalStakingInfo(params.StakingUpdateInterval() + 1)
+	assert.NotNil(t, err)
+
+	// the state of the staking block is pruned
+	_, err = sm.HistoricalStakingInfo(params.StakingUpdateInterval())
+	assert.True(t, errors.Is(err, ErrStakingStatePruned))
+}
+
+// A staking info stored by a legacy version is upgraded when read from the database
+func TestStakingManager_UpgradeFromDB(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+	resetStakingManagerForTest()
+
+	legacy := *stakingInfoTestCases[2].stakingInfo
+	legacy.SchemaVersion = 0
+	legacy.Gini = 0.99 // untrusted
+	require.Nil(t, AddStakingInfoToDB(&legacy))
+
+	stakingInfo, err := GetStakingManager().getStakingInfoFromDB(legacy.BlockNum)
+	require.Nil(t, err)
+	assert.Equal(t, StakingInfoSchemaVersion, stakingInfo.SchemaVersion)
+	assert.Equal(t, DefaultGiniCoefficient, stakingInfo.Gini)
+
+	// Gini is recomputed when served
+	assert.Equal(t, stakingInfoTestCases[2].stakingInfo, GetStakingInfoOnStakingBlock(legacy.BlockNum))
+}
+
+func TestParseStakingUnavailablePolicy(t *testing.T) {
+	for _, policy := range []StakingUnavailablePolicy{StakingUnavailableFail, StakingUnavailableUsePrevious, StakingUnavailableEmpty} {
+		parsed, err := ParseStakingUnavailablePolicy(string(policy))
+		assert.Nil(t, err)
+		assert.Equal(t, policy, parsed)
+	}
+
+	_, err := ParseStakingUnavailablePolicy("previous")
+	assert.NotNil(t, err)
+}
+
+func TestStakingManager_StakingUnavailablePolicy(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	oldStakingManager := GetStakingManager()
+	defer SetTestStakingManager(oldStakingManager)
+
+	// staking info cannot be read from the AddressBook since every state is pruned
+	SetTestStakingManagerWithChain(&prunedTestBlockChain{newTestBlockChain()}, newDefaultTestGovernance(), database.NewMemoryDBManager())
+	sm := GetStakingManager()
+	sm.stakingInfoCache.add(stakingInfoTestCases[2].stakingInfo) // 2 * 86400
+	sm.stakingInfoCache.add(stakingInfoTestCases[3].stakingInfo) // 3 * 86400
+
+	unavailable := uint64(4 * 86400)
+
+	testcases := []struct {
+		policy             StakingUnavailablePolicy
+		stakingBlockNumber uint64
+		expected           *StakingInfo
+	}{
+		{"", unavailable, nil}, // fail by default
+		{StakingUnavailableFail, unavailable, nil},
+		{StakingUnavailableUsePrevious, unavailable, stakingInfoTestCases[3].stakingInfo.CloneForBlock(unavailable)},
+		{StakingUnavailableUsePrevious, 86400, nil}, // no previous staking info
+		{StakingUnavailableEmpty, unavailable, newEmptyStakingInfo(unavailable)},
+	}
+	for _, tc := range testcases {
+		sm.SetStakingUnavailablePolicy(tc.policy)
+
+		// the policy is consulted only while producing a block
+		blockNum := tc.stakingBlockNumber + params.StakingUpdateInterval() + 1
+		stakingInfo, err := GetStakingInfoWithTimeout(blockNum)
+		assert.Equal(t, tc.expected, stakingInfo, "policy: %s", tc.policy)
+		assert.Equal(t, tc.expected == nil, err != nil, "policy: %s", tc.policy)
+		assert.Nil(t, GetStakingInfoOnStakingBlock(tc.stakingBlockNumber), "policy: %s", tc.policy)
+		assert.Nil(t, GetStakingInfo(blockNum), "policy: %s", tc.policy)
+
+		// the staking info served by the policy is not cached
+		assert.Nil(t, sm.stakingInfoCache.get(unavailable))
+	}
+}
+
+func TestStakingManager_DumpAndLoadCache(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	oldStakingManager := GetStakingManager()
+	defer SetTestStakingManager(oldStakingManager)
+
+	// the active node
+	active := &StakingManager{stakingInfoCache: newStakingInfoCache(), governanceHelper: newDefaultTestGovernance()}
+	for _, testdata := range stakingManagerTestData {
+		active.stakingInfoCache.add(testdata)
+	}
+	dumped := active.DumpCache()
+	assert.Equal(t, stakingManagerTestData, dumped)
+
+	// the dump is a copy of the cache
+	dumped[1].CouncilStakingAmounts[0]++
+	assert.NotEqual(t, dumped[1], active.stakingInfoCache.get(dumped[1].BlockNum))
+	dumped[1].CouncilStakingAmounts[0]--
+
+	// the standby node
+	standby := &StakingManager{stakingInfoCache: newStakingInfoCache(), governanceHelper: newDefaultTestGovernance()}
+	SetTestStakingManager(standby)
+	require.Nil(t, standby.LoadCache(dumped))
+	assert.Equal(t, dumped, standby.DumpCache())
+
+	// a dump of staking info with a duplicate staking address, which is kept for consensus, is loaded
+	duplicate := stakingInfoTestCases[4].stakingInfo.Clone()
+	duplicate.CouncilStakingAddrs[1] = duplicate.CouncilStakingAddrs[0]
+
+	standby.stakingInfoCache = newStakingInfoCache()
+	require.Nil(t, standby.LoadCache([]*StakingInfo{duplicate}))
+	assert.Equal(t, duplicate.CouncilStakingAddrs, standby.DumpCache()[0].CouncilStakingAddrs)
+
+	// nothing is loaded if any staking info is invalid
+	invalid := stakingInfoTestCases[4].stakingInfo.Clone()
+	invalid.CouncilNodeAddrs[1] = invalid.CouncilNodeAddrs[0]
+
+	standby.stakingInfoCache = newStakingInfoCache()
+	assert.True(t, errors.Is(standby.LoadCache([]*StakingInfo{stakingInfoTestCases[1].stakingInfo, invalid}), ErrDuplicateNodeAddr))
+	assert.Equal(t, 0, len(standby.DumpCache()))
+
+	notStakingBlock := stakingInfoTestCases[1].stakingInfo.Clone()
+	notStakingBlock.BlockNum++
+	assert.NotNil(t, standby.LoadCache([]*StakingInfo{notStakingBlock}))
+	assert.Equal(t, 0, len(standby.DumpCache()))
+}
+
+func TestStakingManager_SubscribeWithContext(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	waitExit := func(sm *StakingManager) {
+		select {
+		case <-sm.chainHeadDone:
+		case <-time.After(time.Second):
+			t.Fatal("chain head handler did not exit")
+		}
+	}
+
+	// exits on the cancellation of the context
+	sm := NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), database.NewMemoryDBManager())
+	ctx, cancel := context.WithCancel(context.Background())
+	sm.SubscribeWithContext(ctx)
+	cancel()
+	waitExit(sm)
+
+	// exits on Unsubscribe, which waits for the exit
+	sm = NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), database.NewMemoryDBManager())
+	sm.SubscribeWithContext(context.Background())
+	unsubscribed := make(chan struct{})
+	go func() {
+		sm.Unsubscribe()
+		close(unsubscribed)
+	}()
+	select {
+	case <-unsubscribed:
+	case <-time.After(time.Second):
+		t.Fatal("Unsubscribe did not return")
+	}
+	waitExit(sm)
+
+	// Unsubscribe again does not block
+	sm.Unsubscribe()
+}
+
+// subscriptionCountingBlockChain is a blockChain counting live subscriptions to chain events.
+type subscriptionCountingBlockChain struct {
+	*blockchain.BlockChain
+	headFeed, sideFeed event.Feed
+	scope              event.SubscriptionScope
+}
+
+func (bc *subscriptionCountingBlockChain) SubscribeChainHeadEvent(ch chan<- blockchain.ChainHeadEvent) event.Subscription {
+	return bc.scope.Track(bc.headFeed.Subscribe(ch))
+}
+
+func (bc *subscriptionCountingBlockChain) SubscribeChainSideEvent(ch chan<- blockchain.ChainSideEvent) event.Subscription {
+	return bc.scope.Track(bc.sideFeed.Subscribe(ch))
+}
+
+func TestStakingManager_SubscribeTwice(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	bc := &subscriptionCountingBlockChain{BlockChain: newTestBlockChain()}
+	sm := NewStakingManager(bc, newDefaultTestGovernance(), database.NewMemoryDBManager())
+
+	sm.Subscribe()
+	goroutines := runtime.NumGoroutine()
+	chainHeadDone := sm.chainHeadDone
+
+	// the second subscription is a no-op
+	sm.Subscribe()
+	assert.Equal(t, goroutines, runtime.NumGoroutine())
+	assert.Equal(t, 2, bc.scope.Count()) // a chain head and a chain side subscription
+	assert.True(t, chainHeadDone == sm.chainHeadDone)
+
+	// a chain side event is delivered to the only handler
+	sent := bc.sideFeed.Send(blockchain.ChainSideEvent{Block: types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1)})})
+	assert.Equal(t, 1, sent)
+
+	// unsubscribing twice does not block
+	sm.Unsubscribe()
+	sm.Unsubscribe()
+	assert.Equal(t, 0, bc.scope.Count())
+
+	// it can subscribe again after unsubscribing
+	sm.Subscribe()
+	assert.Equal(t, 2, bc.scope.Count())
+	sm.Unsubscribe()
+	assert.Equal(t, 0, bc.scope.Count())
+}
+
+func TestStakingManager_StakingInfoStats(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	sm := NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), database.NewMemoryDBManager())
+	interval := params.StakingUpdateInterval()
+
+	oldRecompute := recomputeStakingInfo
+	defer func() { recomputeStakingInfo = oldRecompute }()
+	recomputeStakingInfo = func(sm *StakingManager, stakingBlockNumber uint64) (*StakingInfo, error) {
+		s := stakingInfoTestCases[2].stakingInfo.CloneForBlock(stakingBlockNumber)
+		sm.stakingInfoCache.add(s)
+		return s, nil
+	}
+
+	// cache hit
+	sm.stakingInfoCache.add(stakingInfoTestCases[2].stakingInfo.CloneForBlock(interval))
+	assert.NotNil(t, sm.GetStakingInfoOnStakingBlock(interval))
+	assert.Equal(t, StakingInfoStats{CacheHits: 1, CacheLen: 1}, sm.StakingInfoStats())
+
+	// db hit, and then cache hit
+	assert.NoError(t, sm.addStakingInfoToDB(stakingInfoTestCases[2].stakingInfo.CloneForBlock(2*interval)))
+	assert.NotNil(t, sm.GetStakingInfoOnStakingBlock(2*interval))
+	assert.NotNil(t, sm.GetStakingInfoOnStakingBlock(2*interval))
+	assert.Equal(t, StakingInfoStats{CacheHits: 2, DBHits: 1, CacheLen: 2}, sm.StakingInfoStats())
+
+	// recomputation, and then cache hit
+	assert.NotNil(t, sm.GetStakingInfoOnStakingBlock(3*interval))
+	assert.NotNil(t, sm.GetStakingInfoOnStakingBlock(3*interval))
+	assert.Equal(t, StakingInfoStats{CacheHits: 3, DBHits: 1, Recomputes: 1, CacheLen: 3}, sm.StakingInfoStats())
+}
+
+func TestStakingManager_StakingCacheSize(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	// invalid size
+	_, err := NewStakingManagerWithConfig(newTestBlockChain(), newDefaultTestGovernance(), nil, StakingManagerConfig{StakingCacheSize: -1})
+	assert.Error(t, err)
+
+	// default size if zero
+	sm, err := NewStakingManagerWithConfig(newTestBlockChain(), newDefaultTestGovernance(), nil, StakingManagerConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, maxStakingCache, sm.stakingInfoCache.maxSize)
+
+	// the oldest is evicted
+	sm, err = NewStakingManagerWithConfig(newTestBlockChain(), newDefaultTestGovernance(), nil, StakingManagerConfig{StakingCacheSize: 2})
+	require.NoError(t, err)
+
+	interval := params.StakingUpdateInterval()
+	for _, num := range []uint64{interval, 2 * interval, 3 * interval} {
+		sm.stakingInfoCache.add(stakingInfoTestCases[2].stakingInfo.CloneForBlock(num))
+	}
+	assert.Nil(t, sm.stakingInfoCache.get(interval))
+	assert.NotNil(t, sm.stakingInfoCache.get(2*interval))
+	assert.NotNil(t, sm.stakingInfoCache.get(3*interval))
+	assert.Equal(t, 2, sm.StakingInfoStats().CacheLen)
+}
+
+func TestStakingManager_GetStakingInfoInRange(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	sm := NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), database.NewMemoryDBManager())
+	interval := params.StakingUpdateInterval()
+
+	// staking info is computed from the mock chain only on staking blocks
+	var recomputed []uint64
+	oldRecompute := recomputeStakingInfo
+	defer func() { recomputeStakingInfo = oldRecompute }()
+	recomputeStakingInfo = func(sm *StakingManager, stakingBlockNumber uint64) (*StakingInfo, error) {
+		recomputed = append(recomputed, stakingBlockNumber)
+		s := stakingInfoTestCases[2].stakingInfo.CloneForBlock(stakingBlockNumber)
+		sm.stakingInfoCache.add(s)
+		return s, nil
+	}
+
+	// a DB entry is reused
+	require.NoError(t, sm.addStakingInfoToDB(stakingInfoTestCases[2].stakingInfo.CloneForBlock(3*interval)))
+
+	infos, err := sm.GetStakingInfoInRange(interval-10, 5*interval+10)
+	require.NoError(t, err)
+
+	var blockNums []uint64
+	for _, info := range infos {
+		blockNums = append(blockNums, info.BlockNum)
+	}
+	assert.Equal(t, []uint64{interval, 2 * interval, 3 * interval, 4 * interval, 5 * interval}, blockNums)
+	assert.Equal(t, []uint64{interval, 2 * interval, 4 * interval, 5 * interval}, recomputed)
+
+	// no staking block in the range
+	infos, err = sm.GetStakingInfoInRange(interval+1, 2*interval-1)
+	assert.NoError(t, err)
+	assert.Empty(t, infos)
+
+	// invalid range
+	_, err = sm.GetStakingInfoInRange(2*interval, interval)
+	assert.Error(t, err)
+
+	// too many staking blocks, rejected without recomputing any
+	_, err = sm.GetStakingInfoInRange(interval, (maxStakingInfoRangeIntervals+1)*interval)
+	assert.Error(t, err)
+	assert.Equal(t, 4, len(recomputed))
+
+	// no staking block is left before overflowing
+	_, err = sm.GetStakingInfoInRange(math.MaxUint64-1, math.MaxUint64)
+	assert.Error(t, err)
+}
+
+// reorgTestBlockChain is a blockChain whose canonical blocks can be replaced.
+type reorgTestBlockChain struct {
+	*blockchain.BlockChain
+	canonical map[uint64]*types.Block
+}
+
+func (bc *reorgTestBlockChain) GetBlockByNumber(number uint64) *types.Block {
+	return bc.canonical[number]
+}
+
+// newReorgTestBlock returns a block of the given number, whose hash differs by the given fork name.
+func newReorgTestBlock(number uint64, fork string) *types.Block {
+	return types.NewBlockWithHeader(&types.Header{Number: new(big.Int).SetUint64(number), Extra: []byte(fork)})
+}
+
+func TestStakingManager_InvalidateOnReorg(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	interval := params.StakingUpdateInterval()
+	bc := &reorgTestBlockChain{newTestBlockChain(), make(map[uint64]*types.Block)}
+	for _, num := range []uint64{interval, interval + 1, 2 * interval} {
+		bc.canonical[num] = newReorgTestBlock(num, "canonical")
+	}
+	sm := NewStakingManager(bc, newDefaultTestGovernance(), database.NewMemoryDBManager())
+
+	// staking info computed from the state of an orphaned block
+	orphaned := stakingInfoTestCases[2].stakingInfo.CloneForBlock(interval)
+	sm.stakingInfoCache.add(orphaned)
+
+	// the state of the canonical block has different balances
+	canonicalAmounts := []uint64{80000000, 40000000, 20000000, 10000000}
+	recomputed := 0
+	oldRecompute := recomputeStakingInfo
+	defer func() { recomputeStakingInfo = oldRecompute }()
+	recomputeStakingInfo = func(sm *StakingManager, stakingBlockNumber uint64) (*StakingInfo, error) {
+		recomputed++
+		s := stakingInfoTestCases[2].stakingInfo.CloneForBlock(stakingBlockNumber)
+		s.CouncilStakingAmounts = canonicalAmounts
+		sm.stakingInfoCache.add(s)
+		return s, nil
+	}
+
+	// a reorg not crossing the staking block does not affect the staking info
+	sm.handleChainSide(newReorgTestBlock(interval+1, "orphaned"))
+	assert.True(t, orphaned == sm.GetStakingInfoOnStakingBlock(interval))
+
+	// a reorg past the staking block refreshes the staking info
+	sm.handleChainSide(newReorgTestBlock(interval, "orphaned"))
+	stakingInfo := sm.GetStakingInfoOnStakingBlock(interval)
+	require.NotNil(t, stakingInfo)
+	assert.Equal(t, canonicalAmounts, stakingInfo.CouncilStakingAmounts)
+	assert.Equal(t, 1, recomputed)
+
+	// a side block imported off the canonical chain does not affect the staking info computed from the canonical block
+	sm.handleChainSide(newReorgTestBlock(interval, "side"))
+	assert.True(t, stakingInfo == sm.GetStakingInfoOnStakingBlock(interval))
+	assert.Equal(t, 1, recomputed)
+
+	// another reorg replacing the canonical block refreshes the staking info again
+	bc.canonical[interval] = newReorgTestBlock(interval, "new canonical")
+	sm.handleChainSide(newReorgTestBlock(interval, "canonical"))
+	assert.Equal(t, 2, recomputed)
+
+	// a staking block neither cached nor stored is not computed
+	sm.handleChainSide(newReorgTestBlock(2*interval, "orphaned"))
+	assert.Nil(t, sm.stakingInfoCache.get(2*interval))
+	assert.Equal(t, 2, recomputed)
+}
+
+func TestStakingManager_SafeCopy(t *testing.T) {
+	log.EnableLogForTest(log.LvlCrit, log.LvlDebug)
+
+	sm := NewStakingManager(newTestBlockChain(), newDefaultTestGovernance(), database.NewMemoryDBManager())
+	cached := stakingInfoTestCases[2].stakingInfo.Clone()
+	sm.stakingInfoCache.add(cached)
+	blockNum := cached.BlockNum + params.StakingUpdateInterval() + 1
+
+	// the cached staking info is served by default
+	assert.True(t, cached == sm.GetStakingInfo(blockNum))
+
+	// a copy is served if safe copy is enabled
+	sm.SetSafeCopy(true)
+	served := sm.GetStakingInfo(blockNum)
+	require.NotNil(t, served)
+	assert.True(t, cached != served)
+	assert.Equal(t, cached, served)
+
+	served.CouncilStakingAmounts[0] = 0
+	served.CouncilNodeAddrs[0] = common.Address{}
+	served.CouncilRewardAddrs[0], served.CouncilRewardAddrs[1] = served.CouncilRewardAddrs[1], served.CouncilRewardAddrs[0]
+	assert.Equal(t, stakingInfoTestCases[2].stakingInfo, sm.stakingInfoCache.get(cached.BlockNum))
+	assert.Equal(t, stakingInfoTestCases[2].stakingInfo, sm.GetStakingInfoOnStakingBlock(cached.BlockNum))
+}
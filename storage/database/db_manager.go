@@ -277,6 +277,7 @@ type DBManager interface {
 	// StakingInfo related functions
 	ReadStakingInfo(blockNum uint64) ([]byte, error)
 	WriteStakingInfo(blockNum uint64, stakingInfo []byte) error
+	IterateStakingInfos(fn func(blockNum uint64, stakingInfo []byte) error) error
 
 	// DB migration related function
 	StartDBMigration(DBManager) error
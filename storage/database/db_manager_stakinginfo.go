@@ -16,6 +16,12 @@
 
 package database
 
+import (
+	"encoding/binary"
+
+	"github.com/klaytn/klaytn/common"
+)
+
 // ReadStakingInfo reads staking information from database. It returns
 // (StakingInfo, nil) if it succeeds to read and (nil, error) if it fails.
 // StakingInfo is stored in MiscDB.
@@ -46,3 +52,24 @@ func (dbm *databaseManager) WriteStakingInfo(blockNum uint64, stakingInfo []byte
 	key := makeKey(stakingInfoPrefix, blockNum)
 	return db.Put(key, stakingInfo)
 }
+
+// IterateStakingInfos calls fn with every staking information stored in database,
+// not in the order of block numbers. It stops and returns the error if fn returns an error.
+func (dbm *databaseManager) IterateStakingInfos(fn func(blockNum uint64, stakingInfo []byte) error) error {
+	db := dbm.getDatabase(MiscDB)
+
+	it := db.NewIterator(stakingInfoPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		key := it.Key()
+		if len(key) != len(stakingInfoPrefix)+8 {
+			continue
+		}
+		blockNum := binary.LittleEndian.Uint64(key[len(stakingInfoPrefix):])
+		if err := fn(blockNum, common.CopyBytes(it.Value())); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
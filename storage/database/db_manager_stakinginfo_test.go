@@ -18,6 +18,7 @@ package database
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -40,3 +41,43 @@ func TestDatabaseManager_StakingInfo(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestDatabaseManager_IterateStakingInfos(t *testing.T) {
+	dbm := dbManagers[0]
+
+	written := map[uint64][]byte{
+		86400:  []byte("{\"BlockNum\":86400}"),
+		172800: []byte("{\"BlockNum\":172800}"),
+		259200: []byte("{\"BlockNum\":259200}"),
+	}
+	for blockNum, value := range written {
+		if err := dbm.WriteStakingInfo(blockNum, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found := make(map[uint64][]byte)
+	err := dbm.IterateStakingInfos(func(blockNum uint64, stakingInfo []byte) error {
+		found[blockNum] = stakingInfo
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for blockNum, value := range written {
+		if !bytes.Equal(value, found[blockNum]) {
+			t.Fatalf("staking info of block %d is not iterated. expected: %s, actual: %s", blockNum, value, found[blockNum])
+		}
+	}
+
+	// iteration stops at an error
+	errStop := errors.New("stop")
+	count := 0
+	err = dbm.IterateStakingInfos(func(blockNum uint64, stakingInfo []byte) error {
+		count++
+		return errStop
+	})
+	if err != errStop || count != 1 {
+		t.Fatalf("iteration is not stopped. err: %v, count: %d", err, count)
+	}
+}
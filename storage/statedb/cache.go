@@ -18,6 +18,7 @@ package statedb
 
 import (
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -36,8 +37,41 @@ type TrieNodeCacheConfig struct {
 	FastCacheSavePeriod       time.Duration // Period of saving in memory trie cache to file if fastcache is used
 	RedisEndpoints            []string      // Endpoints of redis cache
 	RedisClusterEnable        bool          // Enable cluster-enabled mode of redis cache
+	RedisSentinelEnable       bool          // Enable sentinel mode of redis cache; RedisEndpoints are the addresses of the sentinels
+	RedisMasterName           string        // Name of the master monitored by the sentinels in sentinel mode
+	RedisUsername             string        // ACL username of the redis server; the default user is used if empty. Not used in sentinel mode
+	RedisPassword             string        // Password of the redis server (requirepass or ACL); no authentication if empty
 	RedisPublishBlockEnable   bool          // Enable publishing every inserted block to the redis server
 	RedisSubscribeBlockEnable bool          // Enable subscribing blocks from the redis server
+	RedisRepopulateEnable     bool          // Enable re-populating the redis server with items found locally but evicted from redis (hybrid only)
+	RedisTLSEnable            bool          // Enable TLS of the connections to the redis server
+	RedisTLSSkipVerify        bool          // Skip verifying the certificate of the redis server; for testing only
+	RedisTLSCAFile            string        // CA certificate file verifying the redis server; the system CAs are used if empty
+	RedisTLSCertFile          string        // Client certificate file for mutual TLS with the redis server
+	RedisTLSKeyFile           string        // Client private key file for mutual TLS with the redis server
+	RedisSetItemChannelSize   int           // Size of the channel of items written to the redis server asynchronously; the default is used if zero
+	RedisSetRetries           int           // Number of retries of a failed asynchronous write to the redis server; the default is used if zero, and no retry if negative
+	RedisSetRetryBackoff      time.Duration // Backoff before the first retry of a failed asynchronous write, doubled on every retry; the default is used if zero
+	RedisKeyPrefix            string        // Prefix of every key and channel name in the redis server, to share it with other nodes
+	RedisEntryTTL             time.Duration // Expiration of the items written to the redis server; no expiration if zero
+	RedisDialTimeout          time.Duration // Timeout of connecting to the redis server; the default is used if zero
+	RedisReadTimeout          time.Duration // Timeout of reading from the redis server; the default is used if zero
+	RedisWriteTimeout         time.Duration // Timeout of writing to the redis server; the default is used if zero
+	RedisPoolSize             int           // Maximum number of connections to the redis server (per node in cluster mode); the default is used if zero
+	RedisMinIdleConns         int           // Minimum number of idle connections to the redis server; the default is used if zero
+
+	LocalCacheEvictionPolicy LocalCacheEvictionPolicy // Eviction policy of the local cache of a hybrid cache: "" (fastcache), "lru" or "lfu"
+	RedisCompression         RedisCompressionType     // Compression of the values written to the redis server: "" (none) or "snappy"
+}
+
+// String returns the config with the redis password redacted, not to expose it in logs.
+func (c TrieNodeCacheConfig) String() string {
+	type plain TrieNodeCacheConfig // without the String method, not to recurse
+	redacted := plain(c)
+	if redacted.RedisPassword != "" {
+		redacted.RedisPassword = "<redacted>"
+	}
+	return fmt.Sprintf("%+v", redacted)
 }
 
 func (c *TrieNodeCacheConfig) DumpPeriodically() bool {
@@ -53,6 +87,7 @@ type TrieNodeCache interface {
 	Set(k, v []byte)
 	Get(k []byte) []byte
 	Has(k []byte) ([]byte, bool)
+	Delete(k []byte)
 	UpdateStats() interface{}
 	SaveToFile(filePath string, concurrency int) error
 	Close() error
@@ -0,0 +1,288 @@
+// Copyright 2021 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package statedb
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/units"
+	"github.com/klaytn/klaytn/common"
+)
+
+// evictingCacheEntryOverhead is the approximate bytes of an entry of EvictingCache other than its key and value,
+// i.e. the map entry, the list element and the item on a 64-bit platform. It is counted in the cache size
+// since it is not negligible compared to the size of a small trie node.
+const evictingCacheEntryOverhead = 128
+
+// LocalCacheEvictionPolicy is the eviction policy of the local cache of a hybrid cache.
+//
+// The local cache and redis evict items independently. If they evict different items, a read can miss in
+// the local cache and hit in redis, and the promoted item can evict a hot item from the local cache.
+// Aligning the policy with the maxmemory-policy of redis (allkeys-lru or allkeys-lfu) keeps both tiers
+// holding similar items. Since trie nodes are immutable and content-addressed, LFU keeps the hottest
+// nodes local, e.g. the nodes near the state root.
+type LocalCacheEvictionPolicy string
+
+const (
+	// Available eviction policies of the local cache
+	LocalCacheEvictionDefault LocalCacheEvictionPolicy = ""    // fastcache, which evicts the oldest written items
+	LocalCacheEvictionLRU     LocalCacheEvictionPolicy = "lru" // evicts the least recently used items
+	LocalCacheEvictionLFU     LocalCacheEvictionPolicy = "lfu" // evicts the least frequently used items
+)
+
+func (policy LocalCacheEvictionPolicy) ToValid() LocalCacheEvictionPolicy {
+	validPolicies := []LocalCacheEvictionPolicy{LocalCacheEvictionDefault, LocalCacheEvictionLRU, LocalCacheEvictionLFU}
+	for _, validPolicy := range validPolicies {
+		if strings.ToLower(string(policy)) == string(validPolicy) {
+			return validPolicy
+		}
+	}
+	logger.Warn("Invalid local cache eviction policy; use the default", "inputPolicy", policy, "validPolicies", validPolicies)
+	return LocalCacheEvictionDefault
+}
+
+// newLocalCache creates a local cache of a hybrid cache with the eviction policy of the given config.
+// It returns nil if the cache size is zero.
+func newLocalCache(config *TrieNodeCacheConfig) TrieNodeCache {
+	switch config.LocalCacheEvictionPolicy {
+	case LocalCacheEvictionLRU, LocalCacheEvictionLFU:
+		return newEvictingCache(config)
+	default:
+		return newFastCache(config)
+	}
+}
+
+// EvictingCache is an in-memory trie node cache evicting items by LRU or LFU policy.
+// Unlike FastCache, its items are not saved to a file. Like FastCache, it copies a value on both of set and get,
+// so that a caller reusing its buffer does not corrupt cached items.
+//
+// The cache size counts evictingCacheEntryOverhead for every entry besides its key and value.
+type EvictingCache struct {
+	policy   LocalCacheEvictionPolicy
+	maxBytes int
+	bytes    int
+
+	items map[string]*list.Element
+
+	// LRU: a list ordered from the most recently used
+	recency *list.List
+
+	// LFU: lists of items by access frequency, each ordered from the most recently used
+	frequencies map[uint64]*list.List
+	minFreq     uint64
+
+	hits, misses uint64
+	lock         sync.Mutex
+}
+
+type evictingCacheItem struct {
+	key   string
+	value []byte
+	freq  uint64 // used by LFU only
+}
+
+// EvictingCacheStats is the statistics of an EvictingCache.
+type EvictingCacheStats struct {
+	Entries int
+	Bytes   int // including evictingCacheEntryOverhead of every entry
+	Hits    uint64
+	Misses  uint64
+}
+
+// newEvictingCache creates an EvictingCache with given cache size.
+// It returns nil if the cache size is zero.
+func newEvictingCache(config *TrieNodeCacheConfig) TrieNodeCache {
+	if config.LocalCacheSizeMiB == AutoScaling {
+		config.LocalCacheSizeMiB = getTrieNodeCacheSizeMiB()
+	}
+
+	if config.LocalCacheSizeMiB <= 0 {
+		return nil
+	}
+
+	logger.Info("Initialized local trie node cache", "policy", config.LocalCacheEvictionPolicy, "MaxMiB", config.LocalCacheSizeMiB)
+	return newEvictingCacheWithSize(config.LocalCacheEvictionPolicy, config.LocalCacheSizeMiB*int(units.MiB))
+}
+
+func newEvictingCacheWithSize(policy LocalCacheEvictionPolicy, maxBytes int) *EvictingCache {
+	return &EvictingCache{
+		policy:      policy,
+		maxBytes:    maxBytes,
+		items:       make(map[string]*list.Element),
+		recency:     list.New(),
+		frequencies: make(map[uint64]*list.List),
+	}
+}
+
+func (cache *EvictingCache) Get(k []byte) []byte {
+	ret, _ := cache.Has(k)
+	return ret
+}
+
+func (cache *EvictingCache) Has(k []byte) ([]byte, bool) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	elem, ok := cache.items[string(k)]
+	if !ok {
+		cache.misses++
+		return nil, false
+	}
+	cache.hits++
+	cache.touch(elem)
+	return common.CopyBytes(elem.Value.(*evictingCacheItem).value), true
+}
+
+func (cache *EvictingCache) Set(k, v []byte) {
+	size := len(k) + len(v) + evictingCacheEntryOverhead
+	if size > cache.maxBytes {
+		return
+	}
+	v = common.CopyBytes(v)
+
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	if elem, ok := cache.items[string(k)]; ok {
+		item := elem.Value.(*evictingCacheItem)
+		cache.bytes += len(v) - len(item.value)
+		item.value = v
+		cache.touch(elem)
+		for cache.bytes > cache.maxBytes {
+			cache.evict()
+		}
+		return
+	}
+
+	// make room before inserting, not to evict the new item
+	for cache.bytes+size > cache.maxBytes {
+		cache.evict()
+	}
+	item := &evictingCacheItem{key: string(k), value: v}
+	cache.bytes += size
+	cache.items[item.key] = cache.insert(item)
+}
+
+func (cache *EvictingCache) Delete(k []byte) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	elem, ok := cache.items[string(k)]
+	if !ok {
+		return
+	}
+	cache.remove(elem)
+}
+
+// insert adds a new item to the eviction order.
+func (cache *EvictingCache) insert(item *evictingCacheItem) *list.Element {
+	if cache.policy != LocalCacheEvictionLFU {
+		return cache.recency.PushFront(item)
+	}
+	item.freq = 1
+	cache.minFreq = 1
+	return cache.frequencyList(1).PushFront(item)
+}
+
+// touch updates the eviction order of an accessed item.
+func (cache *EvictingCache) touch(elem *list.Element) {
+	if cache.policy != LocalCacheEvictionLFU {
+		cache.recency.MoveToFront(elem)
+		return
+	}
+
+	item := elem.Value.(*evictingCacheItem)
+	next := cache.frequencyList(item.freq + 1) // created first to keep minFreq valid
+	cache.removeFromFrequencyList(elem)
+	item.freq++
+	cache.items[item.key] = next.PushFront(item)
+}
+
+// evict removes an item by the eviction policy.
+func (cache *EvictingCache) evict() {
+	if cache.policy != LocalCacheEvictionLFU {
+		cache.remove(cache.recency.Back())
+	} else {
+		cache.remove(cache.frequencies[cache.minFreq].Back())
+	}
+}
+
+// remove removes an item from the cache.
+func (cache *EvictingCache) remove(elem *list.Element) {
+	if cache.policy != LocalCacheEvictionLFU {
+		cache.recency.Remove(elem)
+	} else {
+		cache.removeFromFrequencyList(elem)
+	}
+
+	item := elem.Value.(*evictingCacheItem)
+	delete(cache.items, item.key)
+	cache.bytes -= len(item.key) + len(item.value) + evictingCacheEntryOverhead
+}
+
+func (cache *EvictingCache) frequencyList(freq uint64) *list.List {
+	l, ok := cache.frequencies[freq]
+	if !ok {
+		l = list.New()
+		cache.frequencies[freq] = l
+	}
+	return l
+}
+
+// removeFromFrequencyList removes an item from the list of its frequency, keeping minFreq valid
+// as long as any item is left.
+func (cache *EvictingCache) removeFromFrequencyList(elem *list.Element) {
+	item := elem.Value.(*evictingCacheItem)
+	l := cache.frequencies[item.freq]
+	l.Remove(elem)
+	if l.Len() > 0 {
+		return
+	}
+
+	delete(cache.frequencies, item.freq)
+	if cache.minFreq != item.freq {
+		return
+	}
+	cache.minFreq = 0
+	for freq := range cache.frequencies {
+		if cache.minFreq == 0 || freq < cache.minFreq {
+			cache.minFreq = freq
+		}
+	}
+}
+
+func (cache *EvictingCache) UpdateStats() interface{} {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	return EvictingCacheStats{
+		Entries: len(cache.items),
+		Bytes:   cache.bytes,
+		Hits:    cache.hits,
+		Misses:  cache.misses,
+	}
+}
+
+func (cache *EvictingCache) SaveToFile(filePath string, concurrency int) error {
+	return nil
+}
+
+func (cache *EvictingCache) Close() error {
+	return nil
+}
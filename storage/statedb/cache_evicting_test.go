@@ -0,0 +1,187 @@
+// Copyright 2021 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package statedb
+
+import (
+	"encoding/binary"
+	"math/rand"
+	"testing"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/stretchr/testify/assert"
+)
+
+// size of an entry of an 8-byte key and an 8-byte value
+const evictingTestEntrySize = 16 + evictingCacheEntryOverhead
+
+func evictingTestKey(i uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, i)
+	return key
+}
+
+// TestEvictingCache_LRU tests whether an LRU cache evicts the least recently used item.
+func TestEvictingCache_LRU(t *testing.T) {
+	// room for 3 items
+	cache := newEvictingCacheWithSize(LocalCacheEvictionLRU, 3*evictingTestEntrySize)
+	for i := uint64(0); i < 3; i++ {
+		cache.Set(evictingTestKey(i), evictingTestKey(i))
+	}
+
+	// 0 becomes the most recently used, so 1 is evicted
+	assert.NotNil(t, cache.Get(evictingTestKey(0)))
+	cache.Set(evictingTestKey(3), evictingTestKey(3))
+
+	_, ok := cache.Has(evictingTestKey(1))
+	assert.False(t, ok)
+	for _, i := range []uint64{0, 2, 3} {
+		assert.Equal(t, evictingTestKey(i), cache.Get(evictingTestKey(i)))
+	}
+	assert.Equal(t, 3*evictingTestEntrySize, cache.UpdateStats().(EvictingCacheStats).Bytes)
+}
+
+// TestEvictingCache_LFU tests whether an LFU cache evicts the least frequently used item,
+// and the least recently used one among the items of the same frequency.
+func TestEvictingCache_LFU(t *testing.T) {
+	// room for 3 items
+	cache := newEvictingCacheWithSize(LocalCacheEvictionLFU, 3*evictingTestEntrySize)
+	for i := uint64(0); i < 3; i++ {
+		cache.Set(evictingTestKey(i), evictingTestKey(i))
+	}
+
+	// 0 and 1 are accessed more than 2, even though 2 is the most recently used
+	for n := 0; n < 3; n++ {
+		cache.Get(evictingTestKey(0))
+		cache.Get(evictingTestKey(1))
+	}
+	cache.Get(evictingTestKey(2))
+
+	cache.Set(evictingTestKey(3), evictingTestKey(3))
+	_, ok := cache.Has(evictingTestKey(2))
+	assert.False(t, ok)
+
+	// 3 is the only item of the least frequency, so it is evicted by a new item
+	cache.Set(evictingTestKey(4), evictingTestKey(4))
+	_, ok = cache.Has(evictingTestKey(3))
+	assert.False(t, ok)
+
+	for _, i := range []uint64{0, 1, 4} {
+		assert.Equal(t, evictingTestKey(i), cache.Get(evictingTestKey(i)))
+	}
+	stats := cache.UpdateStats().(EvictingCacheStats)
+	assert.Equal(t, 3, stats.Entries)
+	assert.Equal(t, 3*evictingTestEntrySize, stats.Bytes)
+}
+
+// TestEvictingCache_TooBigItem tests whether an item bigger than the cache size is not set.
+func TestEvictingCache_TooBigItem(t *testing.T) {
+	for _, policy := range []LocalCacheEvictionPolicy{LocalCacheEvictionLRU, LocalCacheEvictionLFU} {
+		cache := newEvictingCacheWithSize(policy, evictingTestEntrySize)
+		cache.Set(evictingTestKey(0), evictingTestKey(0))
+		cache.Set(evictingTestKey(1), make([]byte, 16))
+
+		assert.Equal(t, evictingTestKey(0), cache.Get(evictingTestKey(0)))
+		assert.Nil(t, cache.Get(evictingTestKey(1)))
+	}
+}
+
+// TestEvictingCache_Delete tests whether a deleted item is not found and its room is reused.
+func TestEvictingCache_Delete(t *testing.T) {
+	for _, policy := range []LocalCacheEvictionPolicy{LocalCacheEvictionLRU, LocalCacheEvictionLFU} {
+		// room for 2 items
+		cache := newEvictingCacheWithSize(policy, 2*evictingTestEntrySize)
+		cache.Set(evictingTestKey(0), evictingTestKey(0))
+		cache.Set(evictingTestKey(1), evictingTestKey(1))
+		cache.Get(evictingTestKey(1))
+
+		cache.Delete(evictingTestKey(1))
+		cache.Delete(evictingTestKey(2)) // not found
+		assert.Nil(t, cache.Get(evictingTestKey(1)))
+		_, ok := cache.Has(evictingTestKey(1))
+		assert.False(t, ok)
+		assert.Equal(t, evictingTestEntrySize, cache.UpdateStats().(EvictingCacheStats).Bytes)
+
+		// 0 is not evicted by a new item
+		cache.Set(evictingTestKey(2), evictingTestKey(2))
+		assert.Equal(t, evictingTestKey(0), cache.Get(evictingTestKey(0)))
+		assert.Equal(t, evictingTestKey(2), cache.Get(evictingTestKey(2)))
+	}
+}
+
+// TestEvictingCache_Copy tests whether a cached item is not changed by the buffers given to or returned by the cache.
+func TestEvictingCache_Copy(t *testing.T) {
+	for _, policy := range []LocalCacheEvictionPolicy{LocalCacheEvictionLRU, LocalCacheEvictionLFU} {
+		cache := newEvictingCacheWithSize(policy, evictingTestEntrySize)
+		key, value := evictingTestKey(0), evictingTestKey(0)
+		cache.Set(key, value)
+
+		// the buffer given to Set is reused
+		value[0]++
+		assert.Equal(t, evictingTestKey(0), cache.Get(key))
+
+		// the buffers returned by Get and Has are mutated
+		cache.Get(key)[0]++
+		ret, _ := cache.Has(key)
+		ret[0]++
+		assert.Equal(t, evictingTestKey(0), cache.Get(key))
+	}
+}
+
+func TestLocalCacheEvictionPolicy_ToValid(t *testing.T) {
+	assert.Equal(t, LocalCacheEvictionLFU, LocalCacheEvictionPolicy("LFU").ToValid())
+	assert.Equal(t, LocalCacheEvictionLRU, LocalCacheEvictionPolicy("lru").ToValid())
+	assert.Equal(t, LocalCacheEvictionDefault, LocalCacheEvictionPolicy("random").ToValid())
+}
+
+// BenchmarkLocalCacheHitRate compares hit rates of the local caches under a skewed (Zipf) access pattern,
+// where each miss is followed by setting the item as a hybrid cache does on a read from redis.
+func BenchmarkLocalCacheHitRate(b *testing.B) {
+	const (
+		numKeys   = 1 << 20
+		valueSize = 512
+		cacheSize = 32 * 1024 * 1024 // about 1/16 of the items
+	)
+	value := make([]byte, valueSize)
+
+	benchmarks := []struct {
+		name     string
+		newCache func() TrieNodeCache
+	}{
+		{"fastcache", func() TrieNodeCache { return &FastCache{fast: fastcache.New(cacheSize)} }},
+		{"lru", func() TrieNodeCache { return newEvictingCacheWithSize(LocalCacheEvictionLRU, cacheSize) }},
+		{"lfu", func() TrieNodeCache { return newEvictingCacheWithSize(LocalCacheEvictionLFU, cacheSize) }},
+	}
+
+	for _, bm := range benchmarks {
+		b.Run(bm.name, func(b *testing.B) {
+			cache := bm.newCache()
+			zipf := rand.NewZipf(rand.New(rand.NewSource(1)), 1.1, 1, numKeys-1)
+
+			hits := 0
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				key := evictingTestKey(zipf.Uint64())
+				if _, ok := cache.Has(key); ok {
+					hits++
+				} else {
+					cache.Set(key, value)
+				}
+			}
+			b.ReportMetric(float64(hits)/float64(b.N)*100, "hit%")
+		})
+	}
+}
@@ -16,6 +16,14 @@
 
 package statedb
 
+import (
+	"testing"
+
+	"github.com/VictoriaMetrics/fastcache"
+	"github.com/klaytn/klaytn/common"
+	"github.com/stretchr/testify/assert"
+)
+
 func getTestFastCacheConfig() *TrieNodeCacheConfig {
 	return &TrieNodeCacheConfig{
 		CacheType:           CacheTypeLocal,
@@ -24,3 +32,27 @@ func getTestFastCacheConfig() *TrieNodeCacheConfig {
 		FastCacheSavePeriod: 0,
 	}
 }
+
+func TestFastCache_Delete(t *testing.T) {
+	cache := newFastCache(&TrieNodeCacheConfig{CacheType: CacheTypeLocal, LocalCacheSizeMiB: 100})
+	key, value := common.MakeRandomBytes(32), common.MakeRandomBytes(128)
+
+	cache.Set(key, value)
+	assert.Equal(t, value, cache.Get(key))
+
+	cache.Delete(key)
+	assert.Nil(t, cache.Get(key))
+	_, ok := cache.Has(key)
+	assert.False(t, ok)
+}
+
+func TestFastCache_UpdateStats(t *testing.T) {
+	cache := newFastCache(&TrieNodeCacheConfig{CacheType: CacheTypeLocal, LocalCacheSizeMiB: 100})
+	for i := 0; i < 10; i++ {
+		cache.Set(common.MakeRandomBytes(32), common.MakeRandomBytes(128))
+	}
+
+	stats := cache.UpdateStats().(fastcache.Stats)
+	assert.Equal(t, uint64(10), stats.EntriesCount)
+	assert.True(t, stats.BytesSize > 0)
+}
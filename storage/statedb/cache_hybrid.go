@@ -16,7 +16,19 @@
 
 package statedb
 
-import "github.com/go-redis/redis/v7"
+import (
+	"sync/atomic"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/klaytn/klaytn/common"
+)
+
+const (
+	// Number of local hits per check of an item in redis for re-population.
+	hybridRepopulateSampleInterval = 100
+	// Number of items waiting to be checked for re-population. Sampled items are dropped if it is full.
+	hybridRepopulateQueueSize = 1000
+)
 
 func newHybridCache(config *TrieNodeCacheConfig) (TrieNodeCache, error) {
 	redis, err := newRedisCache(config)
@@ -24,18 +36,32 @@ func newHybridCache(config *TrieNodeCacheConfig) (TrieNodeCache, error) {
 		return nil, err
 	}
 
-	return &HybridCache{
-		local:  newFastCache(config),
+	cache := &HybridCache{
+		local:  newLocalCache(config),
 		remote: redis,
-	}, nil
+	}
+	if config.RedisRepopulateEnable {
+		cache.startRepopulation()
+	}
+	return cache, nil
 }
 
 // HybridCache integrates two kinds of caches: local, remote.
 // Local cache uses memory of the local machine and remote cache uses memory of the remote machine.
-// When it sets data to both caches, only remote cache is set asynchronously
+// It reads through local cache, so an item found in remote cache is set to local cache
+// and is read without a round trip to remote cache afterwards.
+// When it sets data to both caches, only remote cache is set asynchronously.
+// If re-population is enabled, an item found in local cache is checked in remote cache on one of
+// hybridRepopulateSampleInterval local hits, and written to remote cache asynchronously if remote cache
+// has evicted it, to recover remote cache from evictions of hot items.
 type HybridCache struct {
 	local  TrieNodeCache
 	remote *RedisCache
+
+	// re-population of remote cache; disabled if repopulateCh is nil
+	repopulateCh   chan setItem
+	repopulateQuit chan struct{}
+	localHits      uint64 // number of local hits, sampling the items to be checked
 }
 
 func (cache *HybridCache) Local() TrieNodeCache {
@@ -55,6 +81,7 @@ func (cache *HybridCache) Set(k, v []byte) {
 func (cache *HybridCache) Get(k []byte) []byte {
 	ret := cache.local.Get(k)
 	if ret != nil {
+		cache.repopulate(k, ret)
 		return ret
 	}
 	ret = cache.remote.Get(k)
@@ -67,17 +94,76 @@ func (cache *HybridCache) Get(k []byte) []byte {
 func (cache *HybridCache) Has(k []byte) ([]byte, bool) {
 	ret, has := cache.local.Has(k)
 	if has {
+		cache.repopulate(k, ret)
 		return ret, has
 	}
-	return cache.remote.Has(k)
+	ret, has = cache.remote.Has(k)
+	if has {
+		cache.local.Set(k, ret)
+	}
+	return ret, has
 }
 
-func (cache *HybridCache) UpdateStats() interface{} {
-	type stats struct {
-		local  interface{}
-		remote interface{}
+// Delete removes an item from both of local and remote caches.
+func (cache *HybridCache) Delete(k []byte) {
+	cache.local.Delete(k)
+	cache.remote.Delete(k)
+}
+
+// startRepopulation starts a worker re-populating remote cache with the items sampled by repopulate.
+func (cache *HybridCache) startRepopulation() {
+	cache.repopulateCh = make(chan setItem, hybridRepopulateQueueSize)
+	cache.repopulateQuit = make(chan struct{})
+	go cache.runRepopulateWorker()
+}
+
+// stopRepopulation stops the worker started by startRepopulation, if any.
+func (cache *HybridCache) stopRepopulation() {
+	if cache.repopulateQuit != nil {
+		close(cache.repopulateQuit)
 	}
-	return stats{cache.local.UpdateStats(), cache.remote.UpdateStats()}
+}
+
+// repopulate samples an item found in local cache to be checked in remote cache, if re-population is enabled.
+// It does not block the read; the item is dropped if too many items are waiting to be checked.
+func (cache *HybridCache) repopulate(k, v []byte) {
+	if cache.repopulateCh == nil || atomic.AddUint64(&cache.localHits, 1)%hybridRepopulateSampleInterval != 0 {
+		return
+	}
+	select {
+	case cache.repopulateCh <- setItem{key: common.CopyBytes(k), value: v}:
+	default:
+	}
+}
+
+// runRepopulateWorker writes the sampled items to remote cache asynchronously, if remote cache does not have them.
+func (cache *HybridCache) runRepopulateWorker() {
+	for {
+		select {
+		case item := <-cache.repopulateCh:
+			exists, err := cache.remote.exists(item.key)
+			if err != nil {
+				logger.Debug("cannot check an item in redis cache to re-populate it", "err", err)
+				continue
+			}
+			if !exists {
+				redisRepopulateCounter.Inc(1)
+				cache.remote.SetAsync(item.key, item.value)
+			}
+		case <-cache.repopulateQuit:
+			return
+		}
+	}
+}
+
+// HybridCacheStats is the statistics of a HybridCache.
+type HybridCacheStats struct {
+	Local  interface{} // statistics of the local cache, e.g. fastcache.Stats or EvictingCacheStats
+	Remote interface{} // RedisCacheStats
+}
+
+func (cache *HybridCache) UpdateStats() interface{} {
+	return HybridCacheStats{cache.local.UpdateStats(), cache.remote.UpdateStats()}
 }
 
 func (cache *HybridCache) SaveToFile(filePath string, concurrency int) error {
@@ -102,6 +188,7 @@ func (cache *HybridCache) UnsubscribeBlock() error {
 }
 
 func (cache *HybridCache) Close() error {
+	cache.stopRepopulation()
 	err := cache.local.Close()
 	if err != nil {
 		return err
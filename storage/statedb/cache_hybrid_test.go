@@ -2,6 +2,7 @@ package statedb
 
 import (
 	"bytes"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -160,3 +161,110 @@ func TestHybridCache_Has(t *testing.T) {
 		assert.Equal(t, returnedExist, true)
 	}
 }
+
+// TestHybridCache_Repopulate tests whether a hybrid cache re-populates the remote cache
+// with an item found in the local cache but evicted from the remote cache, on a sampled local hit.
+func TestHybridCache_Repopulate(t *testing.T) {
+	remoteCache, counter, closeRemote := newTestRedisMemoryCache(t)
+	defer closeRemote()
+
+	hybrid := &HybridCache{local: newFastCache(getTestHybridConfig()), remote: remoteCache}
+	hybrid.startRepopulation()
+	defer hybrid.stopRepopulation()
+
+	key, value := randBytes(32), randBytes(500)
+	hybrid.Set(key, value)
+	time.Sleep(sleepDurationForAsyncBehavior)
+	assert.Equal(t, value, remoteCache.Get(key))
+
+	// The remote cache evicts the item
+	remoteCache.Delete(key)
+	assert.Nil(t, remoteCache.Get(key))
+
+	// Local hits do not query the remote cache until one of them is sampled
+	roundTrips := atomic.LoadInt64(&counter.roundTrips)
+	for i := 0; i < hybridRepopulateSampleInterval-1; i++ {
+		assert.Equal(t, value, hybrid.Get(key))
+	}
+	time.Sleep(sleepDurationForAsyncBehavior)
+	assert.Equal(t, roundTrips, atomic.LoadInt64(&counter.roundTrips))
+
+	// A sampled local hit re-populates the remote cache
+	assert.Equal(t, value, hybrid.Get(key))
+	time.Sleep(sleepDurationForAsyncBehavior)
+	assert.Equal(t, value, remoteCache.Get(key))
+
+	// Without re-population, the remote cache is not touched by local hits
+	hybrid = &HybridCache{local: newFastCache(getTestHybridConfig()), remote: remoteCache}
+	key, value = randBytes(32), randBytes(500)
+	hybrid.Set(key, value)
+	time.Sleep(sleepDurationForAsyncBehavior)
+	remoteCache.Delete(key)
+
+	for i := 0; i < hybridRepopulateSampleInterval; i++ {
+		assert.Equal(t, value, hybrid.Get(key))
+	}
+	time.Sleep(sleepDurationForAsyncBehavior)
+	assert.Nil(t, remoteCache.Get(key))
+}
+
+// TestHybridCache_ReadThrough tests whether a hybrid cache sets an item found in the remote cache
+// to the local cache, so the item is read without a round trip to the remote cache afterwards.
+func TestHybridCache_ReadThrough(t *testing.T) {
+	remoteCache, counter, closeRemote := newTestRedisMemoryCache(t)
+	defer closeRemote()
+
+	localCache := newFastCache(getTestHybridConfig())
+	hybrid := &HybridCache{local: localCache, remote: remoteCache}
+
+	for _, read := range []func(k []byte) []byte{
+		hybrid.Get,
+		func(k []byte) []byte { val, _ := hybrid.Has(k); return val },
+	} {
+		key, value := randBytes(32), randBytes(500)
+		if err := remoteCache.SetSync(key, value); err != nil {
+			t.Fatal(err)
+		}
+		roundTrips := atomic.LoadInt64(&counter.roundTrips)
+
+		for i := 0; i < 10; i++ {
+			assert.Equal(t, value, read(key))
+		}
+		assert.Equal(t, value, localCache.Get(key))
+		assert.Equal(t, roundTrips+1, atomic.LoadInt64(&counter.roundTrips))
+	}
+}
+
+// BenchmarkHybridCache_Get compares the round trips to redis of repeated reads of the same key
+// from a redis cache and a hybrid cache.
+func BenchmarkHybridCache_Get(b *testing.B) {
+	key, value := randBytes(32), randBytes(500)
+
+	newCaches := []struct {
+		name     string
+		newCache func(remote *RedisCache) TrieNodeCache
+	}{
+		{"redis", func(remote *RedisCache) TrieNodeCache { return remote }},
+		{"hybrid", func(remote *RedisCache) TrieNodeCache {
+			return &HybridCache{local: newFastCache(getTestHybridConfig()), remote: remote}
+		}},
+	}
+
+	for _, bm := range newCaches {
+		b.Run(bm.name, func(b *testing.B) {
+			remote, counter, closeRemote := newTestRedisMemoryCache(b)
+			defer closeRemote()
+			if err := remote.SetSync(key, value); err != nil {
+				b.Fatal(err)
+			}
+			cache := bm.newCache(remote)
+			atomic.StoreInt64(&counter.roundTrips, 0)
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				cache.Get(key)
+			}
+			b.ReportMetric(float64(atomic.LoadInt64(&counter.roundTrips))/float64(b.N), "roundtrips/op")
+		})
+	}
+}
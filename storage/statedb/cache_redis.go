@@ -17,12 +17,24 @@
 package statedb
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v7"
+	"github.com/golang/snappy"
 	"github.com/klaytn/klaytn/common/hexutil"
+	metricutils "github.com/klaytn/klaytn/metrics/utils"
+	"github.com/rcrowley/go-metrics"
 )
 
 const (
@@ -31,119 +43,758 @@ const (
 	// Channel size for block subscription. If average block size is 10KB, 10MB could be used.
 	redisSubscriptionChannelSize  = 1000
 	redisSubscriptionChannelBlock = "latestBlock"
+	// Minimum interval of warnings about items dropped because the setItem channel is full.
+	redisDroppedItemsWarnInterval = 10 * time.Second
+	// Number of keys scanned at once by FlushPrefix.
+	redisFlushScanCount = 1000
+	// Maximum number of items written in a round trip by a worker writing items asynchronously.
+	redisSetBatchSize = 100
+	// Retries of an item failed to be written asynchronously. The backoff doubles on every retry up to the maximum.
+	redisSetRetries         = 2
+	redisSetRetryBackoff    = 50 * time.Millisecond
+	redisSetRetryMaxBackoff = time.Second
+	// Minimum interval of querying the statistics of redis by UpdateStats, which is called on every block.
+	redisStatsInterval = 10 * time.Second
+	// Minimum interval of counting the keys of a key prefix by scanning redis, and the pause between the scans.
+	redisPrefixKeysInterval  = 10 * time.Minute
+	redisPrefixKeysScanPause = 10 * time.Millisecond
+
+	// Codecs of an encoded value. An encoded value is prefixed with redisValueMagic and a codec.
+	redisValueCodecNone   byte = 0
+	redisValueCodecSnappy byte = 1
+)
+
+// RedisCompressionType is the compression of the values written to redis.
+// Values are decoded regardless of the type, so that it can be changed on a running redis.
+type RedisCompressionType string
+
+const (
+	// Available compressions of redis values
+	RedisCompressionNone   RedisCompressionType = ""       // values are written as they are
+	RedisCompressionSnappy RedisCompressionType = "snappy" // values are compressed by snappy
 )
 
+func (compression RedisCompressionType) ToValid() RedisCompressionType {
+	validCompressions := []RedisCompressionType{RedisCompressionNone, RedisCompressionSnappy}
+	for _, validCompression := range validCompressions {
+		if strings.ToLower(string(compression)) == string(validCompression) {
+			return validCompression
+		}
+	}
+	logger.Warn("Invalid redis compression; values are not compressed", "inputCompression", compression,
+		"validCompressions", validCompressions)
+	return RedisCompressionNone
+}
+
 var (
 	redisCacheDialTimeout = time.Duration(900 * time.Millisecond)
 	redisCacheTimeout     = time.Duration(900 * time.Millisecond)
 
-	errRedisNoEndpoint = errors.New("redis endpoint not specified")
+	errRedisNoEndpoint     = errors.New("redis endpoint not specified")
+	errRedisTLSKeyPair     = errors.New("both redis TLS certificate and key files should be specified")
+	errRedisTLSCA          = errors.New("no valid certificate in redis TLS CA file")
+	errRedisAuth           = errors.New("failed to authenticate to redis")
+	errRedisSetItemDropped = errors.New("redis setItem channel is full; item dropped")
+	errRedisChannelSize    = errors.New("redis setItem channel size should be positive")
+
+	errRedisSentinelWithCluster = errors.New("redis sentinel and cluster modes cannot be enabled together")
+	errRedisNoMasterName        = errors.New("redis master name not specified for sentinel mode")
+	errRedisPoolSize            = errors.New("redis pool size and minimum idle connections should not be negative")
+
+	// redisValueMagic prefixes an encoded value. Values without it are stored as they are.
+	// Trie nodes are rlp lists, so a raw value never starts with the magic.
+	redisValueMagic = []byte{0x00, 'k', 'v'}
+
+	errRedisValueTruncated    = errors.New("truncated redis value")
+	errRedisValueUnknownCodec = errors.New("unknown codec of redis value")
+	errRedisValueDecompress   = errors.New("cannot decompress redis value")
+
+	redisCorruptedValueCounter = metrics.NewRegisteredCounter("trie/cache/redis/corrupted", nil)
+	redisDroppedItemsCounter   = metrics.NewRegisteredCounter("trie/cache/redis/setitem/dropped", nil)
+)
+
+const (
+	// prefix of per-shard metrics. Metrics are registered as "<prefix>/<shard address>/{ops,errors}".
+	redisShardMetricPrefix = "trie/cache/redis/shard/"
+	// shard label used if the shard of a key cannot be identified
+	redisUnknownShard = "unknown"
 )
 
 type RedisCache struct {
+	// 64-bit fields accessed atomically are placed first for the alignment on 32-bit platforms
+	droppedItems     uint64 // number of items dropped because setItemCh is full or their writes failed
+	lastDroppedWarns int64  // unix nano time of the last warning about dropped items
+
 	client    redis.UniversalClient
 	setItemCh chan setItem
 	pubSub    *redis.PubSub
+
+	breaker *redisCircuitBreaker // skips requests while redis is unavailable; nil if disabled
+
+	compression RedisCompressionType // compression of the values written to redis
+	keyPrefix   string               // prepended to every key and channel name in redis
+
+	// entryTTL is the expiration of the items written to redis; no expiration if zero.
+	// An expiration is safe since a trie node is addressed by the hash of its content and never changes.
+	// An expired node is just a miss, and it is read from the database again.
+	entryTTL time.Duration
+
+	// retries of an item failed to be written asynchronously, distinct from the retries of the client
+	setRetries      int           // no retry if zero
+	setRetryBackoff time.Duration // backoff before the first retry, doubled on every retry
+
+	// statistics of redis most recently queried by UpdateStats
+	statsLock    sync.Mutex
+	stats        RedisCacheStats
+	statsUpdated time.Time
+	keysCounting bool      // whether the keys of the key prefix are being counted in background
+	keysCounted  time.Time // when the keys of the key prefix were counted most recently
+}
+
+// RedisCacheStats is the statistics of a RedisCache.
+// If a key prefix is set, Keys is the number of the keys of the prefix, which is counted by scanning redis
+// in background at most once in redisPrefixKeysInterval. It is -1 until the keys are counted first.
+// UsedMemory is of the whole redis instance, including the items of the other key prefixes.
+type RedisCacheStats struct {
+	Keys         int64  // number of keys of the key prefix, or in redis if no key prefix is set
+	UsedMemory   int64  // bytes of memory used by the redis instance
+	PendingItems int    // number of items waiting to be written asynchronously
+	DroppedItems uint64 // number of items dropped by asynchronous writes. See DroppedItems
 }
 
 type setItem struct {
-	key   []byte
-	value []byte
+	key      []byte
+	value    []byte
+	callback func(err error) // optional; called with the result of the write
 }
 
-func newRedisClient(endpoints []string, isCluster bool) (redis.UniversalClient, error) {
+// newRedisTLSConfig returns a TLS config of the connections to the redis server.
+// It returns nil if TLS is not enabled, which means plaintext connections are used.
+// Giving a client certificate enables TLS even if RedisTLSEnable is false, since it is used for mutual TLS only.
+//
+// The server certificate is verified with the CA certificates of RedisTLSCAFile if it is given,
+// or the system CA certificates otherwise. RedisTLSSkipVerify disables the verification.
+func newRedisTLSConfig(config *TrieNodeCacheConfig) (*tls.Config, error) {
+	certFile, keyFile := config.RedisTLSCertFile, config.RedisTLSKeyFile
+	if !config.RedisTLSEnable && certFile == "" && keyFile == "" {
+		return nil, nil
+	}
+	if (certFile == "") != (keyFile == "") {
+		return nil, errRedisTLSKeyPair
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: config.RedisTLSSkipVerify,
+	}
+	if config.RedisTLSSkipVerify {
+		logger.Warn("The certificate of the redis server is not verified")
+	}
+
+	if config.RedisTLSCAFile != "" {
+		caPEM, err := ioutil.ReadFile(config.RedisTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read redis TLS CA certificate (ca: %s): %w", config.RedisTLSCAFile, err)
+		}
+		rootCAs := x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("%w (ca: %s)", errRedisTLSCA, config.RedisTLSCAFile)
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load redis TLS certificate (cert: %s, key: %s): %w", certFile, keyFile, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
+}
+
+// newRedisClient creates a redis client of the endpoints of the given config.
+// In sentinel mode, the endpoints are the addresses of the sentinels, which serve the address of the master.
+// The password is never logged.
+func newRedisClient(config *TrieNodeCacheConfig, tlsConfig *tls.Config) (redis.UniversalClient, error) {
+	endpoints := config.RedisEndpoints
 	if endpoints == nil {
 		return nil, errRedisNoEndpoint
 	}
 
+	if config.RedisPoolSize < 0 || config.RedisMinIdleConns < 0 {
+		return nil, errRedisPoolSize
+	}
+	dialTimeout, readTimeout, writeTimeout := redisTimeouts(config)
+
+	if config.RedisSentinelEnable {
+		if config.RedisClusterEnable {
+			return nil, errRedisSentinelWithCluster
+		}
+		if config.RedisMasterName == "" {
+			return nil, errRedisNoMasterName
+		}
+		// the client follows the master on failover, so it is transparent to the callers
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			// it takes Timeout * (MaxRetries+1) to raise an error
+			MasterName:    config.RedisMasterName,
+			SentinelAddrs: endpoints,
+			Password:      config.RedisPassword,
+			DialTimeout:   dialTimeout,
+			ReadTimeout:   readTimeout,
+			WriteTimeout:  writeTimeout,
+			MaxRetries:    2,
+			PoolSize:      config.RedisPoolSize,
+			MinIdleConns:  config.RedisMinIdleConns,
+			TLSConfig:     tlsConfig,
+		}), nil
+	}
+
 	// cluster-enabled redis can have more than one shard
-	if isCluster {
+	if config.RedisClusterEnable {
 		return redis.NewClusterClient(&redis.ClusterOptions{
 			// it takes Timeout * (MaxRetries+1) to raise an error
 			Addrs:        endpoints,
-			DialTimeout:  redisCacheDialTimeout,
-			ReadTimeout:  redisCacheTimeout,
-			WriteTimeout: redisCacheTimeout,
+			Username:     config.RedisUsername,
+			Password:     config.RedisPassword,
+			DialTimeout:  dialTimeout,
+			ReadTimeout:  readTimeout,
+			WriteTimeout: writeTimeout,
 			MaxRetries:   2,
+			PoolSize:     config.RedisPoolSize,
+			MinIdleConns: config.RedisMinIdleConns,
+			TLSConfig:    tlsConfig,
 		}), nil
 	}
 
 	return redis.NewClient(&redis.Options{
 		// it takes Timeout * (MaxRetries+1) to raise an error
 		Addr:         endpoints[0],
-		DialTimeout:  redisCacheDialTimeout,
-		ReadTimeout:  redisCacheTimeout,
-		WriteTimeout: redisCacheTimeout,
+		Username:     config.RedisUsername,
+		Password:     config.RedisPassword,
+		DialTimeout:  dialTimeout,
+		ReadTimeout:  readTimeout,
+		WriteTimeout: writeTimeout,
 		MaxRetries:   2,
+		PoolSize:     config.RedisPoolSize,
+		MinIdleConns: config.RedisMinIdleConns,
+		TLSConfig:    tlsConfig,
 	}), nil
 }
 
+// redisTimeouts returns the dial, read and write timeouts of the given config,
+// replacing zero values with the defaults.
+func redisTimeouts(config *TrieNodeCacheConfig) (dial, read, write time.Duration) {
+	dial, read, write = config.RedisDialTimeout, config.RedisReadTimeout, config.RedisWriteTimeout
+	if dial == 0 {
+		dial = redisCacheDialTimeout
+	}
+	if read == 0 {
+		read = redisCacheTimeout
+	}
+	if write == 0 {
+		write = redisCacheTimeout
+	}
+	return dial, read, write
+}
+
+// isRedisAuthError reports whether the given error is an authentication failure of redis.
+func isRedisAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, prefix := range []string{"WRONGPASS", "NOAUTH", "ERR invalid password", "ERR AUTH", "ERR Client sent AUTH"} {
+		if strings.HasPrefix(msg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // newRedisCache creates a redis cache containing redis client, setItemCh and pubSub.
 // It generates worker goroutines to process Set commands asynchronously.
 func newRedisCache(config *TrieNodeCacheConfig) (*RedisCache, error) {
-	cli, err := newRedisClient(config.RedisEndpoints, config.RedisClusterEnable)
+	channelSize := config.RedisSetItemChannelSize
+	if channelSize == 0 {
+		channelSize = redisSetItemChannelSize
+	}
+	if channelSize < 0 {
+		logger.Error("invalid redis setItem channel size", "size", channelSize)
+		return nil, errRedisChannelSize
+	}
+
+	tlsConfig, err := newRedisTLSConfig(config)
+	if err != nil {
+		logger.Error("failed to create a TLS config of redis client", "err", err)
+		return nil, err
+	}
+
+	cli, err := newRedisClient(config, tlsConfig)
 	if err != nil {
 		logger.Error("failed to create a redis client", "err", err, "endpoint", config.RedisEndpoints,
-			"isCluster", config.RedisClusterEnable)
+			"isCluster", config.RedisClusterEnable, "isSentinel", config.RedisSentinelEnable)
 		return nil, err
 	}
 
+	// Verify the credentials at once, not to fail every request later. Other errors are not fatal,
+	// since the cache keeps working while redis is unavailable.
+	if config.RedisPassword != "" {
+		if err := cli.Ping().Err(); isRedisAuthError(err) {
+			logger.Error("failed to authenticate to redis", "err", err, "endpoint", config.RedisEndpoints,
+				"username", config.RedisUsername)
+			cli.Close()
+			return nil, fmt.Errorf("%w: %v", errRedisAuth, err)
+		} else if err != nil {
+			logger.Warn("cannot verify the credentials of redis", "err", err, "endpoint", config.RedisEndpoints)
+		}
+	}
+
+	setRetries, setRetryBackoff := config.RedisSetRetries, config.RedisSetRetryBackoff
+	if setRetries == 0 {
+		setRetries = redisSetRetries
+	} else if setRetries < 0 {
+		setRetries = 0
+	}
+	if setRetryBackoff <= 0 {
+		setRetryBackoff = redisSetRetryBackoff
+	}
+
 	cache := &RedisCache{
 		client:    cli,
-		setItemCh: make(chan setItem, redisSetItemChannelSize),
+		setItemCh: make(chan setItem, channelSize),
 		pubSub:    cli.Subscribe(),
+
+		compression: config.RedisCompression,
+		keyPrefix:   config.RedisKeyPrefix,
+		entryTTL:    config.RedisEntryTTL,
+
+		setRetries:      setRetries,
+		setRetryBackoff: setRetryBackoff,
 	}
+	if cache.keyPrefix != "" {
+		cache.stats.Keys = -1 // not counted yet
+	}
+	cache.breaker = newRedisCircuitBreaker(redisBreakerFailureThreshold, redisBreakerHealthCheckInterval,
+		func() error { return cli.Ping().Err() })
 
 	workerNum := runtime.NumCPU()/2 + 1
 	for i := 0; i < workerNum; i++ {
-		go func() {
-			for item := range cache.setItemCh {
-				cache.Set(item.key, item.value)
-			}
-		}()
+		go cache.runSetWorker()
 	}
 
 	logger.Info("Initialized trie node cache with redis", "endpoint", config.RedisEndpoints,
-		"isCluster", config.RedisClusterEnable)
+		"isCluster", config.RedisClusterEnable, "isSentinel", config.RedisSentinelEnable,
+		"tls", tlsConfig != nil, "auth", config.RedisPassword != "", "setItemChannelSize", channelSize,
+		"compression", config.RedisCompression, "keyPrefix", config.RedisKeyPrefix, "entryTTL", config.RedisEntryTTL,
+		"setRetries", setRetries, "setRetryBackoff", setRetryBackoff)
 	return cache, nil
 }
 
+// encodeRedisValue returns a value to be written to redis, compressed by the given compression.
+// The value is written as it is if it is not compressed, as written before the compression was introduced.
+func encodeRedisValue(val []byte, compression RedisCompressionType) []byte {
+	switch compression {
+	case RedisCompressionSnappy:
+		encoded := append(append([]byte{}, redisValueMagic...), redisValueCodecSnappy)
+		return append(encoded, snappy.Encode(nil, val)...)
+	default:
+		return val
+	}
+}
+
+// redisKey returns the key in redis of the given key, which is hex-encoded and prefixed by the key prefix.
+func (cache *RedisCache) redisKey(k []byte) string {
+	return cache.keyPrefix + hexutil.Encode(k)
+}
+
+// escapeRedisPattern escapes the special characters of redis glob-style patterns in the given string.
+func escapeRedisPattern(s string) string {
+	var escaped strings.Builder
+	for _, c := range s {
+		if strings.ContainsRune(`*?[]\`, c) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(c)
+	}
+	return escaped.String()
+}
+
+// decodeRedisValue returns the original value of a value read from redis.
+// It returns an error if the value is encoded in an unknown way, truncated or cannot be decompressed.
+func decodeRedisValue(val []byte) ([]byte, error) {
+	if !bytes.HasPrefix(val, redisValueMagic) {
+		return val, nil
+	}
+	if len(val) <= len(redisValueMagic) {
+		return nil, errRedisValueTruncated
+	}
+	codec, payload := val[len(redisValueMagic)], val[len(redisValueMagic)+1:]
+	switch codec {
+	case redisValueCodecNone:
+		return payload, nil
+	case redisValueCodecSnappy:
+		decoded, err := snappy.Decode(nil, payload)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errRedisValueDecompress, err)
+		}
+		return decoded, nil
+	default:
+		return nil, errRedisValueUnknownCodec
+	}
+}
+
+// shardAddr returns the address of the redis node (shard) serving the given key.
+// In cluster mode, it is the address of the master node owning the slot of the key.
+// In single-node mode, the address of the single node is always returned.
+func (cache *RedisCache) shardAddr(key string) string {
+	switch client := cache.client.(type) {
+	case *redis.ClusterClient:
+		node, err := client.MasterForKey(key)
+		if err != nil {
+			return redisUnknownShard
+		}
+		return node.Options().Addr
+	case *redis.Client:
+		return client.Options().Addr
+	default:
+		return redisUnknownShard
+	}
+}
+
+// markShardOperation counts an operation on the given key and its failure, labeled by the shard address.
+// A miss (redis.Nil) is not counted as an error. It does nothing if metrics are disabled.
+func (cache *RedisCache) markShardOperation(key string, err error) {
+	if !metricutils.Enabled {
+		return
+	}
+	prefix := redisShardMetricPrefix + cache.shardAddr(key)
+	metrics.GetOrRegisterCounter(prefix+"/ops", nil).Inc(1)
+	if err != nil && err != redis.Nil {
+		metrics.GetOrRegisterCounter(prefix+"/errors", nil).Inc(1)
+	}
+}
+
+// Get returns the value of the given key. An undecodable value is treated as a miss,
+// since it would fail the verification of trie nodes anyway.
+// It returns nil immediately while the circuit breaker is open.
 func (cache *RedisCache) Get(k []byte) []byte {
-	val, err := cache.client.Get(hexutil.Encode(k)).Bytes()
+	return cache.get(k, redisGetMetrics)
+}
+
+// get returns the value of the given key, recording the operation in the given metrics.
+func (cache *RedisCache) get(k []byte, opMetrics redisOpMetrics) []byte {
+	if !cache.breaker.allow() {
+		return nil
+	}
+	key := cache.redisKey(k)
+	start := time.Now()
+	val, err := cache.client.Get(key).Bytes()
+	opMetrics.mark(start, err)
+	cache.markShardOperation(key, err)
+	cache.breaker.record(err)
 	if err != nil {
-		logger.Debug("cannot get an item from redis cache", "err", err, "key", hexutil.Encode(k))
+		logger.Debug("cannot get an item from redis cache", "err", err, "key", key)
+		if err == redis.Nil {
+			redisMissCounter.Inc(1)
+		}
+		return nil
+	}
+	if val, err = decodeRedisValue(val); err != nil {
+		redisCorruptedValueCounter.Inc(1)
+		redisMissCounter.Inc(1)
+		logger.Warn("cannot decode an item from redis cache; treat it as a miss", "err", err, "key", key)
 		return nil
 	}
+	redisHitCounter.Inc(1)
 	return val
 }
 
-// Set writes data synchronously.
-// To write data asynchronously, use SetAsync instead.
+// exists reports whether the given key is in redis, without reading its value.
+// It returns errRedisCircuitOpen while the circuit breaker is open.
+func (cache *RedisCache) exists(k []byte) (bool, error) {
+	if !cache.breaker.allow() {
+		return false, errRedisCircuitOpen
+	}
+	key := cache.redisKey(k)
+	n, err := cache.client.Exists(key).Result()
+	cache.markShardOperation(key, err)
+	cache.breaker.record(err)
+	return n > 0, err
+}
+
+// Set writes data synchronously. It does nothing while the circuit breaker is open.
+// To write data asynchronously, use SetAsync instead. To get the result of the write, use SetSync instead.
 func (cache *RedisCache) Set(k, v []byte) {
-	if err := cache.client.Set(hexutil.Encode(k), v, 0).Err(); err != nil {
-		logger.Error("failed to set an item on redis cache", "err", err, "key", hexutil.Encode(k))
+	cache.set(k, v)
+}
+
+// SetSync writes data synchronously like Set, and returns the error of the write.
+// Callers which must guarantee that an item is persisted in redis before proceeding should use it.
+// It returns errRedisCircuitOpen without writing while the circuit breaker is open.
+func (cache *RedisCache) SetSync(k, v []byte) error {
+	return cache.set(k, v)
+}
+
+func (cache *RedisCache) set(k, v []byte) error {
+	if !cache.breaker.allow() {
+		return errRedisCircuitOpen
+	}
+	key := cache.redisKey(k)
+	start := time.Now()
+	err := cache.client.Set(key, encodeRedisValue(v, cache.compression), cache.entryTTL).Err()
+	redisSetMetrics.mark(start, err)
+	cache.markShardOperation(key, err)
+	cache.breaker.record(err)
+	if err != nil {
+		logger.Error("failed to set an item on redis cache", "err", err, "key", key)
+	}
+	return err
+}
+
+// SetBatch writes the given items synchronously in a round trip using a pipeline.
+// It returns the first error of the writes, or errRedisCircuitOpen without writing while the circuit breaker is open.
+// The callbacks of the items are not called.
+func (cache *RedisCache) SetBatch(items []setItem) error {
+	for _, err := range cache.setBatch(items) {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setBatch writes the given items using a pipeline, and returns the errors of the writes in the order of the items.
+func (cache *RedisCache) setBatch(items []setItem) []error {
+	errs := make([]error, len(items))
+	if !cache.breaker.allow() {
+		for i := range errs {
+			errs[i] = errRedisCircuitOpen
+		}
+		return errs
+	}
+
+	pipe := cache.client.Pipeline()
+	cmds := make([]*redis.StatusCmd, len(items))
+	for i, item := range items {
+		cmds[i] = pipe.Set(cache.redisKey(item.key), encodeRedisValue(item.value, cache.compression), cache.entryTTL)
+	}
+	start := time.Now()
+	_, err := pipe.Exec()
+	redisSetBatchMetrics.mark(start, err)
+	cache.breaker.record(err)
+	if err != nil {
+		logger.Error("failed to set items on redis cache", "err", err, "numItems", len(items))
+	}
+
+	for i, cmd := range cmds {
+		errs[i] = cmd.Err()
+		cache.markShardOperation(cmd.Args()[1].(string), errs[i])
+	}
+	return errs
+}
+
+// runSetWorker writes the items of setItemCh until it is closed. The items already in the channel
+// are written together in a batch of up to redisSetBatchSize items, to save round trips.
+// A failed item is retried by retrySet, and it is counted as dropped if it is not written in the end.
+func (cache *RedisCache) runSetWorker() {
+	batch := make([]setItem, 0, redisSetBatchSize)
+	for item := range cache.setItemCh {
+		redisSetItemPendingGauge.Update(int64(len(cache.setItemCh)))
+		batch = append(batch[:0], item)
+	drain:
+		for len(batch) < redisSetBatchSize {
+			select {
+			case item, ok := <-cache.setItemCh:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, item)
+			default:
+				break drain
+			}
+		}
+
+		if len(batch) == 1 {
+			cache.finishSetItem(item, cache.retrySet(item, cache.set(item.key, item.value)))
+			continue
+		}
+		for i, err := range cache.setBatch(batch) {
+			cache.finishSetItem(batch[i], cache.retrySet(batch[i], err))
+		}
+	}
+}
+
+// retrySet retries writing an item failed with the given error up to setRetries times, and returns the error of
+// the last attempt. The backoff before a retry doubles from setRetryBackoff up to redisSetRetryMaxBackoff.
+// It gives up while the circuit breaker is open, since redis is regarded as unavailable rather than hiccuping.
+func (cache *RedisCache) retrySet(item setItem, err error) error {
+	backoff := cache.setRetryBackoff
+	for retry := 0; retry < cache.setRetries && err != nil && err != errRedisCircuitOpen; retry++ {
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > redisSetRetryMaxBackoff {
+			backoff = redisSetRetryMaxBackoff
+		}
+		redisSetRetryCounter.Inc(1)
+		err = cache.set(item.key, item.value)
+	}
+	return err
+}
+
+// finishSetItem counts an item not written as dropped, and calls its callback with the result.
+func (cache *RedisCache) finishSetItem(item setItem, err error) {
+	if err != nil {
+		cache.markDropped(err)
+	}
+	if item.callback != nil {
+		item.callback(err)
 	}
 }
 
 // SetAsync writes data asynchronously. Not all data is written if a setItemCh is full.
 // To write data synchronously, use Set instead.
 func (cache *RedisCache) SetAsync(k, v []byte) {
-	item := setItem{key: k, value: v}
+	cache.SetWithCallback(k, v, nil)
+}
+
+// SetWithCallback writes data asynchronously like SetAsync, and calls the given callback with the result.
+// The callback is called with nil after the item is written, with the error of the write if it failed,
+// or with errRedisSetItemDropped if the item is dropped because a setItemCh is full.
+// The callback is called on a worker goroutine, or on the caller's goroutine if the item is dropped.
+func (cache *RedisCache) SetWithCallback(k, v []byte, callback func(err error)) {
+	item := setItem{key: k, value: v, callback: callback}
 	select {
 	case cache.setItemCh <- item:
+		redisSetItemPendingGauge.Update(int64(len(cache.setItemCh)))
 	default:
-		logger.Warn("redis setItem channel is full")
+		cache.markDropped(errRedisSetItemDropped)
+		if callback != nil {
+			callback(errRedisSetItemDropped)
+		}
+	}
+}
+
+// markDropped counts an item dropped for the given reason, i.e. setItemCh is full or its write failed.
+// It warns at most once in redisDroppedItemsWarnInterval, not to flood the log while redis is slow.
+func (cache *RedisCache) markDropped(reason error) {
+	dropped := atomic.AddUint64(&cache.droppedItems, 1)
+	redisDroppedItemsCounter.Inc(1)
+
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&cache.lastDroppedWarns)
+	if now-last < int64(redisDroppedItemsWarnInterval) || !atomic.CompareAndSwapInt64(&cache.lastDroppedWarns, last, now) {
+		return
+	}
+	logger.Warn("items are dropped from redis cache", "reason", reason, "totalDropped", dropped,
+		"channelSize", cap(cache.setItemCh))
+}
+
+// DroppedItems returns the number of items dropped by SetAsync and SetWithCallback because the setItem channel is full,
+// or their writes failed even after retries.
+func (cache *RedisCache) DroppedItems() uint64 {
+	return atomic.LoadUint64(&cache.droppedItems)
+}
+
+// Delete removes an item from redis synchronously. It does nothing while the circuit breaker is open.
+func (cache *RedisCache) Delete(k []byte) {
+	if !cache.breaker.allow() {
+		return
+	}
+	key := cache.redisKey(k)
+	err := cache.client.Del(key).Err()
+	cache.markShardOperation(key, err)
+	cache.breaker.record(err)
+	if err != nil {
+		logger.Error("failed to delete an item from redis cache", "err", err, "key", key)
+	}
+}
+
+// FlushPrefix removes all items whose keys start with the given prefix from redis, e.g. to invalidate
+// the trie nodes of a namespace at once. It scans the keys incrementally not to block redis,
+// and all master nodes in cluster mode. It returns the number of removed items.
+func (cache *RedisCache) FlushPrefix(prefix []byte) (int, error) {
+	// keys are hex-encoded, so the prefix of hex-encoded keys is the hex-encoded prefix
+	pattern := escapeRedisPattern(cache.redisKey(prefix)) + "*"
+
+	cluster, ok := cache.client.(*redis.ClusterClient)
+	if !ok {
+		return deleteRedisKeys(cache.client, pattern)
+	}
+
+	var (
+		lock    sync.Mutex
+		deleted int
+	)
+	err := cluster.ForEachMaster(func(master *redis.Client) error {
+		n, err := deleteRedisKeys(master, pattern)
+		lock.Lock()
+		deleted += n
+		lock.Unlock()
+		return err
+	})
+	return deleted, err
+}
+
+// FlushAll removes all items of the cache from redis, e.g. when trie nodes are suspected stale or after a migration.
+// If a key prefix is set, only the items of the prefix are scanned and removed like FlushPrefix, not to remove the
+// items of the other caches sharing redis. Otherwise, the whole database is flushed by FLUSHDB, of all master nodes
+// in cluster mode.
+func (cache *RedisCache) FlushAll() error {
+	if cache.keyPrefix != "" {
+		deleted, err := cache.FlushPrefix(nil)
+		logger.Info("Flushed the items of the key prefix from redis cache", "keyPrefix", cache.keyPrefix,
+			"deleted", deleted, "err", err)
+		return err
+	}
+
+	var err error
+	if cluster, ok := cache.client.(*redis.ClusterClient); ok {
+		err = cluster.ForEachMaster(func(master *redis.Client) error {
+			return master.FlushDB().Err()
+		})
+	} else {
+		err = cache.client.FlushDB().Err()
+	}
+	logger.Info("Flushed redis cache", "err", err)
+	return err
+}
+
+// deleteRedisKeys removes the keys matching the given pattern from a redis node, and returns the number of them.
+func deleteRedisKeys(client redis.Cmdable, pattern string) (int, error) {
+	deleted := 0
+	cursor := uint64(0)
+	for {
+		keys, next, err := client.Scan(cursor, pattern, redisFlushScanCount).Result()
+		if err != nil {
+			return deleted, err
+		}
+		if len(keys) > 0 {
+			n, err := client.Del(keys...).Result()
+			deleted += int(n)
+			if err != nil {
+				return deleted, err
+			}
+		}
+		if next == 0 {
+			return deleted, nil
+		}
+		cursor = next
 	}
 }
 
 func (cache *RedisCache) Has(k []byte) ([]byte, bool) {
-	val := cache.Get(k)
+	val := cache.get(k, redisHasMetrics)
 	if val == nil {
 		return nil, false
 	}
 	return val, true
 }
 
+// CircuitState returns the state of the circuit breaker.
+func (cache *RedisCache) CircuitState() RedisCircuitState {
+	return cache.breaker.state()
+}
+
 func (cache *RedisCache) publish(channel string, msg string) error {
 	return cache.client.Publish(channel, msg).Err()
 }
@@ -158,19 +809,137 @@ func (cache *RedisCache) subscribe(channel string) *redis.PubSub {
 }
 
 func (cache *RedisCache) PublishBlock(msg string) error {
-	return cache.publish(redisSubscriptionChannelBlock, msg)
+	return cache.publish(cache.keyPrefix+redisSubscriptionChannelBlock, msg)
 }
 
 func (cache *RedisCache) SubscribeBlockCh() <-chan *redis.Message {
-	return cache.subscribe(redisSubscriptionChannelBlock).ChannelSize(redisSubscriptionChannelSize)
+	return cache.subscribe(cache.keyPrefix + redisSubscriptionChannelBlock).ChannelSize(redisSubscriptionChannelSize)
 }
 
 func (cache *RedisCache) UnsubscribeBlock() error {
-	return cache.pubSub.Unsubscribe(redisSubscriptionChannelBlock)
+	return cache.pubSub.Unsubscribe(cache.keyPrefix + redisSubscriptionChannelBlock)
 }
 
+// UpdateStats returns RedisCacheStats. The number of keys and the used memory are queried from redis at most once
+// in redisStatsInterval, and the previous ones are returned in between or while the circuit breaker is open.
+// If a key prefix is set, the keys of the prefix are counted in background by countPrefixKeys instead.
 func (cache *RedisCache) UpdateStats() interface{} {
-	return nil
+	cache.statsLock.Lock()
+	defer cache.statsLock.Unlock()
+
+	if time.Since(cache.statsUpdated) >= redisStatsInterval && cache.breaker.allow() {
+		cache.statsUpdated = time.Now()
+		keys, usedMemory, err := cache.queryStats()
+		if err != nil {
+			logger.Debug("cannot query the statistics of redis", "err", err)
+		} else {
+			cache.stats.UsedMemory = usedMemory
+			redisUsedMemoryGauge.Update(usedMemory)
+			if cache.keyPrefix == "" {
+				cache.stats.Keys = keys
+				redisKeysGauge.Update(keys)
+			}
+		}
+		if cache.keyPrefix != "" && !cache.keysCounting && time.Since(cache.keysCounted) >= redisPrefixKeysInterval {
+			cache.keysCounting = true
+			go cache.countPrefixKeys()
+		}
+	}
+
+	cache.stats.PendingItems = len(cache.setItemCh)
+	cache.stats.DroppedItems = cache.DroppedItems()
+	return cache.stats
+}
+
+// queryStats returns the number of keys and the used memory of redis, summed over all master nodes in cluster mode.
+func (cache *RedisCache) queryStats() (keys, usedMemory int64, err error) {
+	cluster, ok := cache.client.(*redis.ClusterClient)
+	if !ok {
+		return queryRedisNodeStats(cache.client)
+	}
+
+	var lock sync.Mutex
+	err = cluster.ForEachMaster(func(master *redis.Client) error {
+		k, m, err := queryRedisNodeStats(master)
+		lock.Lock()
+		keys += k
+		usedMemory += m
+		lock.Unlock()
+		return err
+	})
+	return keys, usedMemory, err
+}
+
+// countPrefixKeys counts the keys of the key prefix by scanning redis, of all master nodes in cluster mode,
+// and updates the statistics. The count is approximate since a key can be returned more than once by SCAN.
+func (cache *RedisCache) countPrefixKeys() {
+	pattern := escapeRedisPattern(cache.redisKey(nil)) + "*"
+
+	var (
+		keys int64
+		err  error
+	)
+	if cluster, ok := cache.client.(*redis.ClusterClient); ok {
+		var lock sync.Mutex
+		err = cluster.ForEachMaster(func(master *redis.Client) error {
+			n, err := countRedisKeys(master, pattern)
+			lock.Lock()
+			keys += n
+			lock.Unlock()
+			return err
+		})
+	} else {
+		keys, err = countRedisKeys(cache.client, pattern)
+	}
+
+	cache.statsLock.Lock()
+	defer cache.statsLock.Unlock()
+	cache.keysCounting = false
+	cache.keysCounted = time.Now()
+	if err != nil {
+		logger.Debug("cannot count the keys of the key prefix in redis", "keyPrefix", cache.keyPrefix, "err", err)
+		return
+	}
+	cache.stats.Keys = keys
+	redisKeysGauge.Update(keys)
+}
+
+// countRedisKeys returns the number of the keys matching the given pattern in a redis node.
+// It pauses between the scans not to load redis.
+func countRedisKeys(client redis.Cmdable, pattern string) (int64, error) {
+	keys := int64(0)
+	cursor := uint64(0)
+	for {
+		page, next, err := client.Scan(cursor, pattern, redisFlushScanCount).Result()
+		if err != nil {
+			return keys, err
+		}
+		keys += int64(len(page))
+		if next == 0 {
+			return keys, nil
+		}
+		cursor = next
+		time.Sleep(redisPrefixKeysScanPause)
+	}
+}
+
+// queryRedisNodeStats returns the number of keys and the used memory of a redis node by DBSIZE and INFO memory.
+func queryRedisNodeStats(client redis.Cmdable) (keys, usedMemory int64, err error) {
+	if keys, err = client.DBSize().Result(); err != nil {
+		return 0, 0, err
+	}
+	info, err := client.Info("memory").Result()
+	if err != nil {
+		return keys, 0, err
+	}
+	for _, line := range strings.Split(info, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "used_memory:") {
+			usedMemory, err = strconv.ParseInt(strings.TrimPrefix(line, "used_memory:"), 10, 64)
+			return keys, usedMemory, err
+		}
+	}
+	return keys, 0, errors.New("used_memory is not found in redis info")
 }
 
 func (cache *RedisCache) SaveToFile(filePath string, concurrency int) error {
@@ -178,6 +947,7 @@ func (cache *RedisCache) SaveToFile(filePath string, concurrency int) error {
 }
 
 func (cache *RedisCache) Close() error {
+	cache.breaker.stop()
 	cache.pubSub.Close()
 	close(cache.setItemCh)
 	return cache.client.Close()
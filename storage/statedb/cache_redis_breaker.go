@@ -0,0 +1,136 @@
+// Copyright 2021 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package statedb
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/rcrowley/go-metrics"
+)
+
+// RedisCircuitState is the state of the circuit breaker of a RedisCache.
+type RedisCircuitState string
+
+const (
+	RedisCircuitClosed RedisCircuitState = "closed" // requests are sent to redis
+	RedisCircuitOpen   RedisCircuitState = "open"   // requests are not sent to redis until a health check succeeds
+)
+
+var (
+	// number of consecutive failures opening the circuit breaker
+	redisBreakerFailureThreshold = uint64(5)
+	// interval of pinging redis while the circuit breaker is open
+	redisBreakerHealthCheckInterval = time.Second
+
+	errRedisCircuitOpen = errors.New("redis circuit breaker is open")
+
+	redisBreakerOpenGauge   = metrics.NewRegisteredGauge("trie/cache/redis/breaker/open", nil)
+	redisBreakerTripCounter = metrics.NewRegisteredCounter("trie/cache/redis/breaker/trips", nil)
+)
+
+// redisCircuitBreaker stops requests to redis after consecutive failures. Without it, every request waits
+// for the timeout of the redis client while redis is unavailable, which stalls trie access.
+// While it is open, a background health check pings redis, and the breaker is closed once redis responds.
+// A nil redisCircuitBreaker always allows requests.
+type redisCircuitBreaker struct {
+	failures uint64 // consecutive failures
+	open     int32  // 1 if open
+
+	threshold uint64
+	interval  time.Duration
+	ping      func() error
+
+	quit     chan struct{}
+	quitOnce sync.Once
+}
+
+func newRedisCircuitBreaker(threshold uint64, interval time.Duration, ping func() error) *redisCircuitBreaker {
+	return &redisCircuitBreaker{
+		threshold: threshold,
+		interval:  interval,
+		ping:      ping,
+		quit:      make(chan struct{}),
+	}
+}
+
+// allow reports whether a request can be sent to redis.
+func (b *redisCircuitBreaker) allow() bool {
+	return b == nil || atomic.LoadInt32(&b.open) == 0
+}
+
+// record records the result of a request to redis. A miss (redis.Nil) is a success.
+// It opens the breaker and starts the health check if the failures reach the threshold.
+func (b *redisCircuitBreaker) record(err error) {
+	if b == nil {
+		return
+	}
+	if err == nil || err == redis.Nil {
+		atomic.StoreUint64(&b.failures, 0)
+		return
+	}
+	if atomic.AddUint64(&b.failures, 1) < b.threshold || !atomic.CompareAndSwapInt32(&b.open, 0, 1) {
+		return
+	}
+
+	logger.Warn("Open redis circuit breaker; requests to redis are skipped until it recovers",
+		"failures", b.threshold, "lastErr", err)
+	redisBreakerOpenGauge.Update(1)
+	redisBreakerTripCounter.Inc(1)
+	go b.healthCheck()
+}
+
+// healthCheck pings redis periodically, and closes the breaker once redis responds.
+func (b *redisCircuitBreaker) healthCheck() {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.quit:
+			return
+		case <-ticker.C:
+			if err := b.ping(); err != nil {
+				logger.Debug("redis is still unavailable", "err", err)
+				continue
+			}
+			atomic.StoreUint64(&b.failures, 0)
+			atomic.StoreInt32(&b.open, 0)
+			redisBreakerOpenGauge.Update(0)
+			logger.Info("Close redis circuit breaker; redis is available again")
+			return
+		}
+	}
+}
+
+func (b *redisCircuitBreaker) state() RedisCircuitState {
+	if b.allow() {
+		return RedisCircuitClosed
+	}
+	return RedisCircuitOpen
+}
+
+// stop stops the health check. It can be called more than once.
+func (b *redisCircuitBreaker) stop() {
+	if b == nil {
+		return
+	}
+	b.quitOnce.Do(func() { close(b.quit) })
+}
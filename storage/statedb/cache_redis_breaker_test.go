@@ -0,0 +1,115 @@
+// Copyright 2021 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package statedb
+
+import (
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDeadRedisEndpoint returns the address of a dead redis server, which accepts connections without responding,
+// and a function closing it.
+func newDeadRedisEndpoint(t *testing.T) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	return listener.Addr().String(), func() { listener.Close() }
+}
+
+// TestRedisCache_CircuitBreaker tests whether requests to a dead redis return immediately once the breaker opens.
+func TestRedisCache_CircuitBreaker(t *testing.T) {
+	endpoint, closeEndpoint := newDeadRedisEndpoint(t)
+	defer closeEndpoint()
+
+	timeout := 100 * time.Millisecond
+	cache := &RedisCache{client: redis.NewClient(&redis.Options{
+		Addr:         endpoint,
+		DialTimeout:  timeout,
+		ReadTimeout:  timeout,
+		WriteTimeout: timeout,
+		MaxRetries:   0,
+	})}
+	cache.breaker = newRedisCircuitBreaker(2, time.Hour, func() error { return cache.client.Ping().Err() })
+	defer cache.breaker.stop()
+
+	key, value := randBytes(32), randBytes(500)
+
+	// requests wait for the timeout until the failures reach the threshold
+	start := time.Now()
+	assert.Error(t, cache.set(key, value))
+	assert.Nil(t, cache.Get(key))
+	assert.True(t, time.Since(start) >= 2*timeout)
+	assert.Equal(t, RedisCircuitOpen, cache.CircuitState())
+
+	// requests return immediately while the breaker is open
+	start = time.Now()
+	assert.Nil(t, cache.Get(key))
+	_, ok := cache.Has(key)
+	assert.False(t, ok)
+	assert.Equal(t, errRedisCircuitOpen, cache.set(key, value))
+	assert.True(t, time.Since(start) < 10*time.Millisecond, "elapsed: %v", time.Since(start))
+}
+
+// TestRedisCircuitBreaker tests whether the breaker opens on consecutive failures and closes on a successful health check.
+func TestRedisCircuitBreaker(t *testing.T) {
+	available := int32(0)
+	breaker := newRedisCircuitBreaker(3, 10*time.Millisecond, func() error {
+		if atomic.LoadInt32(&available) == 0 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+	defer breaker.stop()
+
+	// a success or a miss resets the consecutive failures
+	failure := errors.New("failure")
+	breaker.record(failure)
+	breaker.record(failure)
+	breaker.record(redis.Nil)
+	breaker.record(failure)
+	breaker.record(failure)
+	assert.Equal(t, RedisCircuitClosed, breaker.state())
+
+	breaker.record(failure)
+	assert.Equal(t, RedisCircuitOpen, breaker.state())
+
+	// it stays open while the health check fails
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, RedisCircuitOpen, breaker.state())
+
+	atomic.StoreInt32(&available, 1)
+	assert.Eventually(t, func() bool { return breaker.state() == RedisCircuitClosed }, time.Second, 10*time.Millisecond)
+
+	// a nil breaker always allows requests
+	var nilBreaker *redisCircuitBreaker
+	assert.Equal(t, RedisCircuitClosed, nilBreaker.state())
+}
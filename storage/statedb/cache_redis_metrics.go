@@ -0,0 +1,72 @@
+// Copyright 2021 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package statedb
+
+import (
+	"time"
+
+	"github.com/go-redis/redis/v7"
+	"github.com/rcrowley/go-metrics"
+)
+
+// redisOpMetrics is the metrics of an operation of RedisCache.
+// They are registered as "trie/cache/redis/<operation>/{latency,errors}".
+type redisOpMetrics struct {
+	latency metrics.Timer
+	errors  metrics.Counter
+}
+
+func newRedisOpMetrics(op string) redisOpMetrics {
+	prefix := "trie/cache/redis/" + op
+	return redisOpMetrics{
+		latency: metrics.NewRegisteredTimer(prefix+"/latency", nil),
+		errors:  metrics.NewRegisteredCounter(prefix+"/errors", nil),
+	}
+}
+
+// mark records the latency of an operation started at the given time, and its failure.
+// A miss (redis.Nil) is not counted as an error.
+func (m redisOpMetrics) mark(start time.Time, err error) {
+	m.latency.UpdateSince(start)
+	if err != nil && err != redis.Nil {
+		m.errors.Inc(1)
+	}
+}
+
+var (
+	redisGetMetrics      = newRedisOpMetrics("get")
+	redisHasMetrics      = newRedisOpMetrics("has")
+	redisSetMetrics      = newRedisOpMetrics("set")
+	redisSetBatchMetrics = newRedisOpMetrics("setbatch")
+
+	// hits and misses of Get and Has. An undecodable value is a miss.
+	redisHitCounter  = metrics.NewRegisteredCounter("trie/cache/redis/hit", nil)
+	redisMissCounter = metrics.NewRegisteredCounter("trie/cache/redis/miss", nil)
+
+	// number of items waiting in the setItem channel. Items are dropped if it reaches the channel size.
+	redisSetItemPendingGauge = metrics.NewRegisteredGauge("trie/cache/redis/setitem/pending", nil)
+
+	// number of items found in the local cache of a hybrid cache but not in redis, and re-populated to redis
+	redisRepopulateCounter = metrics.NewRegisteredCounter("trie/cache/redis/repopulated", nil)
+
+	// number of retries of items failed to be written asynchronously
+	redisSetRetryCounter = metrics.NewRegisteredCounter("trie/cache/redis/setitem/retries", nil)
+
+	// number of keys of the key prefix or in redis, and used memory of the redis instance, updated by UpdateStats
+	redisKeysGauge       = metrics.NewRegisteredGauge("trie/cache/redis/keys", nil)
+	redisUsedMemoryGauge = metrics.NewRegisteredGauge("trie/cache/redis/usedmemory", nil)
+)
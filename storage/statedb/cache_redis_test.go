@@ -17,15 +17,35 @@
 package statedb
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/big"
 	"net"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/go-redis/redis/v7"
+	"github.com/klaytn/klaytn/common/hexutil"
+	metricutils "github.com/klaytn/klaytn/metrics/utils"
 	"github.com/klaytn/klaytn/storage"
+	"github.com/rcrowley/go-metrics"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -105,6 +125,319 @@ func TestRedisCache(t *testing.T) {
 	assert.Equal(t, bytes.Compare(value, hasValue), 0)
 }
 
+// TestRedisCache_Delete tests whether a deleted item is not found.
+func TestRedisCache_Delete(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	cache, err := newRedisCache(getTestRedisConfig())
+	assert.Nil(t, err)
+
+	key, value := randBytes(32), randBytes(500)
+	cache.Set(key, value)
+	assert.Equal(t, value, cache.Get(key))
+
+	cache.Delete(key)
+	assert.Nil(t, cache.Get(key))
+	_, ok := cache.Has(key)
+	assert.False(t, ok)
+}
+
+// TestRedisCache_EntryTTL tests whether an item expires after the TTL.
+func TestRedisCache_EntryTTL(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	config := getTestRedisConfig()
+	config.RedisEntryTTL = 100 * time.Millisecond
+	cache, err := newRedisCache(config)
+	assert.Nil(t, err)
+
+	key, value := randBytes(32), randBytes(500)
+	assert.Nil(t, cache.SetSync(key, value))
+	assert.Equal(t, value, cache.Get(key))
+
+	ttl, err := cache.client.PTTL(cache.redisKey(key)).Result()
+	assert.Nil(t, err)
+	assert.True(t, ttl > 0 && ttl <= config.RedisEntryTTL, "ttl: %v", ttl)
+
+	time.Sleep(2 * config.RedisEntryTTL)
+	assert.Nil(t, cache.Get(key))
+}
+
+// TestRedisCache_FlushPrefix tests whether only the items of the given prefix are removed.
+func TestRedisCache_FlushPrefix(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	cache, err := newRedisCache(getTestRedisConfig())
+	assert.Nil(t, err)
+
+	prefix := randBytes(8)
+	var flushed, kept [][]byte
+	for i := 0; i < 10; i++ {
+		flushed = append(flushed, append(append([]byte{}, prefix...), randBytes(24)...))
+		kept = append(kept, randBytes(32))
+	}
+	for _, key := range append(append([][]byte{}, flushed...), kept...) {
+		assert.Nil(t, cache.SetSync(key, randBytes(100)))
+	}
+
+	deleted, err := cache.FlushPrefix(prefix)
+	assert.Nil(t, err)
+	assert.Equal(t, len(flushed), deleted)
+	for _, key := range flushed {
+		assert.Nil(t, cache.Get(key))
+	}
+	for _, key := range kept {
+		assert.NotNil(t, cache.Get(key))
+	}
+}
+
+// TestRedisCache_FlushAll tests whether all items of the key prefix are removed, keeping the items of the other prefix.
+func TestRedisCache_FlushAll(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	configA, configB := getTestRedisConfig(), getTestRedisConfig()
+	configA.RedisKeyPrefix, configB.RedisKeyPrefix = "flushall-a:", "flushall-b:"
+	cacheA, err := newRedisCache(configA)
+	assert.Nil(t, err)
+	cacheB, err := newRedisCache(configB)
+	assert.Nil(t, err)
+
+	var keys [][]byte
+	for i := 0; i < 10; i++ {
+		keys = append(keys, randBytes(32))
+	}
+	for _, key := range keys {
+		assert.Nil(t, cacheA.SetSync(key, randBytes(100)))
+		assert.Nil(t, cacheB.SetSync(key, randBytes(100)))
+	}
+
+	assert.Nil(t, cacheA.FlushAll())
+	for _, key := range keys {
+		assert.Nil(t, cacheA.Get(key))
+		assert.NotNil(t, cacheB.Get(key))
+	}
+}
+
+// TestRedisCache_UpdateStats tests whether the statistics of a populated redis are reported.
+func TestRedisCache_UpdateStats(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	cache, err := newRedisCache(getTestRedisConfig())
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, cache.SetSync(randBytes(32), randBytes(100)))
+	}
+
+	stats, ok := cache.UpdateStats().(RedisCacheStats)
+	assert.True(t, ok)
+	assert.True(t, stats.Keys >= 10, "keys: %d", stats.Keys)
+	assert.True(t, stats.UsedMemory > 0, "used memory: %d", stats.UsedMemory)
+	assert.Equal(t, stats.Keys, redisKeysGauge.Value())
+
+	// redis is not queried again within redisStatsInterval
+	assert.Nil(t, cache.SetSync(randBytes(32), randBytes(100)))
+	assert.Equal(t, stats.Keys, cache.UpdateStats().(RedisCacheStats).Keys)
+}
+
+// TestRedisCache_UpdateStatsKeyPrefix tests whether the number of keys of a key prefix is reported
+// without the keys of the other key prefixes sharing redis.
+func TestRedisCache_UpdateStatsKeyPrefix(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	configA, configB := getTestRedisConfig(), getTestRedisConfig()
+	configA.RedisKeyPrefix = fmt.Sprintf("stats-a-%x:", randBytes(8))
+	configB.RedisKeyPrefix = fmt.Sprintf("stats-b-%x:", randBytes(8))
+	cacheA, err := newRedisCache(configA)
+	assert.Nil(t, err)
+	cacheB, err := newRedisCache(configB)
+	assert.Nil(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.Nil(t, cacheA.SetSync(randBytes(32), randBytes(100)))
+		assert.Nil(t, cacheB.SetSync(randBytes(32), randBytes(100)))
+	}
+	assert.Nil(t, cacheB.SetSync(randBytes(32), randBytes(100)))
+
+	// the keys are not counted yet, and counted in background
+	stats := cacheA.UpdateStats().(RedisCacheStats)
+	assert.Equal(t, int64(-1), stats.Keys)
+	assert.True(t, stats.UsedMemory > 0, "used memory: %d", stats.UsedMemory)
+	cacheB.UpdateStats()
+
+	time.Sleep(sleepDurationForAsyncBehavior)
+	assert.Equal(t, int64(10), cacheA.UpdateStats().(RedisCacheStats).Keys)
+	assert.Equal(t, int64(11), cacheB.UpdateStats().(RedisCacheStats).Keys)
+
+	_, err = cacheA.FlushPrefix(nil)
+	assert.Nil(t, err)
+	_, err = cacheB.FlushPrefix(nil)
+	assert.Nil(t, err)
+}
+
+// TestRedisCache_KeyPrefix tests whether caches of different key prefixes do not share items and blocks.
+func TestRedisCache_KeyPrefix(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	configA, configB := getTestRedisConfig(), getTestRedisConfig()
+	configA.RedisKeyPrefix, configB.RedisKeyPrefix = "mainnet:", "testnet:"
+	cacheA, err := newRedisCache(configA)
+	assert.Nil(t, err)
+	cacheB, err := newRedisCache(configB)
+	assert.Nil(t, err)
+
+	// the same key is set to different values
+	key, valueA, valueB := randBytes(32), randBytes(500), randBytes(500)
+	assert.Nil(t, cacheA.SetSync(key, valueA))
+	assert.Nil(t, cacheB.SetSync(key, valueB))
+	assert.Equal(t, valueA, cacheA.Get(key))
+	assert.Equal(t, valueB, cacheB.Get(key))
+
+	stored, err := cacheA.client.Get(configA.RedisKeyPrefix + hexutil.Encode(key)).Bytes()
+	assert.Nil(t, err)
+	assert.Equal(t, valueA, stored)
+
+	// flushing a prefix of a cache does not remove the items of the other
+	_, err = cacheA.FlushPrefix(key[:4])
+	assert.Nil(t, err)
+	assert.Nil(t, cacheA.Get(key))
+	assert.Equal(t, valueB, cacheB.Get(key))
+
+	// blocks are published to the subscribers of the same prefix only
+	chA, chB := cacheA.SubscribeBlockCh(), cacheB.SubscribeBlockCh()
+	time.Sleep(sleepDurationForAsyncBehavior)
+	assert.Nil(t, cacheA.PublishBlock("blockA"))
+
+	select {
+	case msg := <-chA:
+		assert.Equal(t, "blockA", msg.Payload)
+	case <-time.After(time.Second):
+		t.Fatal("block is not received")
+	}
+	select {
+	case msg := <-chB:
+		t.Fatalf("block of another prefix is received: %s", msg.Payload)
+	case <-time.After(sleepDurationForAsyncBehavior):
+	}
+}
+
+func TestEscapeRedisPattern(t *testing.T) {
+	assert.Equal(t, "mainnet:0x01", escapeRedisPattern("mainnet:0x01"))
+	assert.Equal(t, `a\*b\?c\[d\]e\\f`, escapeRedisPattern(`a*b?c[d]e\f`))
+}
+
+func TestDecodeRedisValue(t *testing.T) {
+	rawValue := []byte{0xc2, 0x80, 0x80}
+
+	testCases := []struct {
+		value    []byte
+		expected []byte
+		err      error
+	}{
+		{rawValue, rawValue, nil},
+		{append(append([]byte{}, redisValueMagic...), append([]byte{redisValueCodecNone}, rawValue...)...), rawValue, nil},
+		{redisValueMagic, nil, errRedisValueTruncated},
+		{append(append([]byte{}, redisValueMagic...), 0xff, 0x01), nil, errRedisValueUnknownCodec},
+		{append(append([]byte{}, redisValueMagic...), redisValueCodecSnappy, 0xff), nil, errRedisValueDecompress},
+	}
+	for _, tc := range testCases {
+		value, err := decodeRedisValue(tc.value)
+		assert.Equal(t, tc.expected, value)
+		assert.True(t, errors.Is(err, tc.err), "err: %v", err)
+	}
+}
+
+func TestEncodeRedisValue(t *testing.T) {
+	compressible := bytes.Repeat([]byte{0xc2, 0x80, 0x80}, 1024)
+	incompressible := randBytes(1024)
+
+	for _, value := range [][]byte{compressible, incompressible, {}} {
+		// not compressed values are written as they are
+		assert.Equal(t, value, encodeRedisValue(value, RedisCompressionNone))
+
+		encoded := encodeRedisValue(value, RedisCompressionSnappy)
+		assert.True(t, bytes.HasPrefix(encoded, append(append([]byte{}, redisValueMagic...), redisValueCodecSnappy)))
+		decoded, err := decodeRedisValue(encoded)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, bytes.Compare(value, decoded))
+	}
+	assert.True(t, len(encodeRedisValue(compressible, RedisCompressionSnappy)) < len(compressible)/10)
+}
+
+// TestRedisCache_Compression checks that a compressible value is stored compressed, and read as it was.
+func TestRedisCache_Compression(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	config := getTestRedisConfig()
+	config.RedisCompression = RedisCompressionSnappy
+	cache, err := newRedisCache(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, value := randBytes(32), bytes.Repeat([]byte("klaytn"), 5*1024*1024/6) // about 5MB
+	assert.Nil(t, cache.SetSync(key, value))
+	assert.Equal(t, value, cache.Get(key))
+
+	storedSize, err := cache.client.StrLen(cache.redisKey(key)).Result()
+	assert.Nil(t, err)
+	assert.True(t, storedSize < int64(len(value)/10), "stored: %d, original: %d", storedSize, len(value))
+
+	// values written without compression are still read
+	uncompressed, err := newRedisCache(getTestRedisConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Nil(t, uncompressed.SetSync(key, value))
+	assert.Equal(t, value, cache.Get(key))
+}
+
+// TestRedisCache_Get_CorruptedValue checks that an undecodable value is treated as a miss.
+func TestRedisCache_Get_CorruptedValue(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	cache, err := newRedisCache(getTestRedisConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key := randBytes(32)
+	corrupted := append(append([]byte{}, redisValueMagic...), 0xff, 0x01)
+	if err := cache.client.Set(cache.redisKey(key), corrupted, 0).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	before := redisCorruptedValueCounter.Count()
+	assert.Nil(t, cache.Get(key))
+	assert.Equal(t, before+1, redisCorruptedValueCounter.Count())
+
+	_, ok := cache.Has(key)
+	assert.False(t, ok)
+}
+
+// TestRedisCache_ShardMetrics checks that single-node mode reports operations with a single shard label.
+func TestRedisCache_ShardMetrics(t *testing.T) {
+	enabled := metricutils.Enabled
+	metricutils.Enabled = true
+	defer func() { metricutils.Enabled = enabled }()
+
+	addr := "localhost:16379" // no server listens on it
+	cache := &RedisCache{client: redis.NewClient(&redis.Options{Addr: addr})}
+	assert.Equal(t, addr, cache.shardAddr("0x01"))
+	assert.Equal(t, addr, cache.shardAddr("0x02"))
+
+	ops := metrics.GetOrRegisterCounter(redisShardMetricPrefix+addr+"/ops", nil)
+	errs := metrics.GetOrRegisterCounter(redisShardMetricPrefix+addr+"/errors", nil)
+	opsBefore, errsBefore := ops.Count(), errs.Count()
+
+	cache.markShardOperation("0x01", nil)
+	cache.markShardOperation("0x02", redis.Nil) // a miss is not an error
+	cache.markShardOperation("0x03", errors.New("connection refused"))
+
+	assert.Equal(t, opsBefore+3, ops.Count())
+	assert.Equal(t, errsBefore+1, errs.Count())
+}
+
 // TestRedisCache_Set_LargeData check whether redis cache can store an large data (5MB).
 func TestRedisCache_Set_LargeData(t *testing.T) {
 	storage.SkipLocalTest(t)
@@ -121,6 +454,27 @@ func TestRedisCache_Set_LargeData(t *testing.T) {
 	assert.Equal(t, bytes.Compare(value, retValue), 0)
 }
 
+// TestRedisCache_SetSync checks that an item written by SetSync is read right after the write.
+func TestRedisCache_SetSync(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	cache, err := newRedisCache(getTestRedisConfig())
+	assert.Nil(t, err)
+
+	key, value := randBytes(32), randBytes(500)
+	assert.Nil(t, cache.SetSync(key, value))
+	assert.Equal(t, value, cache.Get(key))
+}
+
+// TestRedisCache_SetSync_Error checks that SetSync returns the error of the write.
+func TestRedisCache_SetSync_Error(t *testing.T) {
+	cache := &RedisCache{breaker: newRedisCircuitBreaker(1, time.Hour, func() error { return nil })}
+	defer cache.breaker.stop()
+	cache.breaker.open = 1
+
+	assert.Equal(t, errRedisCircuitOpen, cache.SetSync(randBytes(32), randBytes(500)))
+}
+
 // TestRedisCache_SetAsync tests basic operations of redis cache using SetAsync instead of Set.
 func TestRedisCache_SetAsync(t *testing.T) {
 	storage.SkipLocalTest(t)
@@ -140,6 +494,161 @@ func TestRedisCache_SetAsync(t *testing.T) {
 	assert.Equal(t, bytes.Compare(value, hasValue), 0)
 }
 
+// TestRedisCache_SetWithCallback checks that the callback is called after the item is written.
+func TestRedisCache_SetWithCallback(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	cache, err := newRedisCache(getTestRedisConfig())
+	assert.Nil(t, err)
+
+	key, value := randBytes(32), randBytes(500)
+	resultCh := make(chan error, 1)
+	cache.SetWithCallback(key, value, func(err error) { resultCh <- err })
+
+	select {
+	case err := <-resultCh:
+		assert.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("callback is not called")
+	}
+	assert.Equal(t, value, cache.Get(key))
+}
+
+// TestRedisCache_SetWithCallback_Dropped checks that the callback is called when the item is dropped.
+func TestRedisCache_SetWithCallback_Dropped(t *testing.T) {
+	// no worker receives from the channel, so every item is dropped
+	cache := &RedisCache{setItemCh: make(chan setItem)}
+
+	var result error
+	called := false
+	cache.SetWithCallback(randBytes(32), randBytes(500), func(err error) { result, called = err, true })
+
+	assert.True(t, called)
+	assert.Equal(t, errRedisSetItemDropped, result)
+}
+
+// TestRedisCache_SetItemChannelSize checks that the setItem channel is created with the configured size.
+func TestRedisCache_SetItemChannelSize(t *testing.T) {
+	// workers are blocked on writing to a dead redis, so items are buffered in the channel
+	endpoint, closeEndpoint := newDeadRedisEndpoint(t)
+	defer closeEndpoint()
+
+	config := getTestRedisConfig()
+	config.RedisEndpoints = []string{endpoint}
+
+	numItems := 100
+	testcases := []struct {
+		channelSize int
+		dropped     bool
+	}{
+		{0, false}, // default, redisSetItemChannelSize
+		{10 * numItems, false},
+		{1, true},
+	}
+	for _, tc := range testcases {
+		config.RedisSetItemChannelSize = tc.channelSize
+		cache, err := newRedisCache(config)
+		assert.Nil(t, err)
+		if tc.channelSize == 0 {
+			assert.Equal(t, redisSetItemChannelSize, cap(cache.setItemCh))
+		} else {
+			assert.Equal(t, tc.channelSize, cap(cache.setItemCh))
+		}
+
+		for i := 0; i < numItems; i++ {
+			cache.SetAsync(randBytes(32), randBytes(500))
+		}
+		assert.Equal(t, tc.dropped, cache.DroppedItems() > 0, "channel size: %d", tc.channelSize)
+		cache.Close()
+	}
+
+	config.RedisSetItemChannelSize = -1
+	_, err := newRedisCache(config)
+	assert.Equal(t, errRedisChannelSize, err)
+}
+
+// flakyRedisClient is a redis client whose Set fails the given number of times first.
+// Only Set is supported.
+type flakyRedisClient struct {
+	redis.UniversalClient
+
+	lock     sync.Mutex
+	failures int // number of Sets left to fail
+	sets     int // number of Sets called
+	written  map[string][]byte
+}
+
+func (c *flakyRedisClient) Set(key string, value interface{}, expiration time.Duration) *redis.StatusCmd {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.sets++
+	if c.failures > 0 {
+		c.failures--
+		return redis.NewStatusResult("", errors.New("connection reset by peer"))
+	}
+	c.written[key] = value.([]byte)
+	return redis.NewStatusResult("OK", nil)
+}
+
+// TestRedisCache_SetAsync_Retry checks that an item failed to be written asynchronously is retried,
+// and counted as dropped if it is not written after the retries.
+func TestRedisCache_SetAsync_Retry(t *testing.T) {
+	testcases := []struct {
+		failures int
+		written  bool
+	}{
+		{0, true},
+		{1, true},
+		{2, true},
+		{3, false}, // the first attempt and 2 retries fail
+	}
+	for _, tc := range testcases {
+		client := &flakyRedisClient{failures: tc.failures, written: make(map[string][]byte)}
+		cache := &RedisCache{client: client, setItemCh: make(chan setItem, 1), setRetries: 2, setRetryBackoff: time.Millisecond}
+		go cache.runSetWorker()
+
+		key, value := randBytes(32), randBytes(500)
+		resultCh := make(chan error, 1)
+		cache.SetWithCallback(key, value, func(err error) { resultCh <- err })
+
+		select {
+		case err := <-resultCh:
+			assert.Equal(t, tc.written, err == nil, "failures: %d", tc.failures)
+		case <-time.After(time.Second):
+			t.Fatalf("callback is not called (failures: %d)", tc.failures)
+		}
+		close(cache.setItemCh)
+
+		client.lock.Lock()
+		if tc.written {
+			assert.Equal(t, value, client.written[cache.redisKey(key)])
+			assert.Equal(t, tc.failures+1, client.sets)
+			assert.Equal(t, uint64(0), cache.DroppedItems())
+		} else {
+			assert.Empty(t, client.written)
+			assert.Equal(t, 3, client.sets)
+			assert.Equal(t, uint64(1), cache.DroppedItems())
+		}
+		client.lock.Unlock()
+	}
+}
+
+// TestRedisCache_SetAsync_DroppedItems checks that items dropped by a full setItem channel are counted.
+func TestRedisCache_SetAsync_DroppedItems(t *testing.T) {
+	// no worker receives from the channel, so the items exceeding the channel size are dropped
+	channelSize := 10
+	cache := &RedisCache{setItemCh: make(chan setItem, channelSize)}
+
+	before := redisDroppedItemsCounter.Count()
+	for i := 0; i < 3*channelSize; i++ {
+		cache.SetAsync(randBytes(32), randBytes(500))
+	}
+
+	assert.Equal(t, uint64(2*channelSize), cache.DroppedItems())
+	assert.Equal(t, before+int64(2*channelSize), redisDroppedItemsCounter.Count())
+}
+
 // TestRedisCache_SetAsync_LargeData check whether redis cache can store an large data asynchronously (5MB).
 func TestRedisCache_SetAsync_LargeData(t *testing.T) {
 	storage.SkipLocalTest(t)
@@ -239,13 +748,13 @@ func TestRedisCache_Timeout(t *testing.T) {
 		}
 	}()
 
-	var cache TrieNodeCache = &RedisCache{redis.NewClient(&redis.Options{
+	var cache TrieNodeCache = &RedisCache{client: redis.NewClient(&redis.Options{
 		Addr:         "localhost:11234",
 		DialTimeout:  redisCacheDialTimeout,
 		ReadTimeout:  redisCacheTimeout,
 		WriteTimeout: redisCacheTimeout,
 		MaxRetries:   0,
-	}), nil, nil}
+	})}
 
 	key, value := randBytes(32), randBytes(500)
 
@@ -262,3 +771,646 @@ func TestRedisCache_Timeout(t *testing.T) {
 	_, _ = cache.Has(key)
 	assert.Equal(t, redisCacheTimeout, time.Since(start).Round(redisCacheTimeout/2))
 }
+
+// TestRedisCache_CustomTimeouts checks that the timeouts of the config are applied on the client,
+// and the defaults are used for zero values.
+func TestRedisCache_CustomTimeouts(t *testing.T) {
+	config := getTestRedisConfig()
+	config.RedisDialTimeout = 3 * time.Second
+	config.RedisReadTimeout = 200 * time.Millisecond
+	config.RedisWriteTimeout = 300 * time.Millisecond
+
+	cache, err := newRedisCache(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	options := cache.client.(*redis.Client).Options()
+	assert.Equal(t, 3*time.Second, options.DialTimeout)
+	assert.Equal(t, 200*time.Millisecond, options.ReadTimeout)
+	assert.Equal(t, 300*time.Millisecond, options.WriteTimeout)
+
+	// the timeouts of the cluster client are also configured
+	config.RedisClusterEnable = true
+	config.RedisWriteTimeout = 0
+	clusterCache, err := newRedisCache(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer clusterCache.Close()
+
+	clusterOptions := clusterCache.client.(*redis.ClusterClient).Options()
+	assert.Equal(t, 3*time.Second, clusterOptions.DialTimeout)
+	assert.Equal(t, 200*time.Millisecond, clusterOptions.ReadTimeout)
+	assert.Equal(t, redisCacheTimeout, clusterOptions.WriteTimeout)
+}
+
+// TestRedisCache_SetRetries checks that the retries of asynchronous writes are configured,
+// and the defaults are used for zero values.
+func TestRedisCache_SetRetries(t *testing.T) {
+	testcases := []struct {
+		retries         int
+		backoff         time.Duration
+		expectedRetries int
+		expectedBackoff time.Duration
+	}{
+		{0, 0, redisSetRetries, redisSetRetryBackoff},
+		{5, 10 * time.Millisecond, 5, 10 * time.Millisecond},
+		{-1, 0, 0, redisSetRetryBackoff}, // no retry
+	}
+	for _, tc := range testcases {
+		config := getTestRedisConfig()
+		config.RedisSetRetries, config.RedisSetRetryBackoff = tc.retries, tc.backoff
+
+		cache, err := newRedisCache(config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, tc.expectedRetries, cache.setRetries)
+		assert.Equal(t, tc.expectedBackoff, cache.setRetryBackoff)
+		cache.Close()
+	}
+}
+
+// writeTestKeyPair writes a self-signed certificate for 127.0.0.1 and its private key into the given directory.
+func writeTestKeyPair(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile := filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	if err := ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestNewRedisTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "redis_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestKeyPair(t, dir)
+
+	// no TLS
+	tlsConfig, err := newRedisTLSConfig(&TrieNodeCacheConfig{})
+	assert.Nil(t, err)
+	assert.Nil(t, tlsConfig)
+
+	// TLS without a client certificate
+	tlsConfig, err = newRedisTLSConfig(&TrieNodeCacheConfig{RedisTLSEnable: true, RedisTLSCAFile: certFile})
+	assert.Nil(t, err)
+	if assert.NotNil(t, tlsConfig) {
+		assert.Equal(t, 0, len(tlsConfig.Certificates))
+		assert.NotNil(t, tlsConfig.RootCAs)
+		assert.False(t, tlsConfig.InsecureSkipVerify)
+	}
+
+	tlsConfig, err = newRedisTLSConfig(&TrieNodeCacheConfig{RedisTLSEnable: true, RedisTLSSkipVerify: true})
+	assert.Nil(t, err)
+	if assert.NotNil(t, tlsConfig) {
+		assert.Nil(t, tlsConfig.RootCAs)
+		assert.True(t, tlsConfig.InsecureSkipVerify)
+	}
+
+	// client certificate
+	tlsConfig, err = newRedisTLSConfig(&TrieNodeCacheConfig{RedisTLSCertFile: certFile, RedisTLSKeyFile: keyFile})
+	assert.Nil(t, err)
+	if assert.NotNil(t, tlsConfig) {
+		assert.Equal(t, 1, len(tlsConfig.Certificates))
+	}
+
+	// the TLS config is used by both of single-node and cluster clients
+	cli, err := newRedisClient(&TrieNodeCacheConfig{RedisEndpoints: []string{"localhost:6379"}}, tlsConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, tlsConfig, cli.(*redis.Client).Options().TLSConfig)
+	cli.Close()
+
+	cli, err = newRedisClient(&TrieNodeCacheConfig{RedisEndpoints: []string{"localhost:6379"}, RedisClusterEnable: true}, tlsConfig)
+	assert.Nil(t, err)
+	assert.Equal(t, tlsConfig, cli.(*redis.ClusterClient).Options().TLSConfig)
+	cli.Close()
+
+	// incomplete or invalid key pairs
+	_, err = newRedisTLSConfig(&TrieNodeCacheConfig{RedisTLSCertFile: certFile})
+	assert.Equal(t, errRedisTLSKeyPair, err)
+
+	_, err = newRedisTLSConfig(&TrieNodeCacheConfig{RedisTLSEnable: true, RedisTLSKeyFile: keyFile})
+	assert.Equal(t, errRedisTLSKeyPair, err)
+
+	_, err = newRedisTLSConfig(&TrieNodeCacheConfig{RedisTLSCertFile: certFile, RedisTLSKeyFile: filepath.Join(dir, "nonexistent.key")})
+	assert.NotNil(t, err)
+
+	_, err = newRedisTLSConfig(&TrieNodeCacheConfig{RedisTLSCertFile: keyFile, RedisTLSKeyFile: certFile})
+	assert.NotNil(t, err)
+
+	// invalid CA certificates
+	_, err = newRedisTLSConfig(&TrieNodeCacheConfig{RedisTLSEnable: true, RedisTLSCAFile: keyFile})
+	assert.True(t, errors.Is(err, errRedisTLSCA))
+
+	_, err = newRedisTLSConfig(&TrieNodeCacheConfig{RedisTLSEnable: true, RedisTLSCAFile: filepath.Join(dir, "nonexistent.crt")})
+	assert.NotNil(t, err)
+}
+
+// serveTestRedisPing serves a mock redis server answering PONG to every command on the given listener.
+func serveTestRedisPing(listener net.Listener) {
+	serveTestRedis(listener, "")
+}
+
+// serveTestRedis serves a mock redis server requiring the given password, which is not required if empty.
+// It answers PONG to every command except AUTH.
+func serveTestRedis(listener net.Listener, password string) {
+	serveTestRedisHandler(listener, func() func(args []string) string {
+		authenticated := password == ""
+		return func(args []string) string {
+			switch {
+			case len(args) > 1 && strings.ToUpper(args[0]) == "AUTH":
+				if args[len(args)-1] == password {
+					authenticated = true
+					return "+OK\r\n"
+				}
+				return "-WRONGPASS invalid username-password pair\r\n"
+			case !authenticated:
+				return "-NOAUTH Authentication required.\r\n"
+			default:
+				return "+PONG\r\n"
+			}
+		}
+	})
+}
+
+// serveTestRedisHandler serves a mock redis server on the given listener. Each connection is served
+// by a handler created by newHandler, which returns the raw reply of a command. An empty reply closes the connection.
+func serveTestRedisHandler(listener net.Listener, newHandler func() func(args []string) string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			reader := bufio.NewReader(conn)
+			handle := newHandler()
+			for {
+				// a command is an array of bulk strings, e.g. "*1\r\n$4\r\nPING\r\n"
+				header, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				n, _ := strconv.Atoi(strings.TrimSpace(header[1:]))
+				args := make([]string, n)
+				for i := 0; i < n; i++ {
+					// a bulk string is its length followed by the binary-safe content, e.g. "$4\r\nPING\r\n"
+					length, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					size, _ := strconv.Atoi(strings.TrimSpace(length[1:]))
+					arg := make([]byte, size+2)
+					if _, err := io.ReadFull(reader, arg); err != nil {
+						return
+					}
+					args[i] = string(arg[:size])
+				}
+
+				reply := handle(args)
+				if reply == "" {
+					return
+				}
+				if _, err := conn.Write([]byte(reply)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// serveTestRedisMemory serves a mock redis server storing items in memory, which answers GET, SET, EXISTS and DEL only.
+func serveTestRedisMemory(listener net.Listener) {
+	var (
+		lock  sync.Mutex
+		items = make(map[string]string)
+	)
+	serveTestRedisHandler(listener, func() func(args []string) string {
+		return func(args []string) string {
+			lock.Lock()
+			defer lock.Unlock()
+			switch strings.ToUpper(args[0]) {
+			case "SET":
+				items[args[1]] = args[2]
+				return "+OK\r\n"
+			case "GET":
+				if value, ok := items[args[1]]; ok {
+					return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+				}
+				return "$-1\r\n"
+			case "EXISTS", "DEL":
+				n := 0
+				for _, key := range args[1:] {
+					if _, ok := items[key]; ok {
+						n++
+						if strings.ToUpper(args[0]) == "DEL" {
+							delete(items, key)
+						}
+					}
+				}
+				return fmt.Sprintf(":%d\r\n", n)
+			default:
+				return "+PONG\r\n"
+			}
+		}
+	})
+}
+
+// redisRoundTripCounter is a redis hook counting round trips, where a pipeline is a round trip.
+type redisRoundTripCounter struct {
+	roundTrips int64
+}
+
+func (c *redisRoundTripCounter) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	atomic.AddInt64(&c.roundTrips, 1)
+	return ctx, nil
+}
+
+func (c *redisRoundTripCounter) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (c *redisRoundTripCounter) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	atomic.AddInt64(&c.roundTrips, 1)
+	return ctx, nil
+}
+
+func (c *redisRoundTripCounter) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+// newTestRedisMemoryCache returns a redis cache of a mock redis server storing items in memory,
+// and a counter of the round trips to the server.
+func newTestRedisMemoryCache(tb testing.TB) (*RedisCache, *redisRoundTripCounter, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	go serveTestRedisMemory(listener)
+
+	config := getTestRedisConfig()
+	config.RedisEndpoints = []string{listener.Addr().String()}
+	cache, err := newRedisCache(config)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	counter := &redisRoundTripCounter{}
+	cache.client.(*redis.Client).AddHook(counter)
+	return cache, counter, func() {
+		cache.Close()
+		listener.Close()
+	}
+}
+
+// TestRedisCache_PoolSize checks that the client does not open connections more than the configured pool size
+// under concurrent Get calls.
+func TestRedisCache_PoolSize(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveTestRedisMemory(listener)
+
+	config := getTestRedisConfig()
+	config.RedisEndpoints = []string{listener.Addr().String()}
+	config.RedisPoolSize = 1
+	cache, err := newRedisCache(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	key, value := randBytes(32), randBytes(500)
+	if err := cache.SetSync(key, value); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, value, cache.Get(key))
+		}()
+	}
+	wg.Wait()
+
+	client := cache.client.(*redis.Client)
+	assert.Equal(t, 1, client.Options().PoolSize)
+	assert.Equal(t, uint32(1), client.PoolStats().TotalConns)
+
+	// negative values are not allowed
+	config.RedisPoolSize = -1
+	_, err = newRedisCache(config)
+	assert.Equal(t, errRedisPoolSize, err)
+}
+
+// TestRedisCache_SetBatch checks that items written by SetBatch in a round trip are read.
+func TestRedisCache_SetBatch(t *testing.T) {
+	cache, counter, closeCache := newTestRedisMemoryCache(t)
+	defer closeCache()
+
+	items := make([]setItem, 1000)
+	for i := range items {
+		items[i] = setItem{key: randBytes(32), value: randBytes(500)}
+	}
+	assert.Nil(t, cache.SetBatch(items))
+	assert.Equal(t, int64(1), atomic.LoadInt64(&counter.roundTrips))
+
+	for _, item := range items {
+		assert.Equal(t, item.value, cache.Get(item.key))
+	}
+
+	// items written asynchronously are batched by the workers
+	asyncItems := make([]setItem, redisSetBatchSize)
+	var wg sync.WaitGroup
+	wg.Add(len(asyncItems))
+	for i := range asyncItems {
+		asyncItems[i] = setItem{key: randBytes(32), value: randBytes(500)}
+		cache.SetWithCallback(asyncItems[i].key, asyncItems[i].value, func(err error) {
+			assert.Nil(t, err)
+			wg.Done()
+		})
+	}
+	wg.Wait()
+	for _, item := range asyncItems {
+		assert.Equal(t, item.value, cache.Get(item.key))
+	}
+}
+
+// TestRedisCache_OperationMetrics checks that the metrics of Get, Has and Set advance by the operations.
+func TestRedisCache_OperationMetrics(t *testing.T) {
+	cache, _, closeCache := newTestRedisMemoryCache(t)
+	defer closeCache()
+
+	getCount, hasCount, setCount := redisGetMetrics.latency.Count(), redisHasMetrics.latency.Count(), redisSetMetrics.latency.Count()
+	hits, misses := redisHitCounter.Count(), redisMissCounter.Count()
+
+	key, value := randBytes(32), randBytes(500)
+	assert.Nil(t, cache.Get(key)) // miss
+	cache.Set(key, value)
+	assert.Equal(t, value, cache.Get(key)) // hit
+	_, ok := cache.Has(key)                // hit
+	assert.True(t, ok)
+
+	assert.Equal(t, getCount+2, redisGetMetrics.latency.Count())
+	assert.Equal(t, hasCount+1, redisHasMetrics.latency.Count())
+	assert.Equal(t, setCount+1, redisSetMetrics.latency.Count())
+	assert.Equal(t, hits+2, redisHitCounter.Count())
+	assert.Equal(t, misses+1, redisMissCounter.Count())
+
+	// a failure of the operation is counted as an error
+	errCount := redisGetMetrics.errors.Count()
+	cache.client.Close()
+	assert.Nil(t, cache.Get(key))
+	assert.Equal(t, errCount+1, redisGetMetrics.errors.Count())
+}
+
+// BenchmarkRedisCache_SetBatch compares the round trips of writing items in a batch and one by one.
+func BenchmarkRedisCache_SetBatch(b *testing.B) {
+	const numItems = 1000
+	items := make([]setItem, numItems)
+	for i := range items {
+		items[i] = setItem{key: randBytes(32), value: randBytes(500)}
+	}
+
+	b.Run("Set", func(b *testing.B) {
+		cache, counter, closeCache := newTestRedisMemoryCache(b)
+		defer closeCache()
+
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			for _, item := range items {
+				cache.Set(item.key, item.value)
+			}
+		}
+		b.ReportMetric(float64(atomic.LoadInt64(&counter.roundTrips))/float64(b.N), "roundtrips/op")
+	})
+
+	b.Run("SetBatch", func(b *testing.B) {
+		cache, counter, closeCache := newTestRedisMemoryCache(b)
+		defer closeCache()
+
+		b.ResetTimer()
+		for n := 0; n < b.N; n++ {
+			if err := cache.SetBatch(items); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ReportMetric(float64(atomic.LoadInt64(&counter.roundTrips))/float64(b.N), "roundtrips/op")
+	})
+}
+
+// TestRedisCache_Auth checks that a redis cache authenticates to a password-protected redis server.
+func TestRedisCache_Auth(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveTestRedis(listener, "secret")
+
+	config := getTestRedisConfig()
+	config.RedisEndpoints = []string{listener.Addr().String()}
+
+	// the right password
+	config.RedisPassword = "secret"
+	cache, err := newRedisCache(config)
+	assert.Nil(t, err)
+	assert.Nil(t, cache.client.Ping().Err())
+	cache.Close()
+
+	config.RedisUsername = "klaytn"
+	cache, err = newRedisCache(config)
+	assert.Nil(t, err)
+	assert.Equal(t, "klaytn", cache.client.(*redis.Client).Options().Username)
+	cache.Close()
+
+	// a wrong password
+	config.RedisPassword = "wrong"
+	_, err = newRedisCache(config)
+	assert.True(t, errors.Is(err, errRedisAuth), "err: %v", err)
+
+	// no password
+	config.RedisUsername, config.RedisPassword = "", ""
+	cache, err = newRedisCache(config)
+	assert.Nil(t, err)
+	assert.True(t, isRedisAuthError(cache.client.Ping().Err()))
+	cache.Close()
+
+	// the password is redacted
+	config.RedisPassword = "secret"
+	assert.False(t, strings.Contains(config.String(), "secret"))
+	assert.True(t, strings.Contains(config.String(), "<redacted>"))
+}
+
+// TestRedisCache_Sentinel checks that a redis cache in sentinel mode follows the master on failover.
+func TestRedisCache_Sentinel(t *testing.T) {
+	var listeners []net.Listener
+	newListener := func() net.Listener {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners = append(listeners, listener)
+		return listener
+	}
+	defer func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}()
+
+	// masters answering GET with their own names. A down master closes the connections.
+	serveMaster := func(name string, down *int32) func() func(args []string) string {
+		return func() func(args []string) string {
+			return func(args []string) string {
+				if atomic.LoadInt32(down) == 1 {
+					return ""
+				}
+				switch strings.ToUpper(args[0]) {
+				case "GET":
+					return fmt.Sprintf("$%d\r\n%s\r\n", len(name), name)
+				case "SET":
+					return "+OK\r\n"
+				default:
+					return "+PONG\r\n"
+				}
+			}
+		}
+	}
+	masterA, masterB := newListener(), newListener()
+	var downA, downB int32
+	go serveTestRedisHandler(masterA, serveMaster("A", &downA))
+	go serveTestRedisHandler(masterB, serveMaster("B", &downB))
+
+	// a sentinel serving the address of the current master
+	var master atomic.Value
+	master.Store(masterA.Addr().String())
+	sentinel := newListener()
+	go serveTestRedisHandler(sentinel, func() func(args []string) string {
+		return func(args []string) string {
+			if len(args) < 2 {
+				return "+PONG\r\n"
+			}
+			switch strings.ToLower(args[0] + " " + args[1]) {
+			case "sentinel get-master-addr-by-name":
+				host, port, _ := net.SplitHostPort(master.Load().(string))
+				return fmt.Sprintf("*2\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n", len(host), host, len(port), port)
+			case "sentinel sentinels":
+				return "*0\r\n"
+			case "subscribe +switch-master":
+				return "*3\r\n$9\r\nsubscribe\r\n$14\r\n+switch-master\r\n:1\r\n"
+			default:
+				return "+OK\r\n"
+			}
+		}
+	})
+
+	config := getTestRedisConfig()
+	config.RedisEndpoints = []string{sentinel.Addr().String()}
+	config.RedisSentinelEnable, config.RedisMasterName = true, "mymaster"
+	cache, err := newRedisCache(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cache.Close()
+
+	key := randBytes(32)
+	assert.Equal(t, []byte("A"), cache.Get(key))
+	assert.Nil(t, cache.SetSync(key, randBytes(500)))
+
+	// failover from A to B
+	master.Store(masterB.Addr().String())
+	atomic.StoreInt32(&downA, 1)
+	assert.Eventually(t, func() bool { return bytes.Equal([]byte("B"), cache.Get(key)) }, 5*time.Second, 10*time.Millisecond)
+	assert.Nil(t, cache.SetSync(key, randBytes(500)))
+
+	// invalid configs
+	config.RedisClusterEnable = true
+	_, err = newRedisCache(config)
+	assert.Equal(t, errRedisSentinelWithCluster, err)
+
+	config.RedisClusterEnable, config.RedisMasterName = false, ""
+	_, err = newRedisCache(config)
+	assert.Equal(t, errRedisNoMasterName, err)
+}
+
+// TestRedisCache_TLS checks that a redis cache connects to a redis server over TLS if TLS is enabled.
+func TestRedisCache_TLS(t *testing.T) {
+	dir, err := ioutil.TempDir(os.TempDir(), "redis_tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	certFile, keyFile := writeTestKeyPair(t, dir)
+	serverCert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{serverCert}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go serveTestRedisPing(listener)
+
+	config := getTestRedisConfig()
+	config.RedisEndpoints = []string{listener.Addr().String()}
+
+	// the server certificate is verified with the given CA
+	config.RedisTLSEnable, config.RedisTLSCAFile = true, certFile
+	cache, err := newRedisCache(config)
+	assert.Nil(t, err)
+	assert.Nil(t, cache.client.Ping().Err())
+	cache.Close()
+
+	// the server certificate is not trusted without the CA
+	config.RedisTLSCAFile = ""
+	cache, err = newRedisCache(config)
+	assert.Nil(t, err)
+	assert.NotNil(t, cache.client.Ping().Err())
+	cache.Close()
+
+	config.RedisTLSSkipVerify = true
+	cache, err = newRedisCache(config)
+	assert.Nil(t, err)
+	assert.Nil(t, cache.client.Ping().Err())
+	cache.Close()
+
+	// plaintext connections are used if TLS is disabled
+	config.RedisTLSEnable, config.RedisTLSSkipVerify = false, false
+	cache, err = newRedisCache(config)
+	assert.Nil(t, err)
+	assert.Nil(t, cache.client.(*redis.Client).Options().TLSConfig)
+	cache.Close()
+}
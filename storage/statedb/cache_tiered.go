@@ -0,0 +1,166 @@
+// Copyright 2022 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package statedb
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errNoCacheTier = errors.New("no tier of trie node cache")
+
+// NewTieredCache creates a TieredCache of the caches of the given configs, from the nearest tier to the farthest.
+// For example, a local cache followed by a redis cache reads through the local cache to redis, and then to
+// the database on a miss. A local cache of zero size is not added as a tier.
+func NewTieredCache(configs ...*TrieNodeCacheConfig) (*TieredCache, error) {
+	tiers := make([]TrieNodeCache, 0, len(configs))
+	for i, config := range configs {
+		tier, err := NewTrieNodeCache(config)
+		if err != nil {
+			for _, created := range tiers {
+				created.Close()
+			}
+			return nil, fmt.Errorf("failed to create tier %d of trie node cache: %w", i, err)
+		}
+		if tier == nil {
+			logger.Warn("Skip a trie node cache tier of zero size", "tier", i)
+			continue
+		}
+		tiers = append(tiers, tier)
+	}
+	if len(tiers) == 0 {
+		return nil, errNoCacheTier
+	}
+
+	logger.Info("Initialized tiered trie node cache", "tiers", len(tiers))
+	return newTieredCache(tiers...), nil
+}
+
+func newTieredCache(tiers ...TrieNodeCache) *TieredCache {
+	return &TieredCache{tiers: tiers}
+}
+
+// TieredCache chains trie node caches. It reads the tiers in order, and an item found in a tier is promoted to
+// the nearer tiers, so that it is read without visiting the farther tiers afterwards.
+// It writes an item to all tiers, and a redis tier is written asynchronously like the remote cache of HybridCache.
+type TieredCache struct {
+	tiers []TrieNodeCache // from the nearest one
+}
+
+// Tiers returns the tiers of the cache from the nearest one.
+func (cache *TieredCache) Tiers() []TrieNodeCache {
+	return cache.tiers
+}
+
+// Set writes data to all tiers.
+func (cache *TieredCache) Set(k, v []byte) {
+	for _, tier := range cache.tiers {
+		setTier(tier, k, v)
+	}
+}
+
+func (cache *TieredCache) Get(k []byte) []byte {
+	for i, tier := range cache.tiers {
+		if ret := tier.Get(k); ret != nil {
+			cache.promote(i, k, ret)
+			return ret
+		}
+	}
+	return nil
+}
+
+func (cache *TieredCache) Has(k []byte) ([]byte, bool) {
+	for i, tier := range cache.tiers {
+		if ret, has := tier.Has(k); has {
+			cache.promote(i, k, ret)
+			return ret, has
+		}
+	}
+	return nil, false
+}
+
+// promote writes an item found in the given tier to the nearer tiers.
+func (cache *TieredCache) promote(found int, k, v []byte) {
+	for _, tier := range cache.tiers[:found] {
+		setTier(tier, k, v)
+	}
+}
+
+// setTier writes an item to a tier, asynchronously if it is a redis cache.
+func setTier(tier TrieNodeCache, k, v []byte) {
+	if redis, ok := tier.(*RedisCache); ok {
+		redis.SetAsync(k, v)
+	} else {
+		tier.Set(k, v)
+	}
+}
+
+// Delete removes an item from all tiers.
+func (cache *TieredCache) Delete(k []byte) {
+	for _, tier := range cache.tiers {
+		tier.Delete(k)
+	}
+}
+
+// TieredCacheStats is the statistics of a TieredCache, of the tiers from the nearest one.
+type TieredCacheStats []interface{}
+
+func (cache *TieredCache) UpdateStats() interface{} {
+	stats := make(TieredCacheStats, len(cache.tiers))
+	for i, tier := range cache.tiers {
+		stats[i] = tier.UpdateStats()
+	}
+	return stats
+}
+
+// SaveToFile saves the nearest tier keeping items in local memory, which is loaded again from the file path
+// by the tier of the same FastCacheFileDir. The other tiers are not saved, since they would overwrite it.
+func (cache *TieredCache) SaveToFile(filePath string, concurrency int) error {
+	for i, tier := range cache.tiers {
+		if !savesToFile(tier) {
+			continue
+		}
+		if err := tier.SaveToFile(filePath, concurrency); err != nil {
+			logger.Error("failed to save a tier of trie node cache to file",
+				"tier", i, "filePath", filePath, "concurrency", concurrency, "err", err)
+			return err
+		}
+		return nil
+	}
+	return nil
+}
+
+// savesToFile returns true if the trie node cache saves its items by SaveToFile.
+func savesToFile(cache TrieNodeCache) bool {
+	switch cache.(type) {
+	case *FastCache, *HybridCache, *TieredCache:
+		return true
+	default:
+		return false
+	}
+}
+
+// Close closes all tiers, and returns the first error.
+func (cache *TieredCache) Close() error {
+	var firstErr error
+	for _, tier := range cache.tiers {
+		if err := tier.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
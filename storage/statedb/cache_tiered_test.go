@@ -0,0 +1,137 @@
+// Copyright 2022 The klaytn Authors
+// This file is part of the klaytn library.
+//
+// The klaytn library is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The klaytn library is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with the klaytn library. If not, see <http://www.gnu.org/licenses/>.
+
+package statedb
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/klaytn/klaytn/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestTieredCache_Promote tests whether an item found in a farther tier is promoted to the nearer tiers.
+func TestTieredCache_Promote(t *testing.T) {
+	near := newEvictingCacheWithSize(LocalCacheEvictionLRU, 1024)
+	middle := newEvictingCacheWithSize(LocalCacheEvictionLRU, 1024)
+	far := newEvictingCacheWithSize(LocalCacheEvictionLRU, 1024)
+	cache := newTieredCache(near, middle, far)
+
+	key, value := randBytes(32), randBytes(100)
+	far.Set(key, value)
+	assert.Nil(t, near.Get(key))
+	assert.Nil(t, middle.Get(key))
+
+	assert.Equal(t, value, cache.Get(key))
+	assert.Equal(t, value, near.Get(key))
+	assert.Equal(t, value, middle.Get(key))
+
+	// an item found in the middle tier is not written to the farther tier
+	key2, value2 := randBytes(32), randBytes(100)
+	middle.Set(key2, value2)
+	ret, ok := cache.Has(key2)
+	assert.True(t, ok)
+	assert.Equal(t, value2, ret)
+	assert.Equal(t, value2, near.Get(key2))
+	assert.Nil(t, far.Get(key2))
+
+	// set and delete go to all tiers
+	key3, value3 := randBytes(32), randBytes(100)
+	cache.Set(key3, value3)
+	for _, tier := range cache.Tiers() {
+		assert.Equal(t, value3, tier.Get(key3))
+	}
+	cache.Delete(key3)
+	for _, tier := range cache.Tiers() {
+		assert.Nil(t, tier.Get(key3))
+	}
+	_, ok = cache.Has(key3)
+	assert.False(t, ok)
+
+	stats := cache.UpdateStats().(TieredCacheStats)
+	assert.Equal(t, 3, len(stats))
+}
+
+func TestNewTieredCache(t *testing.T) {
+	_, err := NewTieredCache()
+	assert.Equal(t, errNoCacheTier, err)
+
+	// a local cache of zero size is skipped
+	cache, err := NewTieredCache(
+		&TrieNodeCacheConfig{CacheType: CacheTypeLocal, LocalCacheSizeMiB: 0},
+		&TrieNodeCacheConfig{CacheType: CacheTypeLocal, LocalCacheSizeMiB: 10},
+	)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(cache.Tiers()))
+	assert.Nil(t, cache.Close())
+
+	_, err = NewTieredCache(&TrieNodeCacheConfig{CacheType: "unknown"})
+	assert.True(t, errors.Is(err, errNotSupportedCacheType))
+}
+
+// TestTieredCache_SaveToFile tests whether only the nearest tier saving to file is saved.
+func TestTieredCache_SaveToFile(t *testing.T) {
+	dirName, err := ioutil.TempDir(os.TempDir(), "tieredcache_savetofile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirName)
+
+	config := &TrieNodeCacheConfig{CacheType: CacheTypeLocal, LocalCacheSizeMiB: 10}
+	evicting := newEvictingCacheWithSize(LocalCacheEvictionLRU, 1024)
+	near, far := newFastCache(config), newFastCache(config)
+	cache := newTieredCache(evicting, near, far)
+
+	nearKey, nearValue := randBytes(32), randBytes(100)
+	farKey, farValue := randBytes(32), randBytes(100)
+	near.Set(nearKey, nearValue)
+	far.Set(farKey, farValue)
+	assert.Nil(t, cache.SaveToFile(dirName, runtime.NumCPU()))
+
+	config.FastCacheFileDir = dirName
+	loaded := newFastCache(config)
+	assert.Equal(t, nearValue, loaded.Get(nearKey))
+	assert.Nil(t, loaded.Get(farKey))
+}
+
+// TestTieredCache_RedisTier tests whether an item only in the redis tier is promoted to the local tier on a read.
+func TestTieredCache_RedisTier(t *testing.T) {
+	storage.SkipLocalTest(t)
+
+	cache, err := NewTieredCache(&TrieNodeCacheConfig{CacheType: CacheTypeLocal, LocalCacheSizeMiB: 10}, getTestRedisConfig())
+	assert.Nil(t, err)
+	defer cache.Close()
+	local, remote := cache.Tiers()[0], cache.Tiers()[1].(*RedisCache)
+
+	key, value := randBytes(32), randBytes(500)
+	assert.Nil(t, remote.SetSync(key, value))
+	assert.Nil(t, local.Get(key))
+
+	assert.Equal(t, value, cache.Get(key))
+	assert.Equal(t, value, local.Get(key))
+
+	// an item is written to the redis tier asynchronously
+	key2, value2 := randBytes(32), randBytes(500)
+	cache.Set(key2, value2)
+	assert.Equal(t, value2, local.Get(key2))
+	time.Sleep(sleepDurationForAsyncBehavior)
+	assert.Equal(t, value2, remote.Get(key2))
+}